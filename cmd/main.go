@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"io"
 	"log"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"whatsapp-bot/config"
 	"whatsapp-bot/internal/handlers"
 	"whatsapp-bot/internal/services"
+	"whatsapp-bot/internal/utils"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -27,28 +29,58 @@ func main() {
 	// Disable standard logging
 	log.SetOutput(io.Discard)
 
+	configFile := flag.String("config", "", "Arquivo de configuração YAML (opcional; também via CONFIG_FILE)")
+	flag.Parse()
+
 	// Load config
-	cfg := config.NewConfig()
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		utils.LogError("Erro ao carregar configuração: %v", err)
+		os.Exit(1)
+	}
 
 	// Initialize database connection
-	db, err := config.ConnectDatabase()
+	db, err := config.ConnectDatabase(cfg.Database)
 	if err != nil {
+		utils.LogError("Erro ao conectar ao banco de dados: %v", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
+	// Armazenamento de mídia (S3/MinIO ou disco local)
+	mediaStore, err := services.NewMediaStore(cfg)
+	if err != nil {
+		utils.LogError("Erro ao criar armazenamento de mídia: %v", err)
+		os.Exit(1)
+	}
+
 	// Create connection manager
-	connectionManager := services.NewConnectionManager(db, cfg)
+	connectionManager := services.NewConnectionManager(db, cfg, mediaStore)
+
+	// Retomar as filas de envio pendentes
+	if err := connectionManager.StartOutboundDispatchers(); err != nil {
+		utils.LogError("Erro ao retomar filas de envio: %v", err)
+	}
+
+	// Iniciar o agendador de mensagens
+	connectionManager.StartScheduler()
+
+	// Iniciar o despachante de campanhas
+	connectionManager.StartCampaignRunner()
 
 	// Create HTTP handler
-	httpHandler := handlers.NewHTTPHandler(connectionManager)
+	httpHandler := handlers.NewHTTPHandler(connectionManager, cfg)
+
+	// Limpar periodicamente as chaves de idempotência expiradas
+	httpHandler.StartIdempotencyPurge()
 	router := mux.NewRouter().PathPrefix("/api/v1").Subrouter()
 
-	router.HandleFunc("/send-message", httpHandler.SendMessage).Methods("POST", "OPTIONS")
-	router.HandleFunc("/send-image", httpHandler.SendImage).Methods("POST", "OPTIONS")
-	router.HandleFunc("/send-audio", httpHandler.SendAudio).Methods("POST", "OPTIONS")
-	router.HandleFunc("/send-document", httpHandler.SendDocument).Methods("POST", "OPTIONS")
+	router.HandleFunc("/send-message", httpHandler.WithIdempotency(httpHandler.SendMessage)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/send-image", httpHandler.WithIdempotency(httpHandler.SendImage)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/send-audio", httpHandler.WithIdempotency(httpHandler.SendAudio)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/send-document", httpHandler.WithIdempotency(httpHandler.SendDocument)).Methods("POST", "OPTIONS")
 	router.HandleFunc("/send-typing", httpHandler.SendTyping).Methods("POST", "OPTIONS")
+	router.HandleFunc("/presence", httpHandler.SetChatPresence).Methods("POST", "OPTIONS")
 	router.HandleFunc("/upload", httpHandler.HandleUpload).Methods("POST", "OPTIONS")
 
 	// Rotas de autenticação e status
@@ -59,9 +91,52 @@ func main() {
 	// Rotas de contatos
 	router.HandleFunc("/mark-viewed", httpHandler.MarkContactViewed).Methods("POST", "OPTIONS")
 	router.HandleFunc("/check-viewed", httpHandler.CheckContactViewed).Methods("POST", "OPTIONS")
+	router.HandleFunc("/contacts/duplicates", httpHandler.ListDuplicateContacts).Methods("GET", "OPTIONS")
+	router.HandleFunc("/contacts/merge", httpHandler.MergeContacts).Methods("POST", "OPTIONS")
+	router.HandleFunc("/check-numbers", httpHandler.CheckNumbers).Methods("POST", "OPTIONS")
+	router.HandleFunc("/conversations/{contactId:[0-9]+}/read", httpHandler.MarkConversationRead).Methods("POST", "OPTIONS")
+
+	// Rotas da fila de envio
+	router.HandleFunc("/outbound-messages", httpHandler.ListOutboundMessages).Methods("GET", "OPTIONS")
+	router.HandleFunc("/outbound-messages/{id:[0-9]+}", httpHandler.GetOutboundMessage).Methods("GET", "OPTIONS")
+	router.HandleFunc("/outbound-messages/{id:[0-9]+}/retry", httpHandler.RetryOutboundMessage).Methods("POST", "OPTIONS")
+
+	// Rotas de configurações do setor
+	router.HandleFunc("/sector-settings", httpHandler.GetSectorSettings).Methods("GET", "OPTIONS")
+	router.HandleFunc("/sector-settings", httpHandler.UpdateSectorSettings).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/users/{id:[0-9]+}/signature", httpHandler.GetUserSignature).Methods("GET", "OPTIONS")
+	router.HandleFunc("/users/{id:[0-9]+}/signature", httpHandler.UpdateUserSignature).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/users/{id:[0-9]+}/signature", httpHandler.DeleteUserSignature).Methods("DELETE", "OPTIONS")
+
+	// Rotas de mensagens agendadas
+	router.HandleFunc("/scheduled-messages", httpHandler.CreateScheduledMessage).Methods("POST", "OPTIONS")
+	router.HandleFunc("/scheduled-messages", httpHandler.ListScheduledMessages).Methods("GET", "OPTIONS")
+	router.HandleFunc("/scheduled-messages/{id:[0-9]+}", httpHandler.CancelScheduledMessage).Methods("DELETE", "OPTIONS")
+	router.HandleFunc("/scheduled-messages/{id:[0-9]+}/reschedule", httpHandler.RescheduleMessage).Methods("PUT", "OPTIONS")
+
+	// Rotas de campanhas
+	router.HandleFunc("/campaigns", httpHandler.CreateCampaign).Methods("POST", "OPTIONS")
+	router.HandleFunc("/campaigns", httpHandler.ListCampaigns).Methods("GET", "OPTIONS")
+	router.HandleFunc("/campaigns/{id:[0-9]+}", httpHandler.GetCampaign).Methods("GET", "OPTIONS")
+	router.HandleFunc("/campaigns/{id:[0-9]+}/recipients", httpHandler.AddCampaignRecipients).Methods("POST", "OPTIONS")
+	router.HandleFunc("/campaigns/{id:[0-9]+}/recipients", httpHandler.ListCampaignRecipients).Methods("GET", "OPTIONS")
+	router.HandleFunc("/campaigns/{id:[0-9]+}/{action:start|pause|resume|cancel}", httpHandler.ChangeCampaignStatus).Methods("POST", "OPTIONS")
+
+	// Rotas de templates de mensagens
+	router.HandleFunc("/templates", httpHandler.CreateTemplate).Methods("POST", "OPTIONS")
+	router.HandleFunc("/templates", httpHandler.ListTemplates).Methods("GET", "OPTIONS")
+	router.HandleFunc("/templates/{id:[0-9]+}", httpHandler.GetTemplate).Methods("GET", "OPTIONS")
+	router.HandleFunc("/templates/{id:[0-9]+}", httpHandler.UpdateTemplate).Methods("PUT", "OPTIONS")
+	router.HandleFunc("/templates/{id:[0-9]+}", httpHandler.DeleteTemplate).Methods("DELETE", "OPTIONS")
+
+	// Mídias privadas: arquivos do armazenamento local (links assinados) e redirecionamentos para links temporários
+	router.HandleFunc("/media/{key:.+}", httpHandler.ServeMedia).Methods("GET", "HEAD", "OPTIONS")
+	router.HandleFunc("/messages/{id:[0-9]+}/media", httpHandler.RedirectMessageMedia).Methods("GET", "OPTIONS")
+	router.HandleFunc("/messages/{id:[0-9]+}/preview", httpHandler.RedirectMessagePreview).Methods("GET", "OPTIONS")
+	router.HandleFunc("/contacts/{id:[0-9]+}/avatar", httpHandler.RedirectContactAvatar).Methods("GET", "OPTIONS")
 
 	// Rota WebSocket
-	router.HandleFunc("/ws", handlers.WebSocketHandler)
+	router.HandleFunc("/ws", httpHandler.WebSocketHandler)
 
 	// Serve os arquivos estáticos do Swagger
 	fs := http.FileServer(http.Dir("./docs"))
@@ -69,7 +144,7 @@ func main() {
 
 	// Configuração do Swagger UI
 	router.PathPrefix("/swagger-ui/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("https://unofficial.ligchat.com/api/v1/swagger/swagger.json"),
+		httpSwagger.URL(cfg.SwaggerURL),
 		httpSwagger.DeepLinking(true),
 	))
 
@@ -78,10 +153,10 @@ func main() {
 
 	// Configurar CORS
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
+		AllowedOrigins:   cfg.CORSOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Link", "Idempotent-Replayed", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	})
@@ -90,7 +165,7 @@ func main() {
 	handler := c.Handler(mainRouter)
 
 	server := &http.Server{
-		Addr:    ":8081",
+		Addr:    cfg.ListenAddr,
 		Handler: handler,
 	}
 
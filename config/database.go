@@ -3,42 +3,51 @@ package config
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type DatabaseConfig struct {
-	Server   string
-	Database string
-	User     string
-	Password string
+	// DSN completo; quando informado, os campos de servidor e credenciais são ignorados
+	DSN             string        `yaml:"dsn"`
+	Server          string        `yaml:"server"`
+	Database        string        `yaml:"database"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
 }
 
 func NewDatabaseConfig() *DatabaseConfig {
 	return &DatabaseConfig{
-		Server:   "ligchat.cmj46oa0g26n.us-east-1.rds.amazonaws.com",
-		Database: "ligchat",
-		User:     "ligchat",
-		Password: "Cap0199**",
+		MaxOpenConns: 25,
+		MaxIdleConns: 5,
 	}
 }
 
 func (c *DatabaseConfig) GetDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
 	// Use UTC for database connections to avoid timezone issues
 	return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&multiStatements=true&loc=UTC",
 		c.User, c.Password, c.Server, c.Database)
 }
 
-func ConnectDatabase() (*sql.DB, error) {
-	config := NewDatabaseConfig()
+func ConnectDatabase(config *DatabaseConfig) (*sql.DB, error) {
 	db, err := sql.Open("mysql", config.GetDSN())
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %v", err)
 	}
 
 	// Configurar o pool de conexões
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
 
 	// Testar a conexão
 	err = db.Ping()
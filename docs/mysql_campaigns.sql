@@ -0,0 +1,44 @@
+-- Campanhas de envio em massa por setor
+CREATE TABLE IF NOT EXISTS campaigns (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    sector_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    -- text, image, audio ou document
+    kind VARCHAR(20) NOT NULL,
+    -- Texto da mensagem ou legenda da mídia
+    content TEXT NULL,
+    -- Chave do arquivo no S3 para campanhas de mídia
+    media_key VARCHAR(512) NULL,
+    file_name VARCHAR(255) NULL,
+    user_id INT NULL,
+    is_anonymous TINYINT(1) NOT NULL DEFAULT 0,
+    rate_per_minute INT NOT NULL DEFAULT 20,
+    -- draft, running, paused, canceled ou completed
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    started_at DATETIME(6) NULL,
+    finished_at DATETIME(6) NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    INDEX idx_campaigns_sector (sector_id, status)
+);
+
+-- Destinatários de cada campanha e o estado do envio
+CREATE TABLE IF NOT EXISTS campaign_recipients (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    campaign_id BIGINT NOT NULL,
+    sector_id INT NOT NULL,
+    contact_id INT NULL,
+    recipient VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NULL,
+    -- queued, sending, sent, delivered, read, failed ou canceled
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    outbound_message_id BIGINT NULL,
+    whatsapp_message_id VARCHAR(128) NULL,
+    last_error TEXT NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    UNIQUE KEY uk_campaign_recipient (campaign_id, recipient),
+    INDEX idx_campaign_recipients_status (campaign_id, status),
+    INDEX idx_campaign_recipients_whatsapp (sector_id, whatsapp_message_id),
+    FOREIGN KEY (campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Identificador LID (linked identity) do contato, usado pelo WhatsApp no lugar do telefone
+ALTER TABLE contacts
+    ADD COLUMN lid VARCHAR(64) NULL AFTER number,
+    ADD INDEX idx_contacts_sector_lid (sector_id, lid);
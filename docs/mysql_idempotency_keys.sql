@@ -0,0 +1,16 @@
+-- Chaves de idempotência dos endpoints de envio
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    idem_key VARCHAR(255) NOT NULL,
+    endpoint VARCHAR(100) NOT NULL,
+    -- SHA-256 do corpo da requisição original
+    request_hash CHAR(64) NOT NULL,
+    -- pending ou completed
+    status VARCHAR(20) NOT NULL,
+    status_code INT NULL,
+    response_body MEDIUMBLOB NULL,
+    outbound_message_id BIGINT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (idem_key, endpoint),
+    INDEX idx_idempotency_expires (expires_at)
+);
@@ -0,0 +1,7 @@
+-- Chaves de idempotência passam a ser por setor, e chaves pendentes ficam reservadas apenas até locked_until
+-- (depois disso, uma nova requisição pode assumir a chave de uma requisição que não terminou)
+ALTER TABLE idempotency_keys
+    ADD COLUMN sector_id INT NOT NULL DEFAULT 0 FIRST,
+    ADD COLUMN locked_until DATETIME(6) NULL AFTER outbound_message_id,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (sector_id, idem_key, endpoint);
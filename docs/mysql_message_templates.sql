@@ -0,0 +1,16 @@
+-- Templates de mensagens por setor
+CREATE TABLE IF NOT EXISTS message_templates (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    sector_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    -- text, image, audio ou document
+    kind VARCHAR(20) NOT NULL DEFAULT 'text',
+    -- Texto ou legenda com placeholders, ex: {{contact.name}}
+    content TEXT NULL,
+    -- Chave do anexo no S3 para templates de mídia
+    media_key VARCHAR(512) NULL,
+    file_name VARCHAR(255) NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    INDEX idx_message_templates_sector (sector_id)
+);
@@ -0,0 +1,3 @@
+-- ID da mensagem reservado na primeira tentativa de envio e reaproveitado nas seguintes
+ALTER TABLE outbound_messages
+    ADD COLUMN pending_message_id VARCHAR(128) NULL AFTER whatsapp_message_id;
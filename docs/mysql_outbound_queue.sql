@@ -0,0 +1,31 @@
+-- Fila persistente de mensagens de saída por setor
+CREATE TABLE IF NOT EXISTS outbound_messages (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    sector_id INT NOT NULL,
+    -- text, image, audio ou document
+    kind VARCHAR(20) NOT NULL,
+    recipient VARCHAR(64) NOT NULL,
+    -- Texto da mensagem ou legenda da mídia
+    content TEXT NULL,
+    -- Chave do arquivo no S3 para mensagens de mídia
+    media_key VARCHAR(512) NULL,
+    file_name VARCHAR(255) NULL,
+    user_id INT NULL,
+    is_anonymous TINYINT(1) NOT NULL DEFAULT 0,
+    sent_at DATETIME(6) NULL,
+    -- queued, sending, sent ou dead
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 10,
+    next_attempt_at DATETIME(6) NOT NULL,
+    last_error TEXT NULL,
+    whatsapp_message_id VARCHAR(128) NULL,
+    -- ID reservado na primeira tentativa e reaproveitado nas seguintes para o destinatário não receber duplicatas
+    pending_message_id VARCHAR(128) NULL,
+    -- Origem da mensagem (api, call, ...)
+    source VARCHAR(20) NOT NULL DEFAULT 'api',
+    source_id BIGINT NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    INDEX idx_outbound_dispatch (sector_id, status, next_attempt_at)
+);
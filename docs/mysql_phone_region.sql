@@ -0,0 +1,3 @@
+-- Região padrão usada para interpretar números informados sem o código do país
+ALTER TABLE sector_settings
+    ADD COLUMN default_region CHAR(2) NOT NULL DEFAULT 'BR';
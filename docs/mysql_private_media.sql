@@ -0,0 +1,14 @@
+-- Mídias privadas: messages.url e contacts.avatar_url passam a guardar apenas a chave do objeto.
+-- Links públicos antigos continuam funcionando (são convertidos em links temporários na leitura),
+-- mas podem ser migrados para chaves. Ajuste o endereço do bucket antes de executar.
+SET @bucket_url = 'https://ligchat-whatsapp.s3.amazonaws.com/';
+
+UPDATE messages
+SET url = SUBSTRING(url, CHAR_LENGTH(@bucket_url) + 1)
+WHERE url LIKE CONCAT(@bucket_url, '%');
+
+UPDATE contacts
+SET avatar_url = SUBSTRING(avatar_url, CHAR_LENGTH(@bucket_url) + 1)
+WHERE avatar_url LIKE CONCAT(@bucket_url, '%');
+
+-- Depois da migração, remova a leitura pública do bucket (Block Public Access / política do bucket)
@@ -0,0 +1,10 @@
+-- Limites de envio e ritmo "humano" por setor
+ALTER TABLE sector_settings
+    ADD COLUMN sector_rate_per_minute INT NOT NULL DEFAULT 30,
+    ADD COLUMN sector_burst INT NOT NULL DEFAULT 10,
+    ADD COLUMN recipient_rate_per_minute INT NOT NULL DEFAULT 6,
+    ADD COLUMN recipient_burst INT NOT NULL DEFAULT 3,
+    -- Atraso aleatório máximo (ms) antes de cada envio; 0 desativa
+    ADD COLUMN send_jitter_max_ms INT NOT NULL DEFAULT 0,
+    -- Envia "digitando"/"gravando" antes de cada mensagem
+    ADD COLUMN typing_before_send TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+-- O limite por destinatário passa a ser opcional: desativado por padrão e ativado por setor
+ALTER TABLE sector_settings
+    ALTER COLUMN recipient_rate_per_minute SET DEFAULT 0,
+    ALTER COLUMN recipient_burst SET DEFAULT 0;
+
+-- Setores que receberam o limite antigo (6 por minuto, rajada de 3) apenas pelo valor padrão voltam a não ter limite
+UPDATE sector_settings
+SET recipient_rate_per_minute = 0, recipient_burst = 0
+WHERE recipient_rate_per_minute = 6 AND recipient_burst = 3;
@@ -0,0 +1,6 @@
+-- Marcar a conversa como lida automaticamente quando o setor responde
+ALTER TABLE sector_settings
+    ADD COLUMN auto_mark_read_on_reply TINYINT(1) NOT NULL DEFAULT 1;
+
+-- Acelera a busca de mensagens não lidas por conversa
+CREATE INDEX idx_messages_unread ON messages (id_setor, contato_id, enviado, lido);
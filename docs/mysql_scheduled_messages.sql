@@ -0,0 +1,27 @@
+-- Mensagens agendadas por setor
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    sector_id INT NOT NULL,
+    -- text, image, audio ou document
+    kind VARCHAR(20) NOT NULL,
+    recipient VARCHAR(64) NOT NULL,
+    -- Texto da mensagem ou legenda da mídia
+    content TEXT NULL,
+    -- Chave do arquivo no S3 para mensagens de mídia
+    media_key VARCHAR(512) NULL,
+    file_name VARCHAR(255) NULL,
+    user_id INT NULL,
+    is_anonymous TINYINT(1) NOT NULL DEFAULT 0,
+    -- Horário de envio em UTC
+    send_at DATETIME(6) NOT NULL,
+    timezone VARCHAR(64) NOT NULL,
+    -- pending, queued, sent, failed ou canceled
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    outbound_message_id BIGINT NULL,
+    whatsapp_message_id VARCHAR(128) NULL,
+    last_error TEXT NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    INDEX idx_scheduled_due (status, send_at),
+    INDEX idx_scheduled_sector (sector_id, status)
+);
@@ -0,0 +1,10 @@
+-- Configurações de comportamento por setor
+CREATE TABLE IF NOT EXISTS sector_settings (
+    sector_id INT NOT NULL PRIMARY KEY,
+    -- Política para chamadas recebidas: ignore, reject ou reject_message
+    call_policy VARCHAR(20) NOT NULL DEFAULT 'ignore',
+    -- Texto enviado ao contato quando a política é reject_message
+    call_reject_message TEXT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,19 @@
+-- Assinatura configurável do atendente por setor
+ALTER TABLE sector_settings
+    -- Aceita os placeholders de templates, ex: *{{agent.name}}*:
+    ADD COLUMN signature_template VARCHAR(255) NULL DEFAULT '*{{agent.name}}*:',
+    -- prefix, suffix ou none
+    ADD COLUMN signature_placement VARCHAR(20) NOT NULL DEFAULT 'prefix';
+
+-- Assinatura própria de um atendente; valores NULL herdam a configuração do setor
+CREATE TABLE IF NOT EXISTS user_signatures (
+    user_id INT PRIMARY KEY,
+    signature_template VARCHAR(255) NULL,
+    signature_placement VARCHAR(20) NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+-- Texto efetivamente enviado ao WhatsApp (com assinatura); conteudo guarda o texto original
+ALTER TABLE messages
+    ADD COLUMN conteudo_enviado TEXT NULL;
@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
+	"whatsapp-bot/internal/services"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// maxCampaignCSVSize limita o tamanho do CSV de destinatários enviado por upload
+const maxCampaignCSVSize = 5 << 20
+
+// @Summary Create a campaign
+// @Description Create a broadcast campaign in draft state, optionally adding contacts by ID or tag. The message may use template placeholders, resolved per recipient. Media campaigns accept JSON with the file in base64 or multipart/form-data with the file streamed in the file field. Instead of the file, mediaUrl (allowed hosts only) or objectKey (a file sent through /upload) can be informed
+// @Tags campaigns
+// @Accept json,mpfd
+// @Produce json
+// @Param request body models.CampaignRequest false "Campaign details (JSON, arquivo em base64File)"
+// @Param file formData file false "Arquivo (multipart/form-data, demais campos com os nomes do JSON)"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Router /campaigns [post]
+func (h *HTTPHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	// O tipo da campanha vem no próprio corpo: o arquivo é lido com o maior limite e conferido com o do tipo depois
+	var req models.CampaignRequest
+	media, err := h.readMediaBody(w, r, h.maxMediaFileSize(), &req, func(name string, value string) error {
+		return setCampaignField(&req, name, value)
+	})
+	if err != nil {
+		utils.LogError("Erro ao ler requisição /campaigns: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Erro ao ler requisição", err)
+		return
+	}
+	defer func() {
+		if media != nil {
+			media.Close()
+		}
+	}()
+
+	if req.SectorID == 0 || req.Name == "" {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Missing required fields"))
+		return
+	}
+
+	var template *models.MessageTemplate
+	if req.TemplateID != 0 {
+		template, err = h.templateRepository.GetByID(req.TemplateID)
+		if err != nil {
+			models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar template: "+err.Error()))
+			return
+		}
+		if template == nil || template.SectorID != req.SectorID {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Template não encontrado para o setor"))
+			return
+		}
+		req.Kind = template.Kind
+		req.Message = template.Content
+	}
+
+	if req.Kind == "" {
+		req.Kind = models.OutboundKindText
+	}
+	switch req.Kind {
+	case models.OutboundKindText:
+		if req.Message == "" {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O campo message é obrigatório para campanhas de texto"))
+			return
+		}
+	case models.OutboundKindImage, models.OutboundKindAudio, models.OutboundKindDocument:
+		// A mídia do template é usada no lugar do arquivo enviado
+		if template != nil {
+			break
+		}
+		media, err = h.requestMedia(req.SectorID, req.Kind, media, req.MediaURL, req.ObjectKey)
+		if err != nil {
+			if errors.Is(err, errMissingFile) {
+				err = fmt.Errorf("envie o arquivo em base64File ou no campo file, ou informe mediaUrl ou objectKey, para campanhas de mídia")
+			}
+			respondWithError(w, http.StatusBadRequest, "", err)
+			return
+		}
+		if req.FileName == "" {
+			req.FileName = media.fileName
+		}
+	default:
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("kind deve ser text, image, audio ou document"))
+		return
+	}
+
+	if req.RatePerMinute < 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ratePerMinute não pode ser negativo"))
+		return
+	}
+
+	if err := h.connectionManager.CheckSector(req.SectorID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	campaign := &models.Campaign{
+		SectorID:      req.SectorID,
+		Name:          req.Name,
+		Kind:          req.Kind,
+		Content:       req.Message,
+		FileName:      req.FileName,
+		UserID:        req.UserID,
+		IsAnonymous:   req.IsAnonymous,
+		RatePerMinute: req.RatePerMinute,
+	}
+
+	if template != nil {
+		campaign.MediaKey = template.MediaKey
+		campaign.FileName = template.FileName
+	} else if req.Kind != models.OutboundKindText {
+		campaign.MediaKey, err = h.storeUpload(media, services.CampaignMediaKey(req.SectorID, mediaFileName(req.FileName)))
+		if err != nil {
+			utils.LogError("Erro ao guardar mídia em /campaigns: %v", err)
+			models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao guardar arquivo: "+err.Error()))
+			return
+		}
+	}
+
+	if err := h.campaignRepository.Create(campaign); err != nil {
+		utils.LogError("Erro ao criar campanha em /campaigns: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao criar campanha: "+err.Error()))
+		return
+	}
+
+	if _, err := h.addCampaignRecipients(campaign, &models.CampaignRecipientsRequest{ContactIDs: req.ContactIDs, TagID: req.TagID}); err != nil {
+		utils.LogError("Erro ao adicionar destinatários à campanha %d: %v", campaign.ID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Campanha criada, mas houve erro ao adicionar destinatários: "+err.Error()))
+		return
+	}
+
+	campaign.Counts, _ = h.campaignRepository.CountByStatus(campaign.ID)
+	models.RespondWithJSON(w, http.StatusCreated, models.NewSuccessResponse("Campanha criada com sucesso", campaign))
+}
+
+// setCampaignField preenche o campo do formulário multipart correspondente ao campo do JSON.
+// contactIds aceita o campo repetido ou os IDs separados por vírgula
+func setCampaignField(req *models.CampaignRequest, name string, value string) error {
+	var err error
+	switch name {
+	case "sectorId":
+		req.SectorID, err = strconv.Atoi(value)
+	case "name":
+		req.Name = value
+	case "kind":
+		req.Kind = value
+	case "message":
+		req.Message = value
+	case "fileName":
+		req.FileName = value
+	case "mediaUrl":
+		req.MediaURL = value
+	case "objectKey":
+		req.ObjectKey = value
+	case "userId":
+		if value != "" {
+			var userID int
+			userID, err = strconv.Atoi(value)
+			req.UserID = &userID
+		}
+	case "isAnonymous":
+		req.IsAnonymous, err = strconv.ParseBool(value)
+	case "ratePerMinute":
+		req.RatePerMinute, err = strconv.Atoi(value)
+	case "templateId":
+		req.TemplateID, err = strconv.ParseInt(value, 10, 64)
+	case "contactIds":
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			var contactID int
+			if contactID, err = strconv.Atoi(item); err != nil {
+				break
+			}
+			req.ContactIDs = append(req.ContactIDs, contactID)
+		}
+	case "tagId":
+		req.TagID, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		return fmt.Errorf("campo %s inválido: %v", name, err)
+	}
+	return nil
+}
+
+// @Summary Add campaign recipients
+// @Description Add recipients to a campaign by contact IDs, tag or loose numbers (JSON), or by uploading a CSV with number and optional name columns (multipart field "file")
+// @Tags campaigns
+// @Accept json,multipart/form-data
+// @Produce json
+// @Param id path int true "ID da campanha"
+// @Param request body models.CampaignRecipientsRequest false "Recipients"
+// @Param file formData file false "CSV de destinatários"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /campaigns/{id}/recipients [post]
+func (h *HTTPHandler) AddCampaignRecipients(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := h.campaignFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if campaign.Status == models.CampaignStatusCanceled || campaign.Status == models.CampaignStatusCompleted {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Não é possível adicionar destinatários a uma campanha encerrada"))
+		return
+	}
+
+	var req models.CampaignRecipientsRequest
+	var csvRecipients []*models.CampaignRecipient
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxCampaignCSVSize); err != nil {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao processar formulário: "+err.Error()))
+			return
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Arquivo CSV não encontrado no campo file"))
+			return
+		}
+		defer file.Close()
+
+		csvRecipients, err = parseRecipientsCSV(io.LimitReader(file, maxCampaignCSVSize), campaign.SectorID)
+		if err != nil {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao ler CSV: "+err.Error()))
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	for _, number := range req.Numbers {
+		if normalized, ok := normalizeRecipientNumber(campaign.SectorID, number); ok {
+			csvRecipients = append(csvRecipients, &models.CampaignRecipient{Recipient: normalized})
+		}
+	}
+
+	added, err := h.addCampaignRecipients(campaign, &req)
+	if err == nil && len(csvRecipients) > 0 {
+		var loose int
+		loose, err = h.campaignRepository.AddRecipients(campaign.ID, csvRecipients)
+		added += loose
+	}
+	if err != nil {
+		utils.LogError("Erro ao adicionar destinatários à campanha %d: %v", campaign.ID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao adicionar destinatários: "+err.Error()))
+		return
+	}
+
+	counts, _ := h.campaignRepository.CountByStatus(campaign.ID)
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Destinatários adicionados", map[string]interface{}{
+		"added":  added,
+		"counts": counts,
+	}))
+}
+
+// @Summary List campaigns
+// @Description List the campaigns of a sector with per-status recipient counts
+// @Tags campaigns
+// @Produce json
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Param limit query int false "Quantidade máxima de campanhas (padrão 50)"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /campaigns [get]
+func (h *HTTPHandler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	campaigns, err := h.campaignRepository.ListBySector(sectorID, limit)
+	if err != nil {
+		utils.LogError("Erro ao listar campanhas em /campaigns: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao listar campanhas: "+err.Error()))
+		return
+	}
+	if campaigns == nil {
+		campaigns = []*models.Campaign{}
+	}
+
+	for _, campaign := range campaigns {
+		campaign.Counts, _ = h.campaignRepository.CountByStatus(campaign.ID)
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Campanhas do setor", campaigns))
+}
+
+// @Summary Get a campaign
+// @Description Get a campaign with per-status recipient counts
+// @Tags campaigns
+// @Produce json
+// @Param id path int true "ID da campanha"
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /campaigns/{id} [get]
+func (h *HTTPHandler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := h.campaignFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	campaign.Counts, _ = h.campaignRepository.CountByStatus(campaign.ID)
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Campanha", campaign))
+}
+
+// @Summary List campaign recipients
+// @Description List the recipients of a campaign, optionally filtered by status (queued, sending, sent, delivered, read, failed, canceled)
+// @Tags campaigns
+// @Produce json
+// @Param id path int true "ID da campanha"
+// @Param status query string false "Status do destinatário"
+// @Param limit query int false "Quantidade máxima de destinatários (padrão 500)"
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /campaigns/{id}/recipients [get]
+func (h *HTTPHandler) ListCampaignRecipients(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := h.campaignFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 500
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 5000 {
+			limit = parsed
+		}
+	}
+
+	recipients, err := h.campaignRepository.ListRecipients(campaign.ID, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		utils.LogError("Erro ao listar destinatários da campanha %d: %v", campaign.ID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao listar destinatários: "+err.Error()))
+		return
+	}
+	if recipients == nil {
+		recipients = []*models.CampaignRecipient{}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Destinatários da campanha", recipients))
+}
+
+// @Summary Start, pause, resume or cancel a campaign
+// @Description Change the state of a campaign. Pausing stops dispatching new recipients; canceling also cancels recipients not yet dispatched
+// @Tags campaigns
+// @Produce json
+// @Param id path int true "ID da campanha"
+// @Param action path string true "start, pause, resume ou cancel"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /campaigns/{id}/{action} [post]
+func (h *HTTPHandler) ChangeCampaignStatus(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := h.campaignFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var updated *models.Campaign
+	var err error
+
+	switch mux.Vars(r)["action"] {
+	case "start":
+		counts, countErr := h.campaignRepository.CountByStatus(campaign.ID)
+		if countErr == nil && counts[models.CampaignRecipientQueued] == 0 {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("A campanha não possui destinatários"))
+			return
+		}
+		updated, err = h.connectionManager.StartCampaign(campaign.ID)
+	case "pause":
+		updated, err = h.connectionManager.PauseCampaign(campaign.ID)
+	case "resume":
+		updated, err = h.connectionManager.ResumeCampaign(campaign.ID)
+	case "cancel":
+		updated, err = h.connectionManager.CancelCampaign(campaign.ID)
+	default:
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Ação inválida"))
+		return
+	}
+
+	if err != nil {
+		utils.LogError("Erro ao alterar estado da campanha %d: %v", campaign.ID, err)
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Não foi possível alterar a campanha: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Campanha atualizada", updated))
+}
+
+func (h *HTTPHandler) campaignFromRequest(w http.ResponseWriter, r *http.Request) (*models.Campaign, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID da campanha inválido"))
+		return nil, false
+	}
+
+	campaign, err := h.campaignRepository.GetByID(id)
+	if err != nil {
+		utils.LogError("Erro ao buscar campanha %d: %v", id, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar campanha: "+err.Error()))
+		return nil, false
+	}
+	if campaign == nil {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Campanha não encontrada"))
+		return nil, false
+	}
+
+	return campaign, true
+}
+
+// addCampaignRecipients adiciona à campanha os contatos do setor selecionados por ID ou etiqueta
+func (h *HTTPHandler) addCampaignRecipients(campaign *models.Campaign, req *models.CampaignRecipientsRequest) (int, error) {
+	added, err := h.campaignRepository.AddContactRecipients(campaign, req.ContactIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	if req.TagID > 0 {
+		byTag, err := h.campaignRepository.AddTagRecipients(campaign, req.TagID)
+		if err != nil {
+			return added, err
+		}
+		added += byTag
+	}
+
+	return added, nil
+}
+
+// parseRecipientsCSV lê um CSV com colunas de número e nome. Se a primeira linha tiver um cabeçalho
+// reconhecido (number/numero/telefone/phone e name/nome), as colunas são localizadas por ele;
+// caso contrário, a primeira coluna é o número e a segunda, o nome
+func parseRecipientsCSV(reader io.Reader, sectorID int) ([]*models.CampaignRecipient, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("arquivo vazio")
+	}
+
+	numberColumn, nameColumn := 0, 1
+	header := records[0]
+	headerFound := false
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "number", "numero", "número", "telefone", "phone", "celular":
+			numberColumn = i
+			headerFound = true
+		case "name", "nome":
+			nameColumn = i
+		}
+	}
+	if headerFound {
+		records = records[1:]
+	}
+
+	var recipients []*models.CampaignRecipient
+	for _, record := range records {
+		if numberColumn >= len(record) {
+			continue
+		}
+
+		number, ok := normalizeRecipientNumber(sectorID, record[numberColumn])
+		if !ok {
+			continue
+		}
+
+		recipient := &models.CampaignRecipient{Recipient: number}
+		if nameColumn < len(record) && nameColumn != numberColumn {
+			recipient.Name = strings.TrimSpace(record[nameColumn])
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// normalizeRecipientNumber remove a formatação do número usando a região padrão do setor
+func normalizeRecipientNumber(sectorID int, raw string) (string, bool) {
+	number, err := phone.ForSector(sectorID, raw)
+	if err != nil {
+		return "", false
+	}
+	return number.Digits(), true
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"whatsapp-bot/internal/mediaurl"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+// @Summary List duplicate contacts
+// @Description List groups of contacts of a sector that share the same normalized phone number, with a suggested survivor for each group
+// @Tags contacts
+// @Produce json
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /contacts/duplicates [get]
+func (h *HTTPHandler) ListDuplicateContacts(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	groups, err := h.contactRepository.FindDuplicates(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar contatos duplicados do setor %d: %v", sectorID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar contatos duplicados: "+err.Error()))
+		return
+	}
+	if groups == nil {
+		groups = []*models.DuplicateContactGroup{}
+	}
+
+	for _, group := range groups {
+		signContactAvatars(group.Contacts...)
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Contatos duplicados", groups))
+}
+
+// @Summary Merge contacts
+// @Description Merge duplicate contacts into a surviving contact. Messages, card and campaign recipients are moved to the survivor, empty fields are filled from the duplicates, notes are combined and the duplicates are removed. Every duplicate must share the normalized number or the LID of the survivor
+// @Tags contacts
+// @Accept json
+// @Produce json
+// @Param request body models.MergeContactsRequest true "Merge details"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /contacts/merge [post]
+func (h *HTTPHandler) MergeContacts(w http.ResponseWriter, r *http.Request) {
+	var req models.MergeContactsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	if req.SectorID == 0 || req.SurvivorID == 0 || len(req.DuplicateIDs) == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("sector_id, survivor_id e duplicate_ids são obrigatórios"))
+		return
+	}
+
+	contact, err := h.contactRepository.Merge(req.SectorID, req.SurvivorID, req.DuplicateIDs)
+	if err != nil {
+		utils.LogError("Erro ao mesclar contatos no setor %d: %v", req.SectorID, err)
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao mesclar contatos: "+err.Error()))
+		return
+	}
+
+	signContactAvatars(contact)
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Contatos mesclados com sucesso", contact))
+}
+
+// signContactAvatars troca a chave do avatar gravada no banco por um link temporário e preenche o endereço estável
+func signContactAvatars(contacts ...*models.Contact) {
+	for _, contact := range contacts {
+		if contact == nil {
+			continue
+		}
+		if mediaurl.Key(contact.AvatarURL) != "" {
+			contact.AvatarPath = mediaurl.ContactAvatarPath(contact.SectorID, contact.ID)
+		}
+		contact.AvatarURL = mediaurl.Sign(contact.AvatarURL)
+	}
+}
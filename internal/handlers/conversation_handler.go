@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Mark conversation as read
+// @Description Mark every incoming message of a contact as read, sending batched read receipts to WhatsApp when the sector is connected
+// @Tags contacts
+// @Produce json
+// @Param contactId path int true "ID do contato"
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /conversations/{contactId}/read [post]
+func (h *HTTPHandler) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	contactID, err := strconv.Atoi(mux.Vars(r)["contactId"])
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID do contato inválido"))
+		return
+	}
+
+	contact, err := h.contactRepository.GetByID(sectorID, contactID)
+	if err != nil {
+		utils.LogError("Erro ao buscar contato %d em /conversations/read: %v", contactID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar contato: "+err.Error()))
+		return
+	}
+	if contact == nil {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Contato não encontrado"))
+		return
+	}
+
+	marked, receiptSent, err := h.connectionManager.MarkConversationRead(sectorID, contactID)
+	if err != nil {
+		utils.LogError("Erro ao marcar conversa %d como lida: %v", contactID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao marcar conversa como lida: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Conversa marcada como lida", map[string]interface{}{
+		"contactId":   contactID,
+		"markedCount": marked,
+		"receiptSent": receiptSent,
+	}))
+}
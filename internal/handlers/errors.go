@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/services"
+)
+
+// Status HTTP correspondente a cada código de erro dos serviços
+var serviceErrorStatus = map[string]int{
+	services.CodeNotConnected:     http.StatusServiceUnavailable,
+	services.CodeInvalidRequest:   http.StatusBadRequest,
+	services.CodeInvalidRecipient: http.StatusBadRequest,
+	services.CodeNotOnWhatsApp:    http.StatusUnprocessableEntity,
+	services.CodeRateLimited:      http.StatusTooManyRequests,
+	services.CodeMediaTooLarge:    http.StatusRequestEntityTooLarge,
+	services.CodeSectorNotFound:   http.StatusNotFound,
+	services.CodeOfficialSector:   http.StatusConflict,
+	services.CodeUpstream:         http.StatusBadGateway,
+}
+
+// respondWithError responde com o status e o código do erro de serviço, ou com fallbackStatus
+// quando o erro não é classificado. O prefixo é adicionado à mensagem quando informado
+func respondWithError(w http.ResponseWriter, fallbackStatus int, prefix string, err error) {
+	message := err.Error()
+	if prefix != "" {
+		message = prefix + ": " + message
+	}
+
+	var serviceErr *services.Error
+	if errors.As(err, &serviceErr) {
+		status, exists := serviceErrorStatus[serviceErr.Code]
+		if !exists {
+			status = fallbackStatus
+		}
+		models.RespondWithJSON(w, status, models.NewErrorResponseWithCode(serviceErr.Code, message))
+		return
+	}
+
+	models.RespondWithJSON(w, fallbackStatus, models.NewErrorResponse(message))
+}
@@ -1,16 +1,18 @@
 package handlers
 
 import (
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	"whatsapp-bot/config"
+	"whatsapp-bot/internal/mediaurl"
 	"whatsapp-bot/internal/models"
 	"whatsapp-bot/internal/repositories"
 	"whatsapp-bot/internal/services"
@@ -18,21 +20,36 @@ import (
 )
 
 type HTTPHandler struct {
+	config            *config.Config
 	connectionManager *services.ConnectionManager
-	s3Service         *services.S3Service
+	mediaStore        services.MediaStore
 	contactRepository *repositories.MySQLContactRepository
+	messageRepository *repositories.MySQLMessageRepository
+
+	sectorSettingsRepository *repositories.MySQLSectorSettingsRepository
+	outboundRepository       *repositories.MySQLOutboundMessageRepository
+	idempotencyRepository    *repositories.MySQLIdempotencyRepository
+	scheduledRepository      *repositories.MySQLScheduledMessageRepository
+	campaignRepository       *repositories.MySQLCampaignRepository
+	templateRepository       *repositories.MySQLMessageTemplateRepository
+	userSignatureRepository  *repositories.MySQLUserSignatureRepository
 }
 
-func NewHTTPHandler(manager *services.ConnectionManager) *HTTPHandler {
-	s3Service, err := services.NewS3Service(config.NewConfig().S3Config)
-	if err != nil {
-		utils.LogError("Erro ao criar serviço S3: %v", err)
-	}
-
+func NewHTTPHandler(manager *services.ConnectionManager, cfg *config.Config) *HTTPHandler {
 	return &HTTPHandler{
+		config:            cfg,
 		connectionManager: manager,
-		s3Service:         s3Service,
+		mediaStore:        manager.MediaStore(),
 		contactRepository: repositories.NewMySQLContactRepository(manager.GetDB()),
+		messageRepository: repositories.NewMySQLMessageRepository(manager.GetDB()),
+
+		sectorSettingsRepository: repositories.NewMySQLSectorSettingsRepository(manager.GetDB()),
+		outboundRepository:       repositories.NewMySQLOutboundMessageRepository(manager.GetDB()),
+		idempotencyRepository:    repositories.NewMySQLIdempotencyRepository(manager.GetDB()),
+		scheduledRepository:      repositories.NewMySQLScheduledMessageRepository(manager.GetDB()),
+		campaignRepository:       repositories.NewMySQLCampaignRepository(manager.GetDB()),
+		templateRepository:       repositories.NewMySQLMessageTemplateRepository(manager.GetDB()),
+		userSignatureRepository:  repositories.NewMySQLUserSignatureRepository(manager.GetDB()),
 	}
 }
 
@@ -42,56 +59,132 @@ func NewHTTPHandler(manager *services.ConnectionManager) *HTTPHandler {
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
+// @Param sectorId formData int false "Sector that can reuse the file by objectKey"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Router /upload [post]
 func (h *HTTPHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
-	if h.s3Service == nil {
-		utils.LogError("Serviço S3 não está disponível em /upload")
+	if h.mediaStore == nil {
+		utils.LogError("Armazenamento de mídia não está disponível em /upload")
 		models.RespondWithJSON(w, http.StatusInternalServerError,
-			models.NewErrorResponse("Serviço S3 não está disponível"))
+			models.NewErrorResponse("Armazenamento de mídia não está disponível"))
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		utils.LogError("Arquivo muito grande em /upload: %v", err)
+	maxUploadSize := h.config.Media.MaxUploadSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.LogError("Erro ao processar formulário em /upload: %v", err)
 		models.RespondWithJSON(w, http.StatusBadRequest,
-			models.NewErrorResponse("Arquivo muito grande. Limite de 10MB"))
+			models.NewErrorResponse("Erro ao processar arquivo"))
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		utils.LogError("Erro ao processar arquivo em /upload: %v", err)
+	// O arquivo é copiado em stream para um temporário, sem ser carregado inteiro na memória.
+	// O setor pode vir antes ou depois do arquivo no formulário
+	var media *incomingMedia
+	defer func() {
+		if media != nil {
+			media.Close()
+		}
+	}()
+	sectorID := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			utils.LogError("Erro ao processar arquivo em /upload: %v", err)
+			models.RespondWithJSON(w, http.StatusBadRequest,
+				models.NewErrorResponse("Erro ao processar arquivo"))
+			return
+		}
+
+		switch part.FormName() {
+		case multipartFileField:
+			if media != nil {
+				part.Close()
+				models.RespondWithJSON(w, http.StatusBadRequest,
+					models.NewErrorResponse("Envie apenas um arquivo por requisição"))
+				return
+			}
+			media, err = spoolMedia(part, maxUploadSize)
+			part.Close()
+			if errors.Is(err, services.ErrMediaTooLarge) {
+				utils.LogError("Arquivo muito grande em /upload: %v", err)
+				models.RespondWithJSON(w, http.StatusBadRequest,
+					models.NewErrorResponse(fmt.Sprintf("Arquivo muito grande. Limite de %dMB", maxUploadSize>>20)))
+				return
+			}
+			if err != nil {
+				utils.LogError("Erro ao ler arquivo em /upload: %v", err)
+				models.RespondWithJSON(w, http.StatusBadRequest,
+					models.NewErrorResponse("Erro ao processar arquivo"))
+				return
+			}
+			media.fileName = part.FileName()
+		case "sectorId", "sector_id":
+			value, err := io.ReadAll(io.LimitReader(part, multipartFieldLimit))
+			part.Close()
+			if err == nil {
+				sectorID, err = strconv.Atoi(strings.TrimSpace(string(value)))
+			}
+			if err != nil {
+				models.RespondWithJSON(w, http.StatusBadRequest,
+					models.NewErrorResponse("Invalid sectorId"))
+				return
+			}
+		default:
+			part.Close()
+		}
+	}
+	if media == nil {
+		utils.LogError("Erro ao processar arquivo em /upload: %v", errMissingFile)
 		models.RespondWithJSON(w, http.StatusBadRequest,
 			models.NewErrorResponse("Erro ao processar arquivo"))
 		return
 	}
-	defer file.Close()
 
-	fileUrl, err := h.s3Service.UploadFile(file, handler)
-	if err != nil {
+	// Gerar nome único para o arquivo. Com o setor informado, o arquivo fica na pasta do setor e pode
+	// ser reaproveitado em envios pelo objectKey; sem ele, serve apenas pelo link temporário
+	fileName := fmt.Sprintf("%d%s", time.Now().UnixNano(), filepath.Ext(media.fileName))
+	if sectorID > 0 {
+		if err := h.connectionManager.CheckSector(sectorID); err != nil {
+			utils.LogError("Erro ao verificar setor no /upload: %v", err)
+			respondWithError(w, http.StatusBadRequest, "", err)
+			return
+		}
+		fileName = services.UploadMediaKey(sectorID, fileName)
+	}
+
+	if err := h.mediaStore.PutStream(fileName, media.file, media.size, media.contentType); err != nil {
 		utils.LogError("Erro ao fazer upload em /upload: %v", err)
 		models.RespondWithJSON(w, http.StatusInternalServerError,
 			models.NewErrorResponse(fmt.Sprintf("Erro ao fazer upload: %v", err)))
 		return
 	}
 
+	// O arquivo é privado: o cliente recebe a chave e um link temporário
 	response := map[string]string{
-		"path": fileUrl,
+		"key":  fileName,
+		"path": mediaurl.Sign(fileName),
 	}
 	models.RespondWithJSON(w, http.StatusOK,
 		models.NewSuccessResponse("Arquivo enviado com sucesso", response))
 }
 
 // @Summary Send a text message
-// @Description Send a text message to a WhatsApp contact
+// @Description Send a text message to a WhatsApp contact, either as raw text or rendered from a sector template
 // @Tags messages
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Chave para evitar envios duplicados em repetições da requisição"
 // @Param request body models.MessageRequest true "Message details"
-// @Success 200 {object} map[string]string
+// @Success 202 {object} models.APIResponse
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} models.APIResponse
 // @Router /send-message [post]
 func (h *HTTPHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	var req models.MessageRequest
@@ -101,19 +194,14 @@ func (h *HTTPHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validar campos obrigatórios
-	if req.SectorID == 0 || req.Recipient == "" || req.Message == "" {
+	if req.SectorID == 0 || req.Recipient == "" || (req.Message == "" && req.TemplateID == 0) {
 		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Missing required fields"))
 		return
 	}
 
-	if req.Timezone == "" {
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Missing timezone field"))
-		return
-	}
-
-	loc, err := time.LoadLocation(req.Timezone)
+	loc, err := loadTimezone(req.Timezone)
 	if err != nil {
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Invalid timezone"))
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
 		return
 	}
 
@@ -121,185 +209,255 @@ func (h *HTTPHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	sentAtInLoc := req.SentAt.In(loc)
 	sentAtUTC := sentAtInLoc.UTC()
 
-	// Obter conexão do setor
-	connection, err := h.connectionManager.GetConnection(req.SectorID)
-	if err != nil {
-		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Error getting connection"))
+	if !h.checkRateLimit(w, req.SectorID, req.Recipient) {
 		return
 	}
 
-	// Enviar mensagem
-	err = connection.SendMessage(req.SectorID, req.Recipient, req.Message, req.UserID, req.IsAnonymous, sentAtUTC)
-	if err != nil {
-		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse(err.Error()))
+	// Enfileirar a mensagem para o despachante do setor
+	message := &models.OutboundMessage{
+		SectorID:    req.SectorID,
+		Kind:        models.OutboundKindText,
+		Recipient:   req.Recipient,
+		Content:     req.Message,
+		UserID:      req.UserID,
+		IsAnonymous: req.IsAnonymous,
+		SentAt:      sentAtUTC,
+	}
+
+	if req.TemplateID != 0 {
+		template, err := h.templateRepository.GetByID(req.TemplateID)
+		if err != nil {
+			models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar template: "+err.Error()))
+			return
+		}
+		if template == nil || template.SectorID != req.SectorID {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Template não encontrado para o setor"))
+			return
+		}
+
+		data := h.connectionManager.TemplateData(req.SectorID, req.Recipient, req.UserID, req.Variables, loc)
+		message.Kind = template.Kind
+		message.Content = services.RenderTemplate(template.Content, data)
+		message.MediaKey = template.MediaKey
+		message.FileName = template.FileName
+	}
+	if err := h.connectionManager.EnqueueOutbound(message); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "", err)
 		return
 	}
 
 	// Retornar o sentAt convertido para UTC, em formato ISO UTC (RFC3339Nano)
 	sentAtStr := sentAtUTC.Format(time.RFC3339Nano)
-	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Message sent successfully", map[string]interface{}{
-		"sentAt": sentAtStr,
+	models.RespondWithJSON(w, http.StatusAccepted, models.NewSuccessResponse("Message queued successfully", map[string]interface{}{
+		"sentAt":          sentAtStr,
+		"queuedMessageId": message.ID,
+		"status":          message.Status,
 	}))
 }
 
 // @Summary Send an image
-// @Description Send an image with optional caption to a WhatsApp contact
+// @Description Send an image with optional caption to a WhatsApp contact. Accepts JSON with the file in base64 or multipart/form-data with the file streamed in the file field. Instead of the file, mediaUrl (allowed hosts only) or objectKey (a file sent through /upload) can be informed
 // @Tags messages
-// @Accept json
+// @Accept json,mpfd
 // @Produce json
-// @Param request body models.MediaMessageRequest true "Image message details"
-// @Success 200 {object} map[string]string
+// @Param Idempotency-Key header string false "Chave para evitar envios duplicados em repetições da requisição"
+// @Param request body models.MediaMessageRequest false "Image message details (JSON, arquivo em base64File)"
+// @Param file formData file false "Arquivo (multipart/form-data, demais campos com os nomes do JSON)"
+// @Success 202 {object} models.APIResponse
 // @Failure 400 {object} map[string]string
+// @Failure 413 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
 // @Router /send-image [post]
 func (h *HTTPHandler) SendImage(w http.ResponseWriter, r *http.Request) {
-	var req models.MediaMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogError("Erro ao decodificar requisição /send-image: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+	req, media, err := h.readMediaRequest(w, r, models.OutboundKindImage)
+	if err != nil {
+		utils.LogError("Erro ao ler requisição /send-image: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Erro ao ler requisição", err)
 		return
 	}
+	defer media.Close()
 
-	service, err := h.connectionManager.GetConnection(req.SectorID)
-	if err != nil {
-		utils.LogError("Erro ao obter conexão no /send-image: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+	if err := h.connectionManager.CheckSector(req.SectorID); err != nil {
+		utils.LogError("Erro ao verificar setor no /send-image: %v", err)
+		respondWithError(w, http.StatusBadRequest, "", err)
 		return
 	}
 
-	base64Data := req.Base64File
-	if i := strings.Index(base64Data, ";base64,"); i > -1 {
-		base64Data = base64Data[i+8:]
+	if !h.checkRateLimit(w, req.SectorID, req.Recipient) {
+		return
 	}
 
-	imageBytes, err := base64.StdEncoding.DecodeString(base64Data)
+	mediaKey, err := h.storeOutboundUpload(req.SectorID, media, req.FileName)
 	if err != nil {
-		utils.LogError("Erro ao decodificar base64 em /send-image: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar base64: "+err.Error()))
+		utils.LogError("Erro ao guardar mídia em /send-image: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao guardar imagem: "+err.Error()))
 		return
 	}
 
-	err = service.SendImage(req.SectorID, req.Recipient, imageBytes, req.Caption, req.UserID, req.IsAnonymous, req.SentAt)
-	if err != nil {
-		utils.LogError("Erro ao enviar imagem em /send-image: %v", err)
-		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao enviar imagem: "+err.Error()))
+	message := &models.OutboundMessage{
+		SectorID:    req.SectorID,
+		Kind:        models.OutboundKindImage,
+		Recipient:   req.Recipient,
+		Content:     req.Caption,
+		MediaKey:    mediaKey,
+		FileName:    req.FileName,
+		UserID:      req.UserID,
+		IsAnonymous: req.IsAnonymous,
+		SentAt:      req.SentAt,
+	}
+	if err := h.connectionManager.EnqueueOutbound(message); err != nil {
+		utils.LogError("Erro ao enfileirar imagem em /send-image: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao enfileirar imagem", err)
 		return
 	}
 
 	data := map[string]interface{}{
-		"recipient": req.Recipient,
-		"fileName":  req.FileName,
-		"mediaType": req.MediaType,
+		"queuedMessageId": message.ID,
+		"status":          message.Status,
+		"recipient":       req.Recipient,
+		"fileName":        req.FileName,
+		"mediaType":       req.MediaType,
 	}
-	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Imagem enviada com sucesso", data))
+	models.RespondWithJSON(w, http.StatusAccepted, models.NewSuccessResponse("Imagem enfileirada para envio", data))
 }
 
 // @Summary Send an audio file
-// @Description Send an audio file to a WhatsApp contact
+// @Description Send an audio file to a WhatsApp contact. Accepts JSON with the file in base64 or multipart/form-data with the file streamed in the file field. Instead of the file, mediaUrl (allowed hosts only) or objectKey (a file sent through /upload) can be informed
 // @Tags messages
-// @Accept json
+// @Accept json,mpfd
 // @Produce json
-// @Param request body models.MediaMessageRequest true "Audio message details"
-// @Success 200 {object} map[string]string
+// @Param Idempotency-Key header string false "Chave para evitar envios duplicados em repetições da requisição"
+// @Param request body models.MediaMessageRequest false "Audio message details (JSON, arquivo em base64File)"
+// @Param file formData file false "Arquivo (multipart/form-data, demais campos com os nomes do JSON)"
+// @Success 202 {object} models.APIResponse
 // @Failure 400 {object} map[string]string
+// @Failure 413 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
 // @Router /send-audio [post]
 func (h *HTTPHandler) SendAudio(w http.ResponseWriter, r *http.Request) {
-	var req models.MediaMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogError("Erro ao decodificar requisição /send-audio: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+	req, media, err := h.readMediaRequest(w, r, models.OutboundKindAudio)
+	if err != nil {
+		utils.LogError("Erro ao ler requisição /send-audio: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Erro ao ler requisição", err)
 		return
 	}
+	defer media.Close()
 
-	service, err := h.connectionManager.GetConnection(req.SectorID)
-	if err != nil {
-		utils.LogError("Erro ao obter conexão no /send-audio: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+	if err := h.connectionManager.CheckSector(req.SectorID); err != nil {
+		utils.LogError("Erro ao verificar setor no /send-audio: %v", err)
+		respondWithError(w, http.StatusBadRequest, "", err)
 		return
 	}
 
-	base64Data := req.Base64File
-	if i := strings.Index(base64Data, ";base64,"); i > -1 {
-		base64Data = base64Data[i+8:]
+	if !h.checkRateLimit(w, req.SectorID, req.Recipient) {
+		return
 	}
 
-	audioBytes, err := base64.StdEncoding.DecodeString(base64Data)
+	mediaKey, err := h.storeOutboundUpload(req.SectorID, media, req.FileName)
 	if err != nil {
-		utils.LogError("Erro ao decodificar base64 em /send-audio: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar base64: "+err.Error()))
+		utils.LogError("Erro ao guardar mídia em /send-audio: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao guardar áudio: "+err.Error()))
 		return
 	}
 
-	err = service.SendAudio(req.SectorID, req.Recipient, audioBytes, req.UserID, req.IsAnonymous, req.SentAt)
-	if err != nil {
-		utils.LogError("Erro ao enviar áudio em /send-audio: %v", err)
-		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao enviar áudio: "+err.Error()))
+	message := &models.OutboundMessage{
+		SectorID:    req.SectorID,
+		Kind:        models.OutboundKindAudio,
+		Recipient:   req.Recipient,
+		Content:     "",
+		MediaKey:    mediaKey,
+		FileName:    req.FileName,
+		UserID:      req.UserID,
+		IsAnonymous: req.IsAnonymous,
+		SentAt:      req.SentAt,
+	}
+	if err := h.connectionManager.EnqueueOutbound(message); err != nil {
+		utils.LogError("Erro ao enfileirar áudio em /send-audio: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao enfileirar áudio", err)
 		return
 	}
 
 	data := map[string]interface{}{
-		"recipient": req.Recipient,
-		"fileName":  req.FileName,
-		"mediaType": req.MediaType,
+		"queuedMessageId": message.ID,
+		"status":          message.Status,
+		"recipient":       req.Recipient,
+		"fileName":        req.FileName,
+		"mediaType":       req.MediaType,
 	}
-	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Áudio enviado com sucesso", data))
+	models.RespondWithJSON(w, http.StatusAccepted, models.NewSuccessResponse("Áudio enfileirado para envio", data))
 }
 
 // @Summary Send a document
-// @Description Send a document file to a WhatsApp contact
+// @Description Send a document file to a WhatsApp contact. Accepts JSON with the file in base64 or multipart/form-data with the file streamed in the file field. Instead of the file, mediaUrl (allowed hosts only) or objectKey (a file sent through /upload) can be informed
 // @Tags messages
-// @Accept json
+// @Accept json,mpfd
 // @Produce json
-// @Param request body models.MediaMessageRequest true "Document message details"
-// @Success 200 {object} map[string]string
+// @Param Idempotency-Key header string false "Chave para evitar envios duplicados em repetições da requisição"
+// @Param request body models.MediaMessageRequest false "Document message details (JSON, arquivo em base64File)"
+// @Param file formData file false "Arquivo (multipart/form-data, demais campos com os nomes do JSON)"
+// @Success 202 {object} models.APIResponse
 // @Failure 400 {object} map[string]string
+// @Failure 413 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
 // @Router /send-document [post]
 func (h *HTTPHandler) SendDocument(w http.ResponseWriter, r *http.Request) {
-	var req models.MediaMessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogError("Erro ao decodificar requisição /send-document: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+	req, media, err := h.readMediaRequest(w, r, models.OutboundKindDocument)
+	if err != nil {
+		utils.LogError("Erro ao ler requisição /send-document: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Erro ao ler requisição", err)
 		return
 	}
+	defer media.Close()
 
-	service, err := h.connectionManager.GetConnection(req.SectorID)
-	if err != nil {
-		utils.LogError("Erro ao obter conexão no /send-document: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+	if err := h.connectionManager.CheckSector(req.SectorID); err != nil {
+		utils.LogError("Erro ao verificar setor no /send-document: %v", err)
+		respondWithError(w, http.StatusBadRequest, "", err)
 		return
 	}
 
-	base64Data := req.Base64File
-	if i := strings.Index(base64Data, ";base64,"); i > -1 {
-		base64Data = base64Data[i+8:]
+	if !h.checkRateLimit(w, req.SectorID, req.Recipient) {
+		return
 	}
 
-	fileBytes, err := base64.StdEncoding.DecodeString(base64Data)
+	utils.LogInfo("Enviando documento: %s (%d bytes)", req.FileName, media.size)
+
+	mediaKey, err := h.storeOutboundUpload(req.SectorID, media, req.FileName)
 	if err != nil {
-		utils.LogError("Erro ao decodificar base64 em /send-document: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar base64: "+err.Error()))
+		utils.LogError("Erro ao guardar mídia em /send-document: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao guardar documento: "+err.Error()))
 		return
 	}
 
-	utils.LogInfo("Enviando documento: %s (%d bytes)", req.FileName, len(fileBytes))
-
-	err = service.SendDocument(req.SectorID, req.Recipient, fileBytes, req.FileName, req.UserID, req.IsAnonymous, req.SentAt)
-	if err != nil {
-		utils.LogError("Erro ao enviar documento em /send-document: %v", err)
-		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao enviar documento: "+err.Error()))
+	message := &models.OutboundMessage{
+		SectorID:    req.SectorID,
+		Kind:        models.OutboundKindDocument,
+		Recipient:   req.Recipient,
+		Content:     req.Caption,
+		MediaKey:    mediaKey,
+		FileName:    req.FileName,
+		UserID:      req.UserID,
+		IsAnonymous: req.IsAnonymous,
+		SentAt:      req.SentAt,
+	}
+	if err := h.connectionManager.EnqueueOutbound(message); err != nil {
+		utils.LogError("Erro ao enfileirar documento em /send-document: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao enfileirar documento", err)
 		return
 	}
 
 	data := map[string]interface{}{
-		"recipient": req.Recipient,
-		"fileName":  req.FileName,
-		"mediaType": req.MediaType,
-		"size":      len(fileBytes),
-	}
-	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Documento enviado com sucesso", data))
+		"queuedMessageId": message.ID,
+		"status":          message.Status,
+		"recipient":       req.Recipient,
+		"fileName":        req.FileName,
+		"mediaType":       req.MediaType,
+		"size":            media.size,
+	}
+	models.RespondWithJSON(w, http.StatusAccepted, models.NewSuccessResponse("Documento enfileirado para envio", data))
 }
 
 // @Summary Send typing indication
-// @Description Send a typing indication to a WhatsApp contact with specified duration
+// @Description Start a typing indication to a WhatsApp contact. Returns immediately; the indication is cleared after duration seconds
 // @Tags messages
 // @Accept json
 // @Produce json
@@ -318,14 +476,14 @@ func (h *HTTPHandler) SendTyping(w http.ResponseWriter, r *http.Request) {
 	service, err := h.connectionManager.GetConnection(req.SectorID)
 	if err != nil {
 		utils.LogError("Erro ao obter conexão no /send-typing: %v", err)
-		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+		respondWithError(w, http.StatusBadRequest, "", err)
 		return
 	}
 
 	err = service.SendTyping(req.Recipient, req.Duration)
 	if err != nil {
 		utils.LogError("Erro ao enviar status de digitação em /send-typing: %v", err)
-		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao enviar status de digitação: "+err.Error()))
+		respondWithError(w, http.StatusInternalServerError, "Erro ao enviar status de digitação", err)
 		return
 	}
 
@@ -336,6 +494,41 @@ func (h *HTTPHandler) SendTyping(w http.ResponseWriter, r *http.Request) {
 	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Status de digitação enviado com sucesso", data))
 }
 
+// @Summary Set chat presence
+// @Description Start (composing, recording) or stop (paused) the sector presence in a conversation without holding the request open. Active states fall back to paused after duration seconds unless renewed
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param request body models.PresenceRequest true "Presence details"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /presence [post]
+func (h *HTTPHandler) SetChatPresence(w http.ResponseWriter, r *http.Request) {
+	var req models.PresenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.LogError("Erro ao decodificar requisição /presence: %v", err)
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	if !models.IsValidPresenceState(req.State) {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("state deve ser composing, recording ou paused"))
+		return
+	}
+
+	if err := h.connectionManager.SetChatPresence(req.SectorID, req.Recipient, req.State, req.Duration); err != nil {
+		utils.LogError("Erro ao enviar presença em /presence: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao enviar presença", err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"recipient": req.Recipient,
+		"state":     req.State,
+	}
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Presença atualizada com sucesso", data))
+}
+
 // @Summary Get QR Code
 // @Description Get QR code as PNG image for WhatsApp login
 // @Tags authentication
@@ -544,36 +737,54 @@ func (h *HTTPHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Helper function to download file from URL if needed
-func (h *HTTPHandler) getFileBytes(path string) ([]byte, string, error) {
-	// Check if path is a URL
-	if utils.IsURL(path) {
-		// Download file from URL
-		resp, err := http.Get(path)
-		if err != nil {
-			return nil, "", fmt.Errorf("erro ao baixar arquivo: %v", err)
-		}
-		defer resp.Body.Close()
+// loadTimezone carrega o fuso horário informado pelo frontend
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return nil, fmt.Errorf("Missing timezone field")
+	}
 
-		// Read response body
-		fileBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, "", fmt.Errorf("erro ao ler resposta: %v", err)
-		}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid timezone")
+	}
+	return loc, nil
+}
 
-		// Extract filename from URL
-		fileName := filepath.Base(path)
-		return fileBytes, fileName, nil
+// checkRateLimit responde 429 com Retry-After quando o setor ou o destinatário excedeu o limite de envios
+func (h *HTTPHandler) checkRateLimit(w http.ResponseWriter, sectorID int, recipient string) bool {
+	allowed, retryAfter := h.connectionManager.AllowSend(sectorID, recipient)
+	if allowed {
+		return true
 	}
 
-	// If path is a local file path
-	fileBytes, err := os.ReadFile(path)
-	if err != nil {
-		return nil, "", fmt.Errorf("erro ao ler arquivo: %v", err)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
 	}
+	utils.LogWarning("Limite de envios excedido no setor %d para %s, tente novamente em %ds", sectorID, recipient, seconds)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	models.RespondWithJSON(w, http.StatusTooManyRequests, models.NewErrorResponseWithCode(services.CodeRateLimited, fmt.Sprintf("Limite de envios excedido, tente novamente em %d segundos", seconds)))
+	return false
+}
 
-	fileName := filepath.Base(path)
-	return fileBytes, fileName, nil
+func (h *HTTPHandler) storeMedia(key string, data []byte) (string, error) {
+	if h.mediaStore == nil {
+		return "", fmt.Errorf("armazenamento de mídia não está disponível")
+	}
+
+	if err := h.mediaStore.Put(key, data, http.DetectContentType(data)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// mediaFileName remove diretórios do nome informado pelo cliente antes de usá-lo na chave do armazenamento
+func mediaFileName(fileName string) string {
+	fileName = filepath.Base(fileName)
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return "file"
+	}
+	return fileName
 }
 
 // @Summary Mark Contact as Viewed
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyKeyMaxLength   = 255
+	idempotencyQueuedIDField  = "queuedMessageId"
+	idempotencyMessageIDField = "whatsAppMessageId"
+	// Corpos maiores (ex: uploads multipart) são copiados para um temporário em vez de ficarem na memória
+	idempotencyMemoryLimit = 1 << 20
+	// As chaves expiradas são removidas em lotes fora do caminho das requisições
+	idempotencyPurgeInterval  = 10 * time.Minute
+	idempotencyPurgeBatchSize = 500
+)
+
+// StartIdempotencyPurge inicia a limpeza periódica das chaves de idempotência expiradas
+func (h *HTTPHandler) StartIdempotencyPurge() {
+	go h.runIdempotencyPurge()
+}
+
+func (h *HTTPHandler) runIdempotencyPurge() {
+	for {
+		h.purgeExpiredIdempotencyKeys()
+		time.Sleep(idempotencyPurgeInterval)
+	}
+}
+
+// purgeExpiredIdempotencyKeys remove as chaves expiradas em lotes pequenos para não travar a tabela.
+// Chaves expiradas que ainda não foram removidas são sobrescritas pelo Reserve
+func (h *HTTPHandler) purgeExpiredIdempotencyKeys() {
+	var total int64
+	for {
+		purged, err := h.idempotencyRepository.PurgeExpired(idempotencyPurgeBatchSize)
+		if err != nil {
+			utils.LogError("Erro ao remover chaves de idempotência expiradas: %v", err)
+			return
+		}
+		total += purged
+		if purged < idempotencyPurgeBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		utils.LogInfo("%d chaves de idempotência expiradas removidas", total)
+	}
+}
+
+// responseRecorder copia a resposta do handler para que ela possa ser gravada junto à chave
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// WithIdempotency faz com que requisições repetidas com o mesmo Idempotency-Key
+// devolvam a resposta original em vez de enviar a mensagem novamente
+func (h *HTTPHandler) WithIdempotency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+		if key == "" || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLength {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Idempotency-Key deve ter no máximo 255 caracteres"))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxMediaRequestSize())
+		requestHash, cleanup, err := spoolRequestBody(r)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			models.RespondWithJSON(w, http.StatusRequestEntityTooLarge, models.NewErrorResponse("Requisição maior que o limite permitido"))
+			return
+		}
+		if err != nil {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao ler requisição: "+err.Error()))
+			return
+		}
+		defer cleanup()
+
+		// A chave vale apenas dentro do setor, para que setores diferentes possam usar a mesma chave
+		record := &models.IdempotencyRecord{
+			SectorID:    requestSectorID(r),
+			Key:         key,
+			Endpoint:    r.URL.Path,
+			RequestHash: requestHash,
+		}
+
+		reserved, err := h.idempotencyRepository.Reserve(record)
+		if err != nil {
+			utils.LogError("Erro ao reservar Idempotency-Key em %s: %v", r.URL.Path, err)
+			models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao verificar Idempotency-Key"))
+			return
+		}
+
+		if !reserved {
+			h.replayIdempotentRequest(w, record)
+			return
+		}
+
+		release := func() {
+			if err := h.idempotencyRepository.Release(record.SectorID, record.Key, record.Endpoint); err != nil {
+				utils.LogError("Erro ao liberar Idempotency-Key em %s: %v", r.URL.Path, err)
+			}
+		}
+
+		// Se o handler entrar em pânico a chave é liberada; se o processo cair, ela vence em IdempotencyLockTimeout
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next(rec, r)
+
+		// Apenas respostas de sucesso ficam gravadas; falhas liberam a chave para nova tentativa
+		if rec.statusCode < 200 || rec.statusCode >= 300 {
+			release()
+			return
+		}
+
+		record.StatusCode = rec.statusCode
+		record.ResponseBody = rec.body.Bytes()
+		record.OutboundMessageID = queuedMessageIDFromResponse(record.ResponseBody)
+		if err := h.idempotencyRepository.Complete(record); err != nil {
+			utils.LogError("Erro ao gravar resposta da Idempotency-Key em %s: %v", r.URL.Path, err)
+		}
+	}
+}
+
+// seekableBody é o corpo já lido, que pode voltar ao início para ser lido de novo
+type seekableBody struct {
+	io.ReadSeeker
+}
+
+func (seekableBody) Close() error { return nil }
+
+// requestSectorID lê o setor do corpo (JSON ou multipart) e volta o corpo ao início. O arquivo em
+// base64File é apenas descartado, sem ser carregado na memória. Retorna 0 se o setor não for encontrado
+func requestSectorID(r *http.Request) int {
+	body, ok := r.Body.(io.ReadSeeker)
+	if !ok {
+		return 0
+	}
+	defer body.Seek(0, io.SeekStart)
+
+	contentType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "multipart/form-data" {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return 0
+			}
+			if name := part.FormName(); name == "sectorId" || name == "sector_id" {
+				value, _ := io.ReadAll(io.LimitReader(part, multipartFieldLimit))
+				sectorID, _ := strconv.Atoi(strings.TrimSpace(string(value)))
+				return sectorID
+			}
+		}
+	}
+
+	// Os envios de texto usam sector_id e os de mídia, sectorId
+	var fields struct {
+		SectorID      int `json:"sectorId"`
+		SnakeSectorID int `json:"sector_id"`
+	}
+	discard := func(value io.Reader) error { return nil }
+	if err := decodeJSONStreamingField(body, "base64File", discard, &fields); err != nil {
+		return 0
+	}
+	return max(fields.SectorID, fields.SnakeSectorID)
+}
+
+// spoolRequestBody calcula o hash do corpo e o deixa disponível para ser lido de novo pelo handler.
+// Corpos pequenos ficam na memória; os maiores vão para um temporário, removido por cleanup.
+// Em corpos multipart o hash vem de multipartFingerprint, já que o boundary muda a cada tentativa
+func spoolRequestBody(r *http.Request) (string, func(), error) {
+	hasher := sha256.New()
+
+	var buffer bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(&buffer, hasher), io.LimitReader(r.Body, idempotencyMemoryLimit+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if n <= idempotencyMemoryLimit {
+		body := bytes.NewReader(buffer.Bytes())
+		r.Body = seekableBody{body}
+		return requestFingerprint(r, body, hex.EncodeToString(hasher.Sum(nil))), func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "whatsapp-request-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	if _, err := buffer.WriteTo(file); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := io.Copy(io.MultiWriter(file, hasher), r.Body); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	r.Body = file
+	return requestFingerprint(r, file, hex.EncodeToString(hasher.Sum(nil))), cleanup, nil
+}
+
+// requestFingerprint usa o hash de multipartFingerprint nos corpos multipart e, nos demais
+// (ou se o multipart estiver malformado), o hash do corpo inteiro
+func requestFingerprint(r *http.Request, body io.ReadSeeker, bodyHash string) string {
+	contentType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != "multipart/form-data" {
+		return bodyHash
+	}
+
+	fingerprint, err := multipartFingerprint(body, params["boundary"])
+	if err != nil {
+		return bodyHash
+	}
+	return fingerprint
+}
+
+// multipartFingerprint calcula o hash do formulário a partir do nome, do nome do arquivo e do
+// conteúdo de cada parte, ignorando o boundary e a ordem das partes. O corpo volta ao início ao final
+func multipartFingerprint(body io.ReadSeeker, boundary string) (string, error) {
+	defer body.Seek(0, io.SeekStart)
+
+	var parts []string
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		digest := sha256.New()
+		if _, err := io.Copy(digest, part); err != nil {
+			return "", err
+		}
+		parts = append(parts, part.FormName()+"\x00"+part.FileName()+"\x00"+hex.EncodeToString(digest.Sum(nil)))
+	}
+	sort.Strings(parts)
+
+	hasher := sha256.New()
+	for _, part := range parts {
+		hasher.Write([]byte(part + "\n"))
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (h *HTTPHandler) replayIdempotentRequest(w http.ResponseWriter, record *models.IdempotencyRecord) {
+	existing, err := h.idempotencyRepository.Get(record.SectorID, record.Key, record.Endpoint)
+	if err != nil {
+		utils.LogError("Erro ao buscar Idempotency-Key em %s: %v", record.Endpoint, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao verificar Idempotency-Key"))
+		return
+	}
+
+	if existing == nil || existing.Status == models.IdempotencyStatusPending {
+		models.RespondWithJSON(w, http.StatusConflict, models.NewErrorResponse("Uma requisição com este Idempotency-Key ainda está em processamento"))
+		return
+	}
+
+	if existing.RequestHash != record.RequestHash {
+		models.RespondWithJSON(w, http.StatusUnprocessableEntity, models.NewErrorResponse("Idempotency-Key já utilizado com um corpo de requisição diferente"))
+		return
+	}
+
+	body := existing.ResponseBody
+	if existing.OutboundMessageID != 0 {
+		body = h.withCurrentOutboundState(body, existing.OutboundMessageID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(body)
+}
+
+// withCurrentOutboundState atualiza a resposta gravada com o estado atual da mensagem na fila,
+// incluindo o ID da mensagem no WhatsApp quando ela já foi enviada
+func (h *HTTPHandler) withCurrentOutboundState(body []byte, outboundMessageID int64) []byte {
+	message, err := h.outboundRepository.GetByID(outboundMessageID)
+	if err != nil || message == nil {
+		return body
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+
+	data, ok := response["data"].(map[string]interface{})
+	if !ok {
+		return body
+	}
+	data["status"] = message.Status
+	if message.WhatsAppMessageID != "" {
+		data[idempotencyMessageIDField] = message.WhatsAppMessageID
+	}
+
+	updated, err := json.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return append(updated, '\n')
+}
+
+func queuedMessageIDFromResponse(body []byte) int64 {
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0
+	}
+
+	if id, ok := response.Data[idempotencyQueuedIDField].(float64); ok {
+		return int64(id)
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+type formPart struct {
+	name     string
+	fileName string
+	content  string
+}
+
+// multipartRequest monta um corpo multipart com o boundary informado, já pronto para ser relido
+func multipartRequest(t *testing.T, boundary string, parts []formPart) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.SetBoundary(boundary); err != nil {
+		t.Fatalf("SetBoundary(%q) erro inesperado: %v", boundary, err)
+	}
+	for _, part := range parts {
+		var err error
+		if part.fileName == "" {
+			err = writer.WriteField(part.name, part.content)
+		} else {
+			var file io.Writer
+			if file, err = writer.CreateFormFile(part.name, part.fileName); err == nil {
+				_, err = file.Write([]byte(part.content))
+			}
+		}
+		if err != nil {
+			t.Fatalf("erro ao montar a parte %s: %v", part.name, err)
+		}
+	}
+	writer.Close()
+
+	request, err := http.NewRequest(http.MethodPost, "/api/v1/messages/image", &body)
+	if err != nil {
+		t.Fatalf("NewRequest erro inesperado: %v", err)
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestSpoolRequestBodyMultipartFingerprint(t *testing.T) {
+	original := []formPart{
+		{name: "sectorId", content: "1"},
+		{name: "recipient", content: "5511999999999"},
+		{name: "file", fileName: "foto.jpg", content: "conteúdo da imagem"},
+	}
+
+	tests := []struct {
+		name     string
+		boundary string
+		parts    []formPart
+		same     bool
+	}{
+		{name: "mesmo formulário com outro boundary", boundary: "outro-boundary", parts: original, same: true},
+		{name: "partes em outra ordem", boundary: "outro-boundary", parts: []formPart{original[2], original[0], original[1]}, same: true},
+		{name: "outro destinatário", boundary: "boundary-original", parts: []formPart{original[0], {name: "recipient", content: "5511988887777"}, original[2]}, same: false},
+		{name: "outro conteúdo do arquivo", boundary: "boundary-original", parts: []formPart{original[0], original[1], {name: "file", fileName: "foto.jpg", content: "outra imagem"}}, same: false},
+		{name: "outro nome de arquivo", boundary: "boundary-original", parts: []formPart{original[0], original[1], {name: "file", fileName: "foto2.jpg", content: "conteúdo da imagem"}}, same: false},
+		{name: "campo a menos", boundary: "boundary-original", parts: original[1:], same: false},
+	}
+
+	want, cleanup, err := spoolRequestBody(multipartRequest(t, "boundary-original", original))
+	if err != nil {
+		t.Fatalf("spoolRequestBody erro inesperado: %v", err)
+	}
+	cleanup()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := multipartRequest(t, tt.boundary, tt.parts)
+			got, cleanup, err := spoolRequestBody(request)
+			if err != nil {
+				t.Fatalf("spoolRequestBody erro inesperado: %v", err)
+			}
+			defer cleanup()
+
+			if (got == want) != tt.same {
+				t.Errorf("spoolRequestBody = %s, original %s, esperado iguais = %v", got, want, tt.same)
+			}
+			if sectorID := requestSectorID(request); len(tt.parts) == len(original) && sectorID != 1 {
+				t.Errorf("requestSectorID depois do hash = %d, esperado 1: o corpo não voltou ao início", sectorID)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// jsonFieldsLimit é o tamanho máximo dos campos de um objeto JSON, exceto o campo lido em stream
+const jsonFieldsLimit = multipartOverhead
+
+var errJSONFieldsTooLarge = errors.New("campos da requisição maiores que o limite permitido")
+
+// decodeJSONStreamingField decodifica o objeto JSON em v, exceto o campo de texto field, cujo conteúdo
+// (já sem as aspas e os escapes) é entregue a sink em stream, sem ser carregado inteiro na memória.
+// sink não é chamado quando o campo está ausente ou é null
+func decodeJSONStreamingField(src io.Reader, field string, sink func(io.Reader) error, v interface{}) error {
+	scanner := &jsonObjectScanner{src: bufio.NewReader(src)}
+	if err := scanner.scan(field, sink); err != nil {
+		return err
+	}
+	return json.Unmarshal(scanner.fields.Bytes(), v)
+}
+
+// jsonObjectScanner percorre um objeto JSON byte a byte, copiando os campos comuns para fields
+type jsonObjectScanner struct {
+	src    *bufio.Reader
+	fields bytes.Buffer
+}
+
+func (s *jsonObjectScanner) scan(field string, sink func(io.Reader) error) error {
+	c, err := s.next()
+	if err != nil {
+		return err
+	}
+	if c != '{' {
+		return fmt.Errorf("esperado um objeto JSON")
+	}
+	s.fields.WriteByte('{')
+
+	first := true
+	for {
+		c, err := s.next()
+		if err != nil {
+			return err
+		}
+		if c == '}' && first {
+			break
+		}
+		if c != '"' {
+			return fmt.Errorf("nome de campo inválido no JSON")
+		}
+
+		var rawKey bytes.Buffer
+		if err := s.captureString(&rawKey); err != nil {
+			return err
+		}
+		var key string
+		if err := json.Unmarshal(rawKey.Bytes(), &key); err != nil {
+			return err
+		}
+
+		if c, err = s.next(); err != nil {
+			return err
+		}
+		if c != ':' {
+			return fmt.Errorf("esperado ':' após o campo %s", key)
+		}
+		if c, err = s.next(); err != nil {
+			return err
+		}
+
+		if key == field {
+			if err := s.streamValue(c, sink); err != nil {
+				return err
+			}
+		} else {
+			if !first {
+				s.fields.WriteByte(',')
+			}
+			s.fields.Write(rawKey.Bytes())
+			s.fields.WriteByte(':')
+			if err := s.captureValue(c, &s.fields); err != nil {
+				return err
+			}
+			first = false
+		}
+		if s.fields.Len() > jsonFieldsLimit {
+			return errJSONFieldsTooLarge
+		}
+
+		if c, err = s.next(); err != nil {
+			return err
+		}
+		if c == '}' {
+			break
+		}
+		if c != ',' {
+			return fmt.Errorf("esperado ',' ou '}' após o campo %s", key)
+		}
+	}
+
+	s.fields.WriteByte('}')
+	return nil
+}
+
+// readByte lê o próximo byte; o fim do corpo no meio do objeto é um JSON incompleto
+func (s *jsonObjectScanner) readByte() (byte, error) {
+	c, err := s.src.ReadByte()
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return c, err
+}
+
+// next retorna o próximo byte que não seja espaço
+func (s *jsonObjectScanner) next() (byte, error) {
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, nil
+		}
+	}
+}
+
+// streamValue entrega o texto a sink e descarta o que ele não tiver lido
+func (s *jsonObjectScanner) streamValue(c byte, sink func(io.Reader) error) error {
+	if c == 'n' {
+		return s.expectLiteral("ull")
+	}
+	if c != '"' {
+		return fmt.Errorf("esperado um texto no JSON")
+	}
+
+	value := &jsonStringReader{src: s.src}
+	if err := sink(value); err != nil {
+		return err
+	}
+	_, err := io.Copy(io.Discard, value)
+	return err
+}
+
+func (s *jsonObjectScanner) expectLiteral(rest string) error {
+	for i := 0; i < len(rest); i++ {
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		if c != rest[i] {
+			return fmt.Errorf("valor inválido no JSON")
+		}
+	}
+	return nil
+}
+
+// captureString copia um texto JSON, com as aspas e os escapes; a aspa inicial já foi lida
+func (s *jsonObjectScanner) captureString(dst *bytes.Buffer) error {
+	dst.WriteByte('"')
+	escaped := false
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		dst.WriteByte(c)
+		if dst.Len() > jsonFieldsLimit {
+			return errJSONFieldsTooLarge
+		}
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			return nil
+		}
+	}
+}
+
+// captureValue copia um valor JSON qualquer, a partir do seu primeiro byte
+func (s *jsonObjectScanner) captureValue(c byte, dst *bytes.Buffer) error {
+	switch c {
+	case '"':
+		return s.captureString(dst)
+	case '{', '[':
+		dst.WriteByte(c)
+		depth := 1
+		for depth > 0 {
+			c, err := s.readByte()
+			if err != nil {
+				return err
+			}
+			if c == '"' {
+				if err := s.captureString(dst); err != nil {
+					return err
+				}
+				continue
+			}
+			switch c {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			dst.WriteByte(c)
+			if dst.Len() > jsonFieldsLimit {
+				return errJSONFieldsTooLarge
+			}
+		}
+		return nil
+	}
+
+	// Número, true, false ou null: vai até o próximo separador
+	dst.WriteByte(c)
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		if c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			return s.src.UnreadByte()
+		}
+		dst.WriteByte(c)
+		if dst.Len() > jsonFieldsLimit {
+			return errJSONFieldsTooLarge
+		}
+	}
+}
+
+// jsonStringReader lê o conteúdo de um texto JSON, resolvendo os escapes, até a aspa final
+type jsonStringReader struct {
+	src     *bufio.Reader
+	pending []byte
+	done    bool
+}
+
+func (r *jsonStringReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) > 0 {
+			copied := copy(p[n:], r.pending)
+			r.pending = r.pending[copied:]
+			n += copied
+			continue
+		}
+		if r.done {
+			break
+		}
+
+		c, err := r.src.ReadByte()
+		if err == io.EOF {
+			return n, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return n, err
+		}
+		switch c {
+		case '"':
+			r.done = true
+		case '\\':
+			if r.pending, err = r.readEscape(); err != nil {
+				return n, err
+			}
+		default:
+			p[n] = c
+			n++
+		}
+	}
+
+	if n == 0 && r.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (r *jsonStringReader) readEscape() ([]byte, error) {
+	c, err := r.src.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch c {
+	case '"', '\\', '/':
+		return []byte{c}, nil
+	case 'b':
+		return []byte{'\b'}, nil
+	case 'f':
+		return []byte{'\f'}, nil
+	case 'n':
+		return []byte{'\n'}, nil
+	case 'r':
+		return []byte{'\r'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case 'u':
+		hex := make([]byte, 4)
+		if _, err := io.ReadFull(r.src, hex); err != nil {
+			return nil, err
+		}
+		code, err := strconv.ParseUint(string(hex), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("escape inválido no JSON: \\u%s", hex)
+		}
+		return utf8.AppendRune(nil, rune(code)), nil
+	}
+	return nil, fmt.Errorf("escape inválido no JSON: \\%c", c)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"whatsapp-bot/internal/mediaurl"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/services"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Serve stored media
+// @Description Serve a file from the local media storage. Requires the expires and signature parameters of a signed link
+// @Tags media
+// @Produce octet-stream
+// @Param key path string true "Chave do arquivo"
+// @Param expires query int true "Validade do link (unix)"
+// @Param signature query string true "Assinatura do link"
+// @Success 200 {file} file
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /media/{key} [get]
+func (h *HTTPHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.mediaStore.(*services.LocalMediaStore)
+	if !ok {
+		// Com o S3 os arquivos são acessados diretamente no bucket
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Arquivo não encontrado"))
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	query := r.URL.Query()
+	if !store.VerifySignature(key, query.Get("expires"), query.Get("signature")) {
+		models.RespondWithJSON(w, http.StatusForbidden, models.NewErrorResponse("Link inválido ou expirado"))
+		return
+	}
+
+	object, err := store.Stat(key)
+	if errors.Is(err, services.ErrMediaNotFound) {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Arquivo não encontrado"))
+		return
+	}
+	if err != nil {
+		utils.LogError("Erro ao consultar mídia %s: %v", key, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar arquivo"))
+		return
+	}
+
+	file, err := store.Open(key)
+	if err != nil {
+		utils.LogError("Erro ao abrir mídia %s: %v", key, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar arquivo"))
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, "", object.ModTime, file)
+}
+
+// @Summary Redirect to message media
+// @Description Redirect to a short-lived link of the media attached to a message. Clients that persist links should store this address instead of the temporary link; it expires after storage.resource_url_ttl and a new one is returned whenever the message is loaded
+// @Tags media
+// @Param id path int true "ID da mensagem"
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Param expires query int true "Validade do endereço (Unix), recebida em mediaPath"
+// @Param token query string true "Token do endereço, recebido em mediaPath"
+// @Success 302
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /messages/{id}/media [get]
+func (h *HTTPHandler) RedirectMessageMedia(w http.ResponseWriter, r *http.Request) {
+	message := h.sectorMessage(w, r)
+	if message == nil {
+		return
+	}
+
+	h.redirectToMedia(w, r, message.URL)
+}
+
+// @Summary Redirect to media preview
+// @Description Redirect to a short-lived link of the small preview of an image or document message (first page), for listing conversations without downloading the full file. Falls back to the original file when there is no preview
+// @Tags media
+// @Param id path int true "ID da mensagem"
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Param expires query int true "Validade do endereço (Unix), a mesma de mediaPath"
+// @Param token query string true "Token do endereço, o mesmo de mediaPath"
+// @Success 302
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /messages/{id}/preview [get]
+func (h *HTTPHandler) RedirectMessagePreview(w http.ResponseWriter, r *http.Request) {
+	message := h.sectorMessage(w, r)
+	if message == nil {
+		return
+	}
+
+	// A prévia só existe para imagens e documentos guardados no armazenamento
+	hasPreview := message.Tipo == "image" || message.Tipo == "document"
+	if key := mediaurl.Key(message.URL); key != "" && hasPreview && h.mediaStore != nil {
+		previewKey := services.MediaPreviewKey(key)
+		if _, err := h.mediaStore.Stat(previewKey); err == nil {
+			h.redirectToMedia(w, r, previewKey)
+			return
+		}
+	}
+
+	h.redirectToMedia(w, r, message.URL)
+}
+
+// sectorMessage busca a mensagem do caminho, conferindo o token e se ela pertence ao setor informado.
+// Retorna nil depois de responder com o erro
+func (h *HTTPHandler) sectorMessage(w http.ResponseWriter, r *http.Request) *models.Message {
+	sectorID, messageID, ok := resourceRequest(w, r, mediaurl.ResourceMessage)
+	if !ok {
+		return nil
+	}
+
+	message, err := h.messageRepository.GetByID(messageID)
+	if err != nil {
+		utils.LogError("Erro ao buscar mensagem %d: %v", messageID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar mensagem"))
+		return nil
+	}
+	if message == nil || message.IDSetor != sectorID {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Mensagem não encontrada"))
+		return nil
+	}
+	return message
+}
+
+// @Summary Redirect to contact avatar
+// @Description Redirect to a short-lived link of the contact's profile picture
+// @Tags media
+// @Param id path int true "ID do contato"
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Param expires query int true "Validade do endereço (Unix), recebida em avatarPath"
+// @Param token query string true "Token do endereço, recebido em avatarPath"
+// @Success 302
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /contacts/{id}/avatar [get]
+func (h *HTTPHandler) RedirectContactAvatar(w http.ResponseWriter, r *http.Request) {
+	sectorID, contactID, ok := resourceRequest(w, r, mediaurl.ResourceContact)
+	if !ok {
+		return
+	}
+
+	contact, err := h.contactRepository.GetByID(sectorID, contactID)
+	if err != nil {
+		utils.LogError("Erro ao buscar contato %d: %v", contactID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar contato"))
+		return
+	}
+	if contact == nil {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Contato não encontrado"))
+		return
+	}
+
+	h.redirectToMedia(w, r, contact.AvatarURL)
+}
+
+// resourceRequest lê o setor e o ID do caminho e confere o token que assina o par até a validade do endereço.
+// Os IDs são sequenciais, então sem o token qualquer um poderia percorrê-los e obter links para as mídias de todos os setores
+func resourceRequest(w http.ResponseWriter, r *http.Request, kind string) (int, int, bool) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return 0, 0, false
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID inválido"))
+		return 0, 0, false
+	}
+
+	if !mediaurl.VerifyResourceToken(kind, sectorID, id, r.URL.Query().Get("expires"), r.URL.Query().Get("token")) {
+		models.RespondWithJSON(w, http.StatusForbidden, models.NewErrorResponse("Link inválido"))
+		return 0, 0, false
+	}
+	return sectorID, id, true
+}
+
+// redirectToMedia gera um link temporário novo a cada acesso, sem cache, para que o link expirado nunca seja reaproveitado
+func (h *HTTPHandler) redirectToMedia(w http.ResponseWriter, r *http.Request, stored string) {
+	if mediaurl.Key(stored) == "" && !strings.Contains(stored, "://") {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Nenhuma mídia disponível"))
+		return
+	}
+
+	link := mediaurl.Sign(stored)
+	if link == "" {
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao gerar link da mídia"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, link, http.StatusFound)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"whatsapp-bot/internal/mediaurl"
+	"whatsapp-bot/internal/services"
+)
+
+// mediaURLMaxRedirects limita os redirecionamentos seguidos ao baixar um mediaUrl
+const mediaURLMaxRedirects = 5
+
+var errMediaURLDisabled = errors.New("envio por mediaUrl não está habilitado (configure media.allowed_url_hosts)")
+
+// resolveMediaSource obtém o arquivo de um envio sem arquivo no corpo: um mediaUrl do próprio armazenamento
+// ou um objectKey são reaproveitados sem novo upload; outros mediaUrl são baixados para um temporário
+func (h *HTTPHandler) resolveMediaSource(sectorID int, mediaURL string, objectKey string, limit int64) (*incomingMedia, error) {
+	if objectKey == "" && strings.Contains(mediaURL, "://") {
+		// Links (inclusive os assinados) do próprio armazenamento apontam para um arquivo que já temos
+		objectKey = mediaurl.Key(mediaURL)
+	}
+
+	if objectKey != "" {
+		return h.storedMedia(sectorID, objectKey, limit)
+	}
+	if mediaURL != "" {
+		return h.fetchMedia(mediaURL, limit)
+	}
+	return nil, errMissingFile
+}
+
+// storedMedia valida um arquivo já guardado no armazenamento para ser enviado novamente
+func (h *HTTPHandler) storedMedia(sectorID int, key string, limit int64) (*incomingMedia, error) {
+	if h.mediaStore == nil {
+		return nil, fmt.Errorf("armazenamento de mídia não está disponível")
+	}
+	if !services.ReusableMediaKey(sectorID, key) {
+		return nil, fmt.Errorf("objectKey não pertence ao setor: %s", key)
+	}
+
+	object, err := h.mediaStore.Stat(key)
+	if errors.Is(err, services.ErrMediaNotFound) {
+		return nil, fmt.Errorf("objectKey não encontrado: %s", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && object.Size > limit {
+		return nil, services.MediaTooLargeError(limit)
+	}
+
+	return &incomingMedia{
+		storedKey:   object.Key,
+		size:        object.Size,
+		contentType: object.ContentType,
+		fileName:    path.Base(object.Key),
+	}, nil
+}
+
+// fetchMedia baixa o mediaUrl para um temporário. Apenas os hosts configurados são aceitos, inclusive nos
+// redirecionamentos, e o download é interrompido ao passar do limite do tipo de mensagem
+func (h *HTTPHandler) fetchMedia(rawURL string, limit int64) (*incomingMedia, error) {
+	if err := h.checkMediaURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: h.config.Media.URLFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= mediaURLMaxRedirects {
+				return fmt.Errorf("redirecionamentos demais")
+			}
+			return h.checkMediaURL(req.URL.String())
+		},
+	}
+
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao baixar mediaUrl: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("erro ao baixar mediaUrl: status %d", resp.StatusCode)
+	}
+	if limit > 0 && resp.ContentLength > limit {
+		return nil, services.MediaTooLargeError(limit)
+	}
+
+	// O tipo é deduzido do conteúdo pelo spoolMedia, sem confiar no Content-Type do servidor remoto
+	media, err := spoolMedia(resp.Body, limit)
+	if err != nil {
+		return nil, err
+	}
+	media.fileName = path.Base(resp.Request.URL.Path)
+	return media, nil
+}
+
+// checkMediaURL confere o esquema e se o host está na lista de hosts permitidos
+func (h *HTTPHandler) checkMediaURL(rawURL string) error {
+	allowed := h.config.Media.AllowedURLHosts
+	if len(allowed) == 0 {
+		return errMediaURLDisabled
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("mediaUrl inválido: %s", rawURL)
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if host == pattern {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host não permitido em mediaUrl: %s", host)
+}
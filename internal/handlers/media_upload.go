@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/services"
+)
+
+const (
+	// multipartFileField é o campo do formulário com o arquivo; os demais campos têm os nomes do JSON
+	multipartFileField = "file"
+	// multipartFieldLimit é o tamanho máximo de cada campo de texto do formulário
+	multipartFieldLimit = 64 << 10
+	// multipartOverhead é a folga sobre o limite do arquivo para os outros campos e cabeçalhos do formulário
+	multipartOverhead = 1 << 20
+	// dataURLPrefixLimit é o maior prefixo "data:<tipo>;base64," procurado no início do base64File
+	dataURLPrefixLimit = 256
+)
+
+var (
+	errMissingFile = errors.New("arquivo não informado")
+	errEmptyFile   = errors.New("arquivo vazio")
+)
+
+// incomingMedia é o arquivo de um envio: recebido na requisição (gravado em um temporário para não ficar inteiro
+// na memória) ou já guardado no armazenamento (storedKey)
+type incomingMedia struct {
+	file        *os.File
+	storedKey   string
+	size        int64
+	contentType string
+	fileName    string
+}
+
+// Close remove o temporário
+func (m *incomingMedia) Close() {
+	if m.file == nil {
+		return
+	}
+	m.file.Close()
+	os.Remove(m.file.Name())
+}
+
+// spoolMedia copia src para um temporário, interrompendo a cópia assim que o limite é ultrapassado
+func spoolMedia(src io.Reader, limit int64) (*incomingMedia, error) {
+	file, err := os.CreateTemp("", "whatsapp-media-*")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar arquivo temporário: %v", err)
+	}
+	media := &incomingMedia{file: file}
+
+	reader := src
+	if limit > 0 {
+		reader = io.LimitReader(src, limit+1)
+	}
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		media.Close()
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, services.MediaTooLargeError(limit)
+		}
+		return nil, fmt.Errorf("erro ao ler arquivo: %v", err)
+	}
+	if limit > 0 && size > limit {
+		media.Close()
+		return nil, services.MediaTooLargeError(limit)
+	}
+	if size == 0 {
+		media.Close()
+		return nil, errEmptyFile
+	}
+
+	// O tipo é deduzido do conteúdo, sem confiar no Content-Type informado pelo cliente
+	head := make([]byte, 512)
+	n, _ := file.ReadAt(head, 0)
+	media.contentType = http.DetectContentType(head[:n])
+	media.size = size
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		media.Close()
+		return nil, fmt.Errorf("erro ao ler arquivo temporário: %v", err)
+	}
+	return media, nil
+}
+
+// readMediaRequest lê um envio de mídia em JSON (arquivo em base64File) ou em multipart/form-data (arquivo
+// no campo file). O arquivo vai para um temporário, limitado ao tamanho configurado para o tipo. Sem arquivo no
+// corpo, são usados o mediaUrl ou o objectKey. O chamador deve fechar o incomingMedia retornado
+func (h *HTTPHandler) readMediaRequest(w http.ResponseWriter, r *http.Request, kind string) (*models.MediaMessageRequest, *incomingMedia, error) {
+	limit := h.connectionManager.MediaSizeLimit(kind)
+
+	var req models.MediaMessageRequest
+	media, err := h.readMediaBody(w, r, limit, &req, func(name string, value string) error {
+		return setMediaRequestField(&req, name, value)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if media == nil {
+		media, err = h.resolveMediaSource(req.SectorID, req.MediaURL, req.ObjectKey, limit)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := checkMediaContent(kind, media); err != nil {
+		media.Close()
+		return nil, nil, err
+	}
+
+	if req.FileName == "" {
+		req.FileName = media.fileName
+	}
+	return &req, media, nil
+}
+
+// readMediaBody lê o corpo de uma requisição com arquivo: em multipart/form-data, o arquivo vem no campo file e os
+// demais campos são entregues a setField; em JSON, o base64File é decodificado em stream e o restante vai para v.
+// Retorna nil quando o corpo não traz arquivo
+func (h *HTTPHandler) readMediaBody(w http.ResponseWriter, r *http.Request, limit int64, v interface{}, setField func(name string, value string) error) (*incomingMedia, error) {
+	// O corpo é sempre limitado, com ou sem Idempotency-Key
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxMediaRequestSize())
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "multipart/form-data" {
+		return readMultipartMediaBody(w, r, limit, setField)
+	}
+	return readBase64MediaBody(r, limit, v)
+}
+
+// checkMediaContent confere o arquivo recebido para o tipo de mensagem. Imagens são conferidas pelo conteúdo,
+// já que o WhatsApp recusa arquivos que não são imagem
+func checkMediaContent(kind string, media *incomingMedia) error {
+	if kind == models.OutboundKindImage && !strings.HasPrefix(media.contentType, "image/") {
+		return fmt.Errorf("o arquivo não é uma imagem (%s)", media.contentType)
+	}
+	return nil
+}
+
+// requestMedia obtém o arquivo de um envio cujo tipo de mensagem vem no próprio corpo, conhecido apenas depois de
+// lido o arquivo: sem arquivo no corpo, usa o mediaUrl ou o objectKey; com ele, aplica o limite de tamanho do tipo.
+// Em caso de erro o arquivo é fechado
+func (h *HTTPHandler) requestMedia(sectorID int, kind string, media *incomingMedia, mediaURL string, objectKey string) (*incomingMedia, error) {
+	limit := h.connectionManager.MediaSizeLimit(kind)
+	if media == nil {
+		var err error
+		if media, err = h.resolveMediaSource(sectorID, mediaURL, objectKey, limit); err != nil {
+			return nil, err
+		}
+	} else if limit > 0 && media.size > limit {
+		media.Close()
+		return nil, services.MediaTooLargeError(limit)
+	}
+
+	if err := checkMediaContent(kind, media); err != nil {
+		media.Close()
+		return nil, err
+	}
+	return media, nil
+}
+
+// readMultipartMediaBody lê o formulário em stream; os campos de texto têm os mesmos nomes do JSON
+func readMultipartMediaBody(w http.ResponseWriter, r *http.Request, limit int64, setField func(name string, value string) error) (*incomingMedia, error) {
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler formulário: %v", err)
+	}
+
+	var media *incomingMedia
+	fail := func(err error) (*incomingMedia, error) {
+		if media != nil {
+			media.Close()
+		}
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return fail(services.MediaTooLargeError(limit))
+			}
+			return fail(fmt.Errorf("erro ao ler formulário: %v", err))
+		}
+
+		if part.FormName() == multipartFileField {
+			if media != nil {
+				part.Close()
+				return fail(fmt.Errorf("envie apenas um arquivo por requisição"))
+			}
+			media, err = spoolMedia(part, limit)
+			part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			media.fileName = part.FileName()
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, multipartFieldLimit))
+		part.Close()
+		if err != nil {
+			return fail(fmt.Errorf("erro ao ler campo %s: %v", part.FormName(), err))
+		}
+		if err := setField(part.FormName(), strings.TrimSpace(string(value))); err != nil {
+			return fail(err)
+		}
+	}
+
+	return media, nil
+}
+
+// maxMediaRequestSize é o maior corpo aceito em um envio de mídia: o maior limite de arquivo codificado em base64,
+// mais a folga dos demais campos
+func (h *HTTPHandler) maxMediaRequestSize() int64 {
+	media := h.config.Media
+	limit := max(media.MaxImageSize, media.MaxAudioSize, media.MaxDocumentSize, media.MaxUploadSize)
+	return int64(base64.StdEncoding.EncodedLen(int(limit))) + multipartOverhead
+}
+
+// maxMediaFileSize é o maior limite de arquivo entre os tipos de mensagem, usado enquanto o tipo ainda não é conhecido
+func (h *HTTPHandler) maxMediaFileSize() int64 {
+	media := h.config.Media
+	return max(media.MaxImageSize, media.MaxAudioSize, media.MaxDocumentSize)
+}
+
+// readBase64MediaBody lê o envio em JSON, decodificando o base64File em stream direto para o temporário,
+// sem carregar o texto do arquivo na memória
+func readBase64MediaBody(r *http.Request, limit int64, v interface{}) (*incomingMedia, error) {
+	var media *incomingMedia
+	err := decodeJSONStreamingField(r.Body, "base64File", func(value io.Reader) error {
+		reader := bufio.NewReaderSize(value, dataURLPrefixLimit)
+		// Texto vazio equivale a não enviar o arquivo
+		if _, err := reader.Peek(1); err == io.EOF {
+			return nil
+		}
+		if err := skipDataURLPrefix(reader); err != nil {
+			return err
+		}
+
+		var err error
+		media, err = spoolMedia(base64.NewDecoder(base64.StdEncoding, reader), limit)
+		return err
+	}, v)
+	if err != nil {
+		if media != nil {
+			media.Close()
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, services.MediaTooLargeError(limit)
+		}
+		var serviceErr *services.Error
+		if errors.As(err, &serviceErr) || errors.Is(err, errEmptyFile) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("erro ao decodificar requisição: %v", err)
+	}
+	return media, nil
+}
+
+// skipDataURLPrefix descarta o prefixo "data:<tipo>;base64," quando o arquivo vem como data URL
+func skipDataURLPrefix(reader *bufio.Reader) error {
+	head, err := reader.Peek(dataURLPrefixLimit)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	if i := bytes.Index(head, []byte(";base64,")); i > -1 && bytes.HasPrefix(head, []byte("data:")) {
+		_, err := reader.Discard(i + len(";base64,"))
+		return err
+	}
+	return nil
+}
+
+// setMediaRequestField preenche o campo do formulário multipart correspondente ao campo do JSON
+func setMediaRequestField(req *models.MediaMessageRequest, name string, value string) error {
+	var err error
+	switch name {
+	case "mediaType":
+		req.MediaType = value
+	case "fileName":
+		req.FileName = value
+	case "mediaUrl":
+		req.MediaURL = value
+	case "objectKey":
+		req.ObjectKey = value
+	case "caption":
+		req.Caption = value
+	case "recipient":
+		req.Recipient = value
+	case "contactId":
+		req.ContactID, err = strconv.Atoi(value)
+	case "sectorId":
+		req.SectorID, err = strconv.Atoi(value)
+	case "userId":
+		if value != "" {
+			var userID int
+			userID, err = strconv.Atoi(value)
+			req.UserID = &userID
+		}
+	case "isAnonymous":
+		req.IsAnonymous, err = strconv.ParseBool(value)
+	case "sentAt":
+		req.SentAt, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		return fmt.Errorf("campo %s inválido: %v", name, err)
+	}
+	return nil
+}
+
+// storeOutboundUpload guarda o arquivo recebido para que o despachante do setor possa enviá-lo depois
+func (h *HTTPHandler) storeOutboundUpload(sectorID int, media *incomingMedia, fileName string) (string, error) {
+	return h.storeUpload(media, services.OutboundMediaKey(sectorID, mediaFileName(fileName)))
+}
+
+// storeUpload guarda o arquivo recebido na chave informada. O armazenamento lê direto do temporário (no S3, em
+// partes quando o arquivo é grande). Arquivos que já estão no armazenamento são enviados a partir da própria
+// chave, sem novo upload
+func (h *HTTPHandler) storeUpload(media *incomingMedia, key string) (string, error) {
+	if media.storedKey != "" {
+		return media.storedKey, nil
+	}
+	if h.mediaStore == nil {
+		return "", fmt.Errorf("armazenamento de mídia não está disponível")
+	}
+
+	if err := h.mediaStore.PutStream(key, media.file, media.size, media.contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
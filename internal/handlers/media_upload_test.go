@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"whatsapp-bot/config"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/services"
+)
+
+func TestReadMediaBodyScheduledMessage(t *testing.T) {
+	handler := &HTTPHandler{config: config.NewConfig()}
+
+	jsonRequest, err := http.NewRequest(http.MethodPost, "/api/v1/scheduled-messages", strings.NewReader(
+		`{"base64File":"data:text/plain;base64,b2zDoQ==","sectorId":3,"kind":"document","recipient":"11999999999","sendAt":"2025-05-20T09:00:00","fileName":"nota.txt"}`))
+	if err != nil {
+		t.Fatalf("NewRequest erro inesperado: %v", err)
+	}
+	jsonRequest.Header.Set("Content-Type", "application/json")
+
+	tests := []struct {
+		name     string
+		request  *http.Request
+		fileName string
+	}{
+		{
+			name:    "JSON com base64File",
+			request: jsonRequest,
+		},
+		{
+			name: "multipart com o arquivo antes dos campos",
+			request: multipartRequest(t, "agendamento", []formPart{
+				{name: "file", fileName: "nota.txt", content: "olá"},
+				{name: "sectorId", content: "3"},
+				{name: "kind", content: "document"},
+				{name: "recipient", content: "11999999999"},
+				{name: "sendAt", content: "2025-05-20T09:00:00"},
+			}),
+			fileName: "nota.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req models.ScheduledMessageRequest
+			media, err := handler.readMediaBody(httptest.NewRecorder(), tt.request, handler.maxMediaFileSize(), &req, func(name string, value string) error {
+				return setScheduledMessageField(&req, name, value)
+			})
+			if err != nil {
+				t.Fatalf("readMediaBody erro inesperado: %v", err)
+			}
+			if media == nil {
+				t.Fatal("readMediaBody não retornou o arquivo")
+			}
+			defer media.Close()
+
+			if req.SectorID != 3 || req.Kind != models.OutboundKindDocument || req.Recipient != "11999999999" || req.SendAt != "2025-05-20T09:00:00" {
+				t.Fatalf("campos lidos incorretamente: %+v", req)
+			}
+			if media.fileName != tt.fileName {
+				t.Fatalf("fileName = %q, esperado %q", media.fileName, tt.fileName)
+			}
+			content, _ := io.ReadAll(media.file)
+			if string(content) != "olá" {
+				t.Fatalf("conteúdo = %q, esperado %q", content, "olá")
+			}
+		})
+	}
+}
+
+func TestReadMediaBodyLimit(t *testing.T) {
+	handler := &HTTPHandler{config: config.NewConfig()}
+
+	request := multipartRequest(t, "limite", []formPart{
+		{name: "file", fileName: "grande.bin", content: strings.Repeat("a", 2048)},
+		{name: "kind", content: "image"},
+	})
+	var req models.CampaignRequest
+	media, err := handler.readMediaBody(httptest.NewRecorder(), request, 1024, &req, func(name string, value string) error {
+		return setCampaignField(&req, name, value)
+	})
+	if media != nil {
+		media.Close()
+	}
+	if !errors.Is(err, services.ErrMediaTooLarge) {
+		t.Fatalf("readMediaBody erro = %v, esperado %v", err, services.ErrMediaTooLarge)
+	}
+}
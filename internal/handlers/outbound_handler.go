@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary List queued messages
+// @Description List the outbound queue of a sector, optionally filtered by status (queued, sending, sent, dead)
+// @Tags outbound
+// @Produce json
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Param status query string false "Status da mensagem"
+// @Param limit query int false "Quantidade máxima de mensagens (padrão 100)"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /outbound-messages [get]
+func (h *HTTPHandler) ListOutboundMessages(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	messages, err := h.outboundRepository.ListBySector(sectorID, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		utils.LogError("Erro ao listar fila de envio em /outbound-messages: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao listar fila de envio: "+err.Error()))
+		return
+	}
+	if messages == nil {
+		messages = []*models.OutboundMessage{}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Fila de envio do setor", messages))
+}
+
+// @Summary Get a queued message
+// @Description Get the current state of a message in the outbound queue
+// @Tags outbound
+// @Produce json
+// @Param id path int true "ID da mensagem na fila"
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /outbound-messages/{id} [get]
+func (h *HTTPHandler) GetOutboundMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID da mensagem inválido"))
+		return
+	}
+
+	message, err := h.outboundRepository.GetByID(id)
+	if err != nil {
+		utils.LogError("Erro ao buscar mensagem da fila em /outbound-messages: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar mensagem: "+err.Error()))
+		return
+	}
+	if message == nil {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Mensagem não encontrada"))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Mensagem da fila de envio", message))
+}
+
+// @Summary Retry a dead message
+// @Description Put a message in dead state back into the outbound queue
+// @Tags outbound
+// @Produce json
+// @Param id path int true "ID da mensagem na fila"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /outbound-messages/{id}/retry [post]
+func (h *HTTPHandler) RetryOutboundMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID da mensagem inválido"))
+		return
+	}
+
+	message, err := h.connectionManager.RetryOutbound(id)
+	if err != nil {
+		utils.LogError("Erro ao reenfileirar mensagem em /outbound-messages/%d/retry: %v", id, err)
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Não foi possível reenfileirar a mensagem: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Mensagem reenfileirada com sucesso", message))
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+// @Summary Check numbers on WhatsApp
+// @Description Check in batch which numbers have a WhatsApp account, returning the canonical number and JID of each one. Results are cached per sector
+// @Tags contacts
+// @Accept json
+// @Produce json
+// @Param request body models.CheckNumbersRequest true "Numbers to check"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /check-numbers [post]
+func (h *HTTPHandler) CheckNumbers(w http.ResponseWriter, r *http.Request) {
+	var req models.CheckNumbersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	if req.SectorID == 0 || len(req.Numbers) == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("sector_id e numbers são obrigatórios"))
+		return
+	}
+	if len(req.Numbers) > models.MaxCheckNumbers {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(fmt.Sprintf("Máximo de %d números por requisição", models.MaxCheckNumbers)))
+		return
+	}
+
+	service, err := h.connectionManager.GetConnection(req.SectorID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	results, err := service.CheckNumbers(req.Numbers)
+	if err != nil {
+		utils.LogError("Erro ao verificar números em /check-numbers: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao verificar números", err)
+		return
+	}
+
+	existing := 0
+	for _, result := range results {
+		if result.Exists {
+			existing++
+		}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Números verificados", map[string]interface{}{
+		"total":    len(results),
+		"existing": existing,
+		"results":  results,
+	}))
+}
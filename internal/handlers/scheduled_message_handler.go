@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/services"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Formatos aceitos para sendAt sem offset, interpretados no fuso informado
+var scheduledTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseSendAt converte o horário informado para UTC. Horários com offset explícito (RFC3339)
+// são respeitados; os demais são interpretados como horário local do fuso informado
+func parseSendAt(value string, loc *time.Location) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed.UTC(), nil
+	}
+
+	for _, layout := range scheduledTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("sendAt inválido, use o formato 2006-01-02T15:04:05")
+}
+
+// @Summary Schedule a message
+// @Description Schedule a text, image, audio or document message to be sent at a given time in the customer timezone. Media messages accept JSON with the file in base64 or multipart/form-data with the file streamed in the file field. Instead of the file, mediaUrl (allowed hosts only) or objectKey (a file sent through /upload) can be informed
+// @Tags scheduled
+// @Accept json,mpfd
+// @Produce json
+// @Param request body models.ScheduledMessageRequest false "Scheduled message details (JSON, arquivo em base64File)"
+// @Param file formData file false "Arquivo (multipart/form-data, demais campos com os nomes do JSON)"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Router /scheduled-messages [post]
+func (h *HTTPHandler) CreateScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	// O tipo da mensagem vem no próprio corpo: o arquivo é lido com o maior limite e conferido com o do tipo depois
+	var req models.ScheduledMessageRequest
+	media, err := h.readMediaBody(w, r, h.maxMediaFileSize(), &req, func(name string, value string) error {
+		return setScheduledMessageField(&req, name, value)
+	})
+	if err != nil {
+		utils.LogError("Erro ao ler requisição /scheduled-messages: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Erro ao ler requisição", err)
+		return
+	}
+	defer func() {
+		if media != nil {
+			media.Close()
+		}
+	}()
+
+	if req.SectorID == 0 || req.Recipient == "" || req.SendAt == "" {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Missing required fields"))
+		return
+	}
+
+	if req.Kind == "" {
+		req.Kind = models.OutboundKindText
+	}
+	switch req.Kind {
+	case models.OutboundKindText:
+		if req.Message == "" {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O campo message é obrigatório para mensagens de texto"))
+			return
+		}
+	case models.OutboundKindImage, models.OutboundKindAudio, models.OutboundKindDocument:
+		media, err = h.requestMedia(req.SectorID, req.Kind, media, req.MediaURL, req.ObjectKey)
+		if err != nil {
+			if errors.Is(err, errMissingFile) {
+				err = fmt.Errorf("envie o arquivo em base64File ou no campo file, ou informe mediaUrl ou objectKey, para mensagens de mídia")
+			}
+			respondWithError(w, http.StatusBadRequest, "", err)
+			return
+		}
+		if req.FileName == "" {
+			req.FileName = media.fileName
+		}
+	default:
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("kind deve ser text, image, audio ou document"))
+		return
+	}
+
+	loc, err := loadTimezone(req.Timezone)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+		return
+	}
+
+	sendAt, err := parseSendAt(req.SendAt, loc)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+		return
+	}
+
+	if err := h.connectionManager.CheckSector(req.SectorID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "", err)
+		return
+	}
+
+	scheduled := &models.ScheduledMessage{
+		SectorID:    req.SectorID,
+		Kind:        req.Kind,
+		Recipient:   req.Recipient,
+		Content:     req.Message,
+		FileName:    req.FileName,
+		UserID:      req.UserID,
+		IsAnonymous: req.IsAnonymous,
+		SendAt:      sendAt,
+		Timezone:    req.Timezone,
+	}
+
+	if req.Kind != models.OutboundKindText {
+		scheduled.MediaKey, err = h.storeOutboundUpload(req.SectorID, media, req.FileName)
+		if err != nil {
+			utils.LogError("Erro ao guardar mídia em /scheduled-messages: %v", err)
+			models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao guardar arquivo: "+err.Error()))
+			return
+		}
+	}
+
+	if err := h.scheduledRepository.Create(scheduled); err != nil {
+		utils.LogError("Erro ao agendar mensagem em /scheduled-messages: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao agendar mensagem: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusCreated, models.NewSuccessResponse("Mensagem agendada com sucesso", scheduled))
+}
+
+// setScheduledMessageField preenche o campo do formulário multipart correspondente ao campo do JSON
+func setScheduledMessageField(req *models.ScheduledMessageRequest, name string, value string) error {
+	var err error
+	switch name {
+	case "sectorId":
+		req.SectorID, err = strconv.Atoi(value)
+	case "kind":
+		req.Kind = value
+	case "recipient":
+		req.Recipient = value
+	case "message":
+		req.Message = value
+	case "fileName":
+		req.FileName = value
+	case "mediaUrl":
+		req.MediaURL = value
+	case "objectKey":
+		req.ObjectKey = value
+	case "userId":
+		if value != "" {
+			var userID int
+			userID, err = strconv.Atoi(value)
+			req.UserID = &userID
+		}
+	case "isAnonymous":
+		req.IsAnonymous, err = strconv.ParseBool(value)
+	case "sendAt":
+		req.SendAt = value
+	case "timezone":
+		req.Timezone = value
+	}
+	if err != nil {
+		return fmt.Errorf("campo %s inválido: %v", name, err)
+	}
+	return nil
+}
+
+// @Summary List scheduled messages
+// @Description List the scheduled messages of a sector, optionally filtered by status (pending, queued, sent, failed, canceled)
+// @Tags scheduled
+// @Produce json
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Param status query string false "Status da mensagem"
+// @Param limit query int false "Quantidade máxima de mensagens (padrão 100)"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /scheduled-messages [get]
+func (h *HTTPHandler) ListScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	messages, err := h.scheduledRepository.ListBySector(sectorID, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		utils.LogError("Erro ao listar mensagens agendadas em /scheduled-messages: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao listar mensagens agendadas: "+err.Error()))
+		return
+	}
+	if messages == nil {
+		messages = []*models.ScheduledMessage{}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Mensagens agendadas do setor", messages))
+}
+
+// @Summary Cancel a scheduled message
+// @Description Cancel a scheduled message that has not been sent yet
+// @Tags scheduled
+// @Produce json
+// @Param id path int true "ID da mensagem agendada"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /scheduled-messages/{id} [delete]
+func (h *HTTPHandler) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID da mensagem inválido"))
+		return
+	}
+
+	if err := h.scheduledRepository.Cancel(id); err != nil {
+		utils.LogError("Erro ao cancelar mensagem agendada %d: %v", id, err)
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Não foi possível cancelar a mensagem: "+err.Error()))
+		return
+	}
+
+	scheduled, err := h.scheduledRepository.GetByID(id)
+	if err != nil || scheduled == nil {
+		models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Mensagem cancelada com sucesso", nil))
+		return
+	}
+
+	// A mídia guardada para o agendamento não será mais usada; arquivos reaproveitados pelo objectKey são mantidos
+	if services.IsOutboundMediaKey(scheduled.SectorID, scheduled.MediaKey) && h.mediaStore != nil {
+		if err := h.mediaStore.Delete(scheduled.MediaKey); err != nil {
+			utils.LogWarning("Não foi possível remover mídia da mensagem agendada %d: %v", id, err)
+		}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Mensagem cancelada com sucesso", scheduled))
+}
+
+// @Summary Reschedule a message
+// @Description Change the send time of a pending or failed scheduled message
+// @Tags scheduled
+// @Accept json
+// @Produce json
+// @Param id path int true "ID da mensagem agendada"
+// @Param request body models.RescheduleRequest true "New send time"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /scheduled-messages/{id}/reschedule [put]
+func (h *HTTPHandler) RescheduleMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID da mensagem inválido"))
+		return
+	}
+
+	var req models.RescheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	loc, err := loadTimezone(req.Timezone)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+		return
+	}
+
+	sendAt, err := parseSendAt(req.SendAt, loc)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse(err.Error()))
+		return
+	}
+
+	if err := h.scheduledRepository.Reschedule(id, sendAt, req.Timezone); err != nil {
+		utils.LogError("Erro ao reagendar mensagem %d: %v", id, err)
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Não foi possível reagendar a mensagem: "+err.Error()))
+		return
+	}
+
+	scheduled, err := h.scheduledRepository.GetByID(id)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar mensagem: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Mensagem reagendada com sucesso", scheduled))
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Get sector settings
+// @Description Get the behaviour settings of a sector (call policy, send limits, agent signature, etc.)
+// @Tags settings
+// @Produce json
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /sector-settings [get]
+func (h *HTTPHandler) GetSectorSettings(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	settings, err := h.sectorSettingsRepository.GetBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar configurações em /sector-settings: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar configurações do setor: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Configurações do setor", settings))
+}
+
+// @Summary Update sector settings
+// @Description Update the behaviour settings of a sector. Omitted fields keep their current value
+// @Tags settings
+// @Accept json
+// @Produce json
+// @Param request body models.SectorSettings true "Sector settings"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /sector-settings [put]
+func (h *HTTPHandler) UpdateSectorSettings(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	settings, err := h.sectorSettingsRepository.GetBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar configurações em /sector-settings: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar configurações do setor: "+err.Error()))
+		return
+	}
+
+	// Decodificar sobre as configurações atuais para permitir atualização parcial
+	if err := json.NewDecoder(r.Body).Decode(settings); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+	settings.SectorID = sectorID
+
+	if !models.IsValidCallPolicy(settings.CallPolicy) {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("call_policy deve ser ignore, reject ou reject_message"))
+		return
+	}
+
+	if !models.IsValidSignaturePlacement(settings.SignaturePlacement) {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("signature_placement deve ser prefix, suffix ou none"))
+		return
+	}
+
+	settings.DefaultRegion = strings.ToUpper(strings.TrimSpace(settings.DefaultRegion))
+	if !phone.IsValidRegion(settings.DefaultRegion) {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("default_region deve ser um código de país ISO 3166-1 válido, ex: BR"))
+		return
+	}
+
+	if settings.SectorRatePerMinute < 0 || settings.SectorBurst < 0 || settings.RecipientRatePerMinute < 0 ||
+		settings.RecipientBurst < 0 || settings.SendJitterMaxMs < 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Os limites de envio não podem ser negativos"))
+		return
+	}
+
+	if err := h.sectorSettingsRepository.Save(settings); err != nil {
+		utils.LogError("Erro ao salvar configurações em /sector-settings: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao salvar configurações do setor: "+err.Error()))
+		return
+	}
+	phone.InvalidateRegion(sectorID)
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Configurações do setor atualizadas com sucesso", settings))
+}
+
+// @Summary Get user signature
+// @Description Get the signature override of an agent. When absent the sector signature is used
+// @Tags settings
+// @Produce json
+// @Param id path int true "ID do usuário"
+// @Success 200 {object} models.APIResponse
+// @Router /users/{id}/signature [get]
+func (h *HTTPHandler) GetUserSignature(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID do usuário inválido"))
+		return
+	}
+
+	signature, err := h.userSignatureRepository.GetByUser(userID)
+	if err != nil {
+		utils.LogError("Erro ao buscar assinatura do usuário %d: %v", userID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar assinatura: "+err.Error()))
+		return
+	}
+	if signature == nil {
+		signature = &models.UserSignature{UserID: userID}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Assinatura do usuário", signature))
+}
+
+// @Summary Update user signature
+// @Description Override the sector signature template and/or placement for an agent. Empty fields inherit the sector value
+// @Tags settings
+// @Accept json
+// @Produce json
+// @Param id path int true "ID do usuário"
+// @Param request body models.UserSignature true "User signature"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /users/{id}/signature [put]
+func (h *HTTPHandler) UpdateUserSignature(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID do usuário inválido"))
+		return
+	}
+
+	var signature models.UserSignature
+	if err := json.NewDecoder(r.Body).Decode(&signature); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+	signature.UserID = userID
+
+	if signature.SignaturePlacement != "" && !models.IsValidSignaturePlacement(signature.SignaturePlacement) {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("signature_placement deve ser prefix, suffix ou none"))
+		return
+	}
+
+	if err := h.userSignatureRepository.Save(&signature); err != nil {
+		utils.LogError("Erro ao salvar assinatura do usuário %d: %v", userID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao salvar assinatura: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Assinatura do usuário atualizada com sucesso", signature))
+}
+
+// @Summary Delete user signature
+// @Description Remove the signature override of an agent so the sector signature is used again
+// @Tags settings
+// @Produce json
+// @Param id path int true "ID do usuário"
+// @Success 200 {object} models.APIResponse
+// @Router /users/{id}/signature [delete]
+func (h *HTTPHandler) DeleteUserSignature(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID do usuário inválido"))
+		return
+	}
+
+	if err := h.userSignatureRepository.Delete(userID); err != nil {
+		utils.LogError("Erro ao remover assinatura do usuário %d: %v", userID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao remover assinatura: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Assinatura do usuário removida", nil))
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/services"
+	"whatsapp-bot/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Create a message template
+// @Description Create a sector template. Content accepts placeholders such as {{contact.name}}, {{contact.number}}, {{agent.name}}, {{date}}, {{time}} and any custom variable
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param request body models.MessageTemplateRequest true "Template details"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /templates [post]
+func (h *HTTPHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req models.MessageTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	if req.SectorID == 0 || req.Name == "" {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Missing required fields"))
+		return
+	}
+
+	template := &models.MessageTemplate{SectorID: req.SectorID}
+	if !h.applyTemplateRequest(w, template, &req) {
+		return
+	}
+
+	if err := h.templateRepository.Create(template); err != nil {
+		utils.LogError("Erro ao criar template em /templates: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao criar template: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusCreated, models.NewSuccessResponse("Template criado com sucesso", template))
+}
+
+// @Summary List message templates
+// @Description List the templates of a sector
+// @Tags templates
+// @Produce json
+// @Param sector_id query int true "ID do setor" minimum(1)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /templates [get]
+func (h *HTTPHandler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	var sectorID int
+	if _, err := fmt.Sscanf(r.URL.Query().Get("sector_id"), "%d", &sectorID); err != nil || sectorID == 0 {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O ID do setor deve ser um número válido"))
+		return
+	}
+
+	templates, err := h.templateRepository.ListBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao listar templates em /templates: %v", err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao listar templates: "+err.Error()))
+		return
+	}
+	if templates == nil {
+		templates = []*models.MessageTemplate{}
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Templates do setor", templates))
+}
+
+// @Summary Get a message template
+// @Tags templates
+// @Produce json
+// @Param id path int true "ID do template"
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /templates/{id} [get]
+func (h *HTTPHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.templateFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Template", template))
+}
+
+// @Summary Update a message template
+// @Description Update name, content or attachment of a template. Fields not sent keep their current value
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "ID do template"
+// @Param request body models.MessageTemplateRequest true "Template details"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /templates/{id} [put]
+func (h *HTTPHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.templateFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	req := models.MessageTemplateRequest{
+		Name:     template.Name,
+		Kind:     template.Kind,
+		Content:  template.Content,
+		FileName: template.FileName,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar requisição: "+err.Error()))
+		return
+	}
+
+	if !h.applyTemplateRequest(w, template, &req) {
+		return
+	}
+
+	if err := h.templateRepository.Update(template); err != nil {
+		utils.LogError("Erro ao atualizar template %d: %v", template.ID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao atualizar template: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Template atualizado com sucesso", template))
+}
+
+// @Summary Delete a message template
+// @Tags templates
+// @Produce json
+// @Param id path int true "ID do template"
+// @Success 200 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /templates/{id} [delete]
+func (h *HTTPHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.templateFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// O anexo não é removido do armazenamento: mensagens agendadas e campanhas podem continuar usando a mesma chave
+	if err := h.templateRepository.Delete(template.ID); err != nil {
+		utils.LogError("Erro ao excluir template %d: %v", template.ID, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao excluir template: "+err.Error()))
+		return
+	}
+
+	models.RespondWithJSON(w, http.StatusOK, models.NewSuccessResponse("Template excluído com sucesso", nil))
+}
+
+func (h *HTTPHandler) templateFromRequest(w http.ResponseWriter, r *http.Request) (*models.MessageTemplate, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("ID do template inválido"))
+		return nil, false
+	}
+
+	template, err := h.templateRepository.GetByID(id)
+	if err != nil {
+		utils.LogError("Erro ao buscar template %d: %v", id, err)
+		models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao buscar template: "+err.Error()))
+		return nil, false
+	}
+	if template == nil {
+		models.RespondWithJSON(w, http.StatusNotFound, models.NewErrorResponse("Template não encontrado"))
+		return nil, false
+	}
+
+	return template, true
+}
+
+// applyTemplateRequest valida a requisição e a aplica ao template, guardando o anexo novo no armazenamento quando enviado
+func (h *HTTPHandler) applyTemplateRequest(w http.ResponseWriter, template *models.MessageTemplate, req *models.MessageTemplateRequest) bool {
+	if req.Kind == "" {
+		req.Kind = models.OutboundKindText
+	}
+
+	switch req.Kind {
+	case models.OutboundKindText:
+		if req.Content == "" {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O campo content é obrigatório para templates de texto"))
+			return false
+		}
+	case models.OutboundKindImage, models.OutboundKindAudio, models.OutboundKindDocument:
+		if req.Base64File == "" && template.MediaKey == "" {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("O campo base64File é obrigatório para templates de mídia"))
+			return false
+		}
+	default:
+		models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("kind deve ser text, image, audio ou document"))
+		return false
+	}
+
+	template.Name = req.Name
+	template.Kind = req.Kind
+	template.Content = req.Content
+	template.FileName = req.FileName
+
+	if req.Kind == models.OutboundKindText {
+		template.MediaKey = ""
+		template.FileName = ""
+		return true
+	}
+
+	if req.Base64File != "" {
+		base64Data := req.Base64File
+		if i := strings.Index(base64Data, ";base64,"); i > -1 {
+			base64Data = base64Data[i+8:]
+		}
+
+		fileBytes, err := base64.StdEncoding.DecodeString(base64Data)
+		if err != nil {
+			models.RespondWithJSON(w, http.StatusBadRequest, models.NewErrorResponse("Erro ao decodificar base64: "+err.Error()))
+			return false
+		}
+
+		mediaKey, err := h.storeMedia(services.TemplateMediaKey(template.SectorID, mediaFileName(req.FileName)), fileBytes)
+		if err != nil {
+			utils.LogError("Erro ao guardar anexo do template: %v", err)
+			models.RespondWithJSON(w, http.StatusInternalServerError, models.NewErrorResponse("Erro ao guardar arquivo: "+err.Error()))
+			return false
+		}
+		template.MediaKey = mediaKey
+	}
+
+	return true
+}
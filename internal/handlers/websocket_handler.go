@@ -1,14 +1,22 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
+	"whatsapp-bot/internal/models"
 	"whatsapp-bot/internal/wsnotify"
 )
 
-func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+// ClientMessage define os comandos enviados pelo frontend através do WebSocket
+type ClientMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (h *HTTPHandler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	// Extrair sector_id do parâmetro de consulta
 	sectorIDStr := r.URL.Query().Get("sector_id")
 	if sectorIDStr == "" {
@@ -35,18 +43,46 @@ func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	wsnotify.Manager.AddClient(conn, sectorID)
 	fmt.Printf("[DEBUG-WS] Conexão WebSocket estabelecida para setor %d\n", sectorID)
 
+	// O setor fica disponível no WhatsApp apenas enquanto algum atendente está com o painel aberto. As chamadas são
+	// síncronas para que a abertura e o fechamento do mesmo painel não sejam aplicados fora de ordem
+	h.connectionManager.AgentSessionOpened(sectorID)
+
 	defer func() {
 		wsnotify.Manager.RemoveClient(conn)
 		conn.Close()
+		h.connectionManager.AgentSessionClosed(sectorID)
 		fmt.Printf("[DEBUG-WS] Conexão WebSocket fechada para setor %d\n", sectorID)
 	}()
 
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			fmt.Printf("[DEBUG-WS] Erro na leitura da mensagem do setor %d: %v\n", sectorID, err)
 			break
 		}
+
+		h.handleClientMessage(sectorID, data)
+	}
+}
+
+// handleClientMessage executa os comandos recebidos do frontend pelo WebSocket
+func (h *HTTPHandler) handleClientMessage(sectorID int, data []byte) {
+	var message ClientMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return
+	}
+
+	switch message.Type {
+	case "presence":
+		var req models.PresenceRequest
+		if err := json.Unmarshal(message.Payload, &req); err != nil {
+			fmt.Printf("[DEBUG-WS] Payload de presença inválido do setor %d: %v\n", sectorID, err)
+			return
+		}
+		// O setor é sempre o da conexão WebSocket
+		if err := h.connectionManager.SetChatPresence(sectorID, req.Recipient, req.State, req.Duration); err != nil {
+			fmt.Printf("[DEBUG-WS] Erro ao enviar presença do setor %d: %v\n", sectorID, err)
+		}
 	}
 }
 
@@ -0,0 +1,195 @@
+// Package imageproc prepara as imagens enviadas pelo WhatsApp: remove os metadados (EXIF com
+// localização, comentários), reduz imagens maiores que o recomendado pelo WhatsApp e gera a
+// miniatura exibida antes do download e a prévia usada no painel dos atendentes.
+//
+// Usa apenas a biblioteca padrão; formatos que ela não decodifica (ex: WebP) são enviados como estão.
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxDimension é o maior lado aceito; imagens maiores são reduzidas, como o próprio app do WhatsApp faz
+	MaxDimension = 1600
+	// ThumbnailDimension é o maior lado do JPEGThumbnail da ImageMessage
+	ThumbnailDimension = 72
+	// PreviewDimension é o maior lado da prévia exibida no painel
+	PreviewDimension = 320
+	// MaxPixels é o maior número de pixels decodificado; acima disso a imagem não é carregada em memória
+	MaxPixels = 50_000_000
+
+	jpegQuality      = 85
+	thumbnailQuality = 60
+	previewQuality   = 75
+)
+
+var (
+	// ErrUnsupported é retornado quando o formato não pode ser decodificado
+	ErrUnsupported = errors.New("formato de imagem não suportado")
+	// ErrTooLarge é retornado quando a imagem tem mais pixels que MaxPixels
+	ErrTooLarge = errors.New("imagem com resolução maior que o limite para processamento")
+)
+
+// Result é a imagem pronta para envio
+type Result struct {
+	Data      []byte
+	MimeType  string
+	Width     int
+	Height    int
+	Thumbnail []byte // JPEG pequeno para o campo JPEGThumbnail
+	Preview   []byte // JPEG para o painel dos atendentes
+}
+
+// Process remove os metadados, reduz a imagem quando necessário e gera a miniatura e a prévia.
+// Formatos não suportados retornam a imagem original, sem miniatura, junto com ErrUnsupported.
+// JPEGs e PNGs que não podem ser decodificados retornam sem miniatura, mas já sem os metadados
+func Process(data []byte) (*Result, error) {
+	mimeType := http.DetectContentType(data)
+	result := &Result{Data: data, MimeType: mimeType}
+
+	var img image.Image
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		img, err = processJPEG(data, result)
+	case "image/png":
+		img, err = processPNG(data, result)
+	case "image/gif":
+		// GIFs não têm EXIF; apenas o primeiro quadro é usado na miniatura
+		if err = checkPixels(data); err == nil {
+			img, err = gif.Decode(bytes.NewReader(data))
+		}
+	default:
+		return result, ErrUnsupported
+	}
+	if err != nil {
+		return result, fmt.Errorf("erro ao processar imagem: %v", err)
+	}
+
+	bounds := img.Bounds()
+	result.Width, result.Height = bounds.Dx(), bounds.Dy()
+
+	if result.Thumbnail, err = encodeJPEG(fit(img, ThumbnailDimension), thumbnailQuality); err != nil {
+		return result, fmt.Errorf("erro ao gerar miniatura: %v", err)
+	}
+	if result.Preview, err = encodeJPEG(fit(img, PreviewDimension), previewQuality); err != nil {
+		return result, fmt.Errorf("erro ao gerar prévia: %v", err)
+	}
+	return result, nil
+}
+
+// Preview gera apenas a prévia do painel, usada para as imagens recebidas
+func Preview(data []byte) ([]byte, error) {
+	if err := checkPixels(data); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if http.DetectContentType(data) == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	return encodeJPEG(fit(img, PreviewDimension), previewQuality)
+}
+
+// Thumbnail reduz a imagem para que o maior lado tenha no máximo maxDimension e a codifica em JPEG,
+// retornando também as dimensões da miniatura
+func Thumbnail(data []byte, maxDimension int) ([]byte, int, int, error) {
+	if err := checkPixels(data); err != nil {
+		return nil, 0, 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	img = fit(img, maxDimension)
+
+	encoded, err := encodeJPEG(img, thumbnailQuality)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	bounds := img.Bounds()
+	return encoded, bounds.Dx(), bounds.Dy(), nil
+}
+
+// processJPEG aplica a orientação do EXIF nos pixels antes de descartá-lo. Quando a imagem não precisa
+// ser girada nem reduzida, ou não pode ser decodificada, os metadados são removidos sem recompressão
+func processJPEG(data []byte, result *Result) (image.Image, error) {
+	result.Data = stripJPEGMetadata(data)
+	if err := checkPixels(data); err != nil {
+		return nil, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := jpegOrientation(data)
+	if orientation <= 1 && !oversized(img) {
+		return img, nil
+	}
+
+	img = orient(fit(img, MaxDimension), orientation)
+	if result.Data, err = encodeJPEG(img, jpegQuality); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func processPNG(data []byte, result *Result) (image.Image, error) {
+	result.Data = stripPNGMetadata(data)
+	if err := checkPixels(data); err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if !oversized(img) {
+		return img, nil
+	}
+
+	img = fit(img, MaxDimension)
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	result.Data = buffer.Bytes()
+	return img, nil
+}
+
+// checkPixels lê apenas o cabeçalho e recusa imagens que ocupariam memória demais ao serem decodificadas
+func checkPixels(data []byte) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	return nil
+}
+
+func oversized(img image.Image) bool {
+	bounds := img.Bounds()
+	return bounds.Dx() > MaxDimension || bounds.Dy() > MaxDimension
+}
+
+// encodeJPEG codifica sobre fundo branco, já que o JPEG não tem transparência
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, flatten(img), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Marcadores JPEG usados na leitura dos segmentos
+const (
+	jpegSOI  = 0xD8
+	jpegSOS  = 0xDA
+	jpegAPP0 = 0xE0
+	jpegAPP1 = 0xE1
+	jpegAPP2 = 0xE2 // perfil de cor ICC
+	jpegAPPE = 0xEE // Adobe, necessário para interpretar JPEGs CMYK
+	jpegAPPF = 0xEF
+	jpegCOM  = 0xFE
+
+	exifOrientationTag = 0x0112
+)
+
+// jpegSegments percorre os segmentos do cabeçalho JPEG (até o início dos dados da imagem), chamando visit
+// com o marcador, o segmento completo e o conteúdo. Retorna a posição onde começam os dados (SOS) ou, se o
+// cabeçalho estiver incompleto ou corrompido, -1 e a posição onde a leitura parou
+func jpegSegments(data []byte, visit func(marker byte, segment []byte, payload []byte)) (int, int) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return -1, 0
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return -1, pos
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// Bytes de preenchimento entre segmentos
+			pos++
+			continue
+		}
+		if marker == jpegSOS {
+			return pos, pos
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return -1, pos
+		}
+		visit(marker, data[pos:end], data[pos+4:end])
+		pos = end
+	}
+	return -1, pos
+}
+
+// jpegOrientation lê a orientação do EXIF (1 quando ausente)
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	jpegSegments(data, func(marker byte, _ []byte, payload []byte) {
+		if marker == jpegAPP1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			if value := exifOrientation(payload[6:]); value != 0 {
+				orientation = value
+			}
+		}
+	})
+	return orientation
+}
+
+// exifOrientation procura a tag de orientação no primeiro IFD do bloco TIFF do EXIF
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == exifOrientationTag {
+			value := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// stripJPEGMetadata remove o EXIF, XMP, IPTC e comentários sem recomprimir a imagem. São mantidos o JFIF,
+// o perfil ICC e o segmento Adobe, que afetam as cores. Em arquivos truncados ou corrompidos, o trecho que não
+// pôde ser lido é mantido, exceto quando começa em um segmento de metadados
+func stripJPEGMetadata(data []byte) []byte {
+	stripped := make([]byte, 0, len(data))
+	stripped = append(stripped, 0xFF, jpegSOI)
+
+	start, stop := jpegSegments(data, func(marker byte, segment []byte, _ []byte) {
+		if !isJPEGMetadata(marker) {
+			stripped = append(stripped, segment...)
+		}
+	})
+	if start >= 0 {
+		return append(stripped, data[start:]...)
+	}
+	if stop == 0 {
+		return data
+	}
+	if stop+1 < len(data) && data[stop] == 0xFF && isJPEGMetadata(data[stop+1]) {
+		return stripped
+	}
+	return append(stripped, data[stop:]...)
+}
+
+// isJPEGMetadata indica os segmentos descartados: comentários e blocos APP que não afetam as cores
+func isJPEGMetadata(marker byte) bool {
+	isAPP := marker >= jpegAPP0 && marker <= jpegAPPF
+	return marker == jpegCOM || (isAPP && marker != jpegAPP0 && marker != jpegAPP2 && marker != jpegAPPE)
+}
+
+// Chunks PNG descartados: textos, EXIF e data de modificação
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// stripPNGMetadata remove os chunks de metadados sem recodificar a imagem. Em arquivos truncados, o chunk
+// incompleto é mantido apenas se não for de metadados
+func stripPNGMetadata(data []byte) []byte {
+	const signatureLength = 8
+	if len(data) < signatureLength {
+		return data
+	}
+
+	stripped := make([]byte, 0, len(data))
+	stripped = append(stripped, data[:signatureLength]...)
+
+	pos := signatureLength
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length // tamanho, tipo, dados e CRC
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || end > len(data) {
+			end = len(data)
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			stripped = append(stripped, data[pos:end]...)
+		}
+		pos = end
+
+		if chunkType == "IEND" {
+			return stripped
+		}
+	}
+	return stripped
+}
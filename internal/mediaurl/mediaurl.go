@@ -0,0 +1,200 @@
+// Package mediaurl converte as chaves de mídia gravadas no banco em links temporários.
+// As mensagens e os avatares guardam apenas a chave do objeto; o link assinado é gerado
+// no momento em que o dado é enviado ao frontend (REST ou WebSocket).
+package mediaurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"whatsapp-bot/internal/utils"
+)
+
+// DefaultTTL é a validade padrão dos links gerados
+const DefaultTTL = 15 * time.Minute
+
+// Signer gera um link temporário para a chave no armazenamento de mídia
+type Signer func(key string, expires time.Duration) (string, error)
+
+var (
+	mutex          sync.RWMutex
+	signer         Signer
+	ttl            = DefaultTTL
+	legacyPrefixes []string
+)
+
+// Configure define o gerador de links e a validade deles. legacyPrefixes são os endereços públicos
+// usados antes das chaves (ex: URL do bucket), para que links antigos gravados no banco também sejam assinados
+func Configure(s Signer, linkTTL time.Duration, prefixes ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	signer = s
+	if linkTTL > 0 {
+		ttl = linkTTL
+	}
+	legacyPrefixes = nil
+	for _, prefix := range prefixes {
+		if prefix = strings.TrimRight(prefix, "/"); prefix != "" {
+			legacyPrefixes = append(legacyPrefixes, prefix+"/")
+		}
+	}
+}
+
+// TTL retorna a validade dos links gerados
+func TTL() time.Duration {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return ttl
+}
+
+// Key extrai a chave do objeto a partir do valor gravado no banco, que pode ser a própria chave
+// ou um link público antigo do armazenamento. Retorna vazio para links externos.
+func Key(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "null" {
+		return ""
+	}
+	if !strings.Contains(value, "://") {
+		return strings.TrimPrefix(value, "/")
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+	for _, prefix := range legacyPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			key := strings.TrimPrefix(value, prefix)
+			if i := strings.Index(key, "?"); i >= 0 {
+				key = key[:i]
+			}
+			if unescaped, err := url.PathUnescape(key); err == nil {
+				key = unescaped
+			}
+			return key
+		}
+	}
+	return ""
+}
+
+// Sign retorna o link temporário para o valor gravado no banco. Links externos são mantidos como estão.
+func Sign(value string) string {
+	key := Key(value)
+	if key == "" {
+		if value == "null" {
+			return ""
+		}
+		return value
+	}
+
+	mutex.RLock()
+	s, linkTTL := signer, ttl
+	mutex.RUnlock()
+	if s == nil {
+		return value
+	}
+
+	signed, err := s(key, linkTTL)
+	if err != nil {
+		utils.LogError("Erro ao gerar link da mídia %s: %v", key, err)
+		return ""
+	}
+	return signed
+}
+
+// SignPtr é a versão de Sign para os campos opcionais dos eventos
+func SignPtr(value *string) *string {
+	if value == nil || *value == "" {
+		return value
+	}
+	signed := Sign(*value)
+	return &signed
+}
+
+// Tipos de recurso dos endereços estáveis de mídia
+const (
+	ResourceMessage = "message"
+	ResourceContact = "contact"
+)
+
+// DefaultResourceTTL é a validade padrão dos endereços estáveis
+const DefaultResourceTTL = 7 * 24 * time.Hour
+
+var (
+	resourceKey  []byte
+	resourceBase string
+	resourceTTL  = DefaultResourceTTL
+)
+
+// ConfigureResources define a chave que assina os endereços estáveis (/messages/{id}/media, /contacts/{id}/avatar),
+// o endereço público da API usado para montá-los e a validade deles
+func ConfigureResources(key string, baseURL string, linkTTL time.Duration) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	resourceKey = []byte(key)
+	resourceBase = strings.TrimRight(baseURL, "/")
+	resourceTTL = DefaultResourceTTL
+	if linkTTL > 0 {
+		resourceTTL = linkTTL
+	}
+}
+
+// ResourceToken assina o par (setor, ID) do recurso até o instante expiresAt (Unix). O token substitui a
+// autenticação nos endereços estáveis, que geram um link temporário novo a cada acesso; a validade longa permite
+// guardá-los, e o frontend recebe um endereço novo sempre que a mensagem ou o contato é carregado
+func ResourceToken(kind string, sectorID int, id int, expiresAt int64) string {
+	mutex.RLock()
+	key := resourceKey
+	mutex.RUnlock()
+	if len(key) == 0 {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind + "\n" + strconv.Itoa(sectorID) + "\n" + strconv.Itoa(id) + "\n" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyResourceToken confere o token e a validade de um endereço estável. Sem chave configurada, nenhum token é aceito
+func VerifyResourceToken(kind string, sectorID int, id int, expires string, token string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+	expected := ResourceToken(kind, sectorID, id, expiresAt)
+	return expected != "" && hmac.Equal([]byte(token), []byte(expected))
+}
+
+// MessageMediaPath retorna o endereço estável da mídia da mensagem, que pode ser guardado pelos clientes
+func MessageMediaPath(sectorID int, messageID int) string {
+	return resourcePath(fmt.Sprintf("/messages/%d/media", messageID), ResourceMessage, sectorID, messageID)
+}
+
+// ContactAvatarPath retorna o endereço estável do avatar do contato
+func ContactAvatarPath(sectorID int, contactID int) string {
+	return resourcePath(fmt.Sprintf("/contacts/%d/avatar", contactID), ResourceContact, sectorID, contactID)
+}
+
+func resourcePath(path string, kind string, sectorID int, id int) string {
+	mutex.RLock()
+	base, linkTTL := resourceBase, resourceTTL
+	mutex.RUnlock()
+
+	expiresAt := time.Now().Add(linkTTL).Unix()
+	token := ResourceToken(kind, sectorID, id, expiresAt)
+	if token == "" {
+		return ""
+	}
+
+	query := url.Values{}
+	query.Set("sector_id", strconv.Itoa(sectorID))
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("token", token)
+	return base + path + "?" + query.Encode()
+}
@@ -0,0 +1,160 @@
+package mediaurl
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	Configure(nil, DefaultTTL, "https://bucket.s3.amazonaws.com", "http://localhost:8081/api/v1/media/")
+	t.Cleanup(func() { Configure(nil, DefaultTTL) })
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "chave", value: "sector_1/images/abc.jpg", want: "sector_1/images/abc.jpg"},
+		{name: "chave com barra inicial", value: "/sector_1/images/abc.jpg", want: "sector_1/images/abc.jpg"},
+		{name: "vazio", value: "", want: ""},
+		{name: "null gravado no banco", value: "null", want: ""},
+		{name: "link público antigo do bucket", value: "https://bucket.s3.amazonaws.com/sector_1/images/abc.jpg", want: "sector_1/images/abc.jpg"},
+		{name: "link antigo com query e escape", value: "https://bucket.s3.amazonaws.com/sector_1/documents/a%20b.pdf?x=1", want: "sector_1/documents/a b.pdf"},
+		{name: "link do armazenamento local", value: "http://localhost:8081/api/v1/media/sector_1/audios/a.ogg", want: "sector_1/audios/a.ogg"},
+		{name: "link externo", value: "https://example.com/a.jpg", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.value); got != tt.want {
+				t.Errorf("Key(%q) = %q, esperado %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	signer := func(key string, expires time.Duration) (string, error) {
+		if key == "falha.jpg" {
+			return "", errors.New("erro do armazenamento")
+		}
+		return "https://signed/" + key + "?ttl=" + expires.String(), nil
+	}
+	Configure(signer, time.Minute, "https://bucket.s3.amazonaws.com")
+	t.Cleanup(func() { Configure(nil, DefaultTTL) })
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "chave é assinada com a validade configurada", value: "sector_1/images/abc.jpg", want: "https://signed/sector_1/images/abc.jpg?ttl=1m0s"},
+		{name: "link antigo é assinado pela chave", value: "https://bucket.s3.amazonaws.com/sector_1/a.jpg", want: "https://signed/sector_1/a.jpg?ttl=1m0s"},
+		{name: "link externo é mantido", value: "https://example.com/a.jpg", want: "https://example.com/a.jpg"},
+		{name: "null vira vazio", value: "null", want: ""},
+		{name: "erro ao assinar vira vazio", value: "falha.jpg", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.value); got != tt.want {
+				t.Errorf("Sign(%q) = %q, esperado %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceToken(t *testing.T) {
+	ConfigureResources("chave-de-teste-com-16+", "https://api.example.com/api/v1/", 0)
+	t.Cleanup(func() { ConfigureResources("", "", 0) })
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	expires := strconv.FormatInt(expiresAt, 10)
+	token := ResourceToken(ResourceMessage, 1, 42, expiresAt)
+	expiredAt := time.Now().Add(-time.Minute).Unix()
+	expiredToken := ResourceToken(ResourceMessage, 1, 42, expiredAt)
+
+	tests := []struct {
+		name     string
+		kind     string
+		sectorID int
+		id       int
+		expires  string
+		token    string
+		want     bool
+	}{
+		{name: "token válido", kind: ResourceMessage, sectorID: 1, id: 42, expires: expires, token: token, want: true},
+		{name: "outro setor", kind: ResourceMessage, sectorID: 2, id: 42, expires: expires, token: token, want: false},
+		{name: "outro ID", kind: ResourceMessage, sectorID: 1, id: 43, expires: expires, token: token, want: false},
+		{name: "outro tipo de recurso", kind: ResourceContact, sectorID: 1, id: 42, expires: expires, token: token, want: false},
+		{name: "validade estendida", kind: ResourceMessage, sectorID: 1, id: 42, expires: strconv.FormatInt(expiresAt+3600, 10), token: token, want: false},
+		{name: "expirado", kind: ResourceMessage, sectorID: 1, id: 42, expires: strconv.FormatInt(expiredAt, 10), token: expiredToken, want: false},
+		{name: "sem validade", kind: ResourceMessage, sectorID: 1, id: 42, expires: "", token: token, want: false},
+		{name: "sem token", kind: ResourceMessage, sectorID: 1, id: 42, expires: expires, token: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyResourceToken(tt.kind, tt.sectorID, tt.id, tt.expires, tt.token); got != tt.want {
+				t.Errorf("VerifyResourceToken(%s, %d, %d) = %v, esperado %v", tt.kind, tt.sectorID, tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceTokenWithoutKey(t *testing.T) {
+	ConfigureResources("", "https://api.example.com", 0)
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	if token := ResourceToken(ResourceMessage, 1, 42, expiresAt); token != "" {
+		t.Fatalf("ResourceToken sem chave = %q, esperado vazio", token)
+	}
+	if VerifyResourceToken(ResourceMessage, 1, 42, strconv.FormatInt(expiresAt, 10), "") {
+		t.Fatal("VerifyResourceToken sem chave aceitou o token vazio")
+	}
+	if path := MessageMediaPath(1, 42); path != "" {
+		t.Fatalf("MessageMediaPath sem chave = %q, esperado vazio", path)
+	}
+}
+
+func TestResourcePaths(t *testing.T) {
+	ConfigureResources("chave-de-teste-com-16+", "https://api.example.com/api/v1/", time.Hour)
+	t.Cleanup(func() { ConfigureResources("", "", 0) })
+
+	tests := []struct {
+		name     string
+		path     string
+		wantPath string
+		kind     string
+		id       int
+	}{
+		{name: "mídia da mensagem", path: MessageMediaPath(3, 42), wantPath: "/api/v1/messages/42/media", kind: ResourceMessage, id: 42},
+		{name: "avatar do contato", path: ContactAvatarPath(3, 7), wantPath: "/api/v1/contacts/7/avatar", kind: ResourceContact, id: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := url.Parse(tt.path)
+			if err != nil {
+				t.Fatalf("endereço inválido %q: %v", tt.path, err)
+			}
+			if parsed.Host != "api.example.com" || parsed.Path != tt.wantPath {
+				t.Errorf("endereço = %s, esperado https://api.example.com%s", tt.path, tt.wantPath)
+			}
+			query := parsed.Query()
+			if query.Get("sector_id") != "3" {
+				t.Errorf("sector_id = %q, esperado 3", query.Get("sector_id"))
+			}
+			if !VerifyResourceToken(tt.kind, 3, tt.id, query.Get("expires"), query.Get("token")) {
+				t.Errorf("token do endereço %s não confere", tt.path)
+			}
+			expiresAt, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+			if remaining := time.Until(time.Unix(expiresAt, 0)); remaining <= 0 || remaining > time.Hour {
+				t.Errorf("validade do endereço %s = %v, esperado até 1h", tt.path, remaining)
+			}
+		})
+	}
+}
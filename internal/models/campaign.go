@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Estados de uma campanha de envio em massa
+const (
+	CampaignStatusDraft     = "draft"     // Criada, aguardando destinatários e início
+	CampaignStatusRunning   = "running"   // Despachando destinatários
+	CampaignStatusPaused    = "paused"    // Pausada, nenhum novo destinatário é despachado
+	CampaignStatusCanceled  = "canceled"  // Cancelada, destinatários restantes não serão enviados
+	CampaignStatusCompleted = "completed" // Todos os destinatários foram processados
+)
+
+// Estados de um destinatário da campanha. A ordem de sent, delivered e read
+// acompanha os recibos do WhatsApp e nunca regride
+const (
+	CampaignRecipientQueued    = "queued"    // Aguardando sua vez na campanha
+	CampaignRecipientSending   = "sending"   // Entregue à fila de envio do setor
+	CampaignRecipientSent      = "sent"      // Enviada ao WhatsApp
+	CampaignRecipientDelivered = "delivered" // Entregue no aparelho do cliente
+	CampaignRecipientRead      = "read"      // Lida pelo cliente
+	CampaignRecipientFailed    = "failed"    // Não pôde ser enviada
+	CampaignRecipientCanceled  = "canceled"  // Campanha cancelada antes do envio
+)
+
+// DefaultCampaignRatePerMinute é o ritmo de despacho quando a campanha não informa um
+const DefaultCampaignRatePerMinute = 20
+
+type Campaign struct {
+	ID            int64          `json:"id"`
+	SectorID      int            `json:"sector_id"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	Content       string         `json:"content"`
+	MediaKey      string         `json:"media_key,omitempty"`
+	FileName      string         `json:"file_name,omitempty"`
+	UserID        *int           `json:"user_id,omitempty"`
+	IsAnonymous   bool           `json:"is_anonymous"`
+	RatePerMinute int            `json:"rate_per_minute"`
+	Status        string         `json:"status"`
+	Counts        map[string]int `json:"counts,omitempty"` // Quantidade de destinatários por status
+	StartedAt     *time.Time     `json:"started_at,omitempty"`
+	FinishedAt    *time.Time     `json:"finished_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+type CampaignRecipient struct {
+	ID                int64     `json:"id"`
+	CampaignID        int64     `json:"campaign_id"`
+	ContactID         int       `json:"contact_id,omitempty"`
+	Recipient         string    `json:"recipient"`
+	Name              string    `json:"name,omitempty"`
+	Status            string    `json:"status"`
+	OutboundMessageID int64     `json:"outbound_message_id,omitempty"`
+	WhatsAppMessageID string    `json:"whatsapp_message_id,omitempty"`
+	LastError         string    `json:"last_error,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type CampaignRepository interface {
+	Create(campaign *Campaign) error
+	GetByID(id int64) (*Campaign, error)
+	ListBySector(sectorID int, limit int) ([]*Campaign, error)
+	ListRunning() ([]*Campaign, error)
+	UpdateStatus(id int64, status string, fromStatuses ...string) error
+	AddRecipients(campaignID int64, recipients []*CampaignRecipient) (int, error)
+	AddContactRecipients(campaign *Campaign, contactIDs []int) (int, error)
+	AddTagRecipients(campaign *Campaign, tagID int) (int, error)
+	CountByStatus(campaignID int64) (map[string]int, error)
+	ClaimRecipients(campaignID int64, limit int) ([]*CampaignRecipient, error)
+	GetRecipientByID(id int64) (*CampaignRecipient, error)
+	SetRecipientOutbound(id int64, outboundMessageID int64) error
+	SetRecipientMessageID(id int64, whatsappMessageID string) error
+	RequeueRecipient(id int64) error
+	MarkRecipientResult(id int64, status string, whatsappMessageID string, lastError string) error
+	UpdateRecipientReceipts(sectorID int, whatsappMessageIDs []string, status string) ([]*CampaignRecipient, error)
+	CancelQueuedRecipients(campaignID int64) error
+	ListRecipients(campaignID int64, status string, limit int) ([]*CampaignRecipient, error)
+}
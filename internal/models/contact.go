@@ -6,7 +6,9 @@ type Contact struct {
 	ID            int       `json:"id"`
 	Name          string    `json:"name"`
 	Number        string    `json:"number"`
+	LID           string    `json:"lid"`
 	AvatarURL     string    `json:"avatar_url"`
+	AvatarPath    string    `json:"avatar_path,omitempty"` // Endereço estável do avatar, preenchido nas respostas
 	SectorID      int       `json:"sector_id"`
 	TagID         int       `json:"tag_id"`
 	IsActive      bool      `json:"is_active"`
@@ -23,9 +25,20 @@ type Contact struct {
 	Order         int       `json:"order"`
 }
 
+// DuplicateContactGroup reúne contatos do setor que representam o mesmo número
+type DuplicateContactGroup struct {
+	Number              string     `json:"number"`
+	SuggestedSurvivorID int        `json:"suggested_survivor_id"`
+	Contacts            []*Contact `json:"contacts"`
+}
+
 type ContactRepository interface {
 	Save(contact *Contact) error
 	GetByNumber(sectorID int, number string) (*Contact, error)
+	GetByID(sectorID int, contactID int) (*Contact, error)
+	GetByLID(sectorID int, lid string) (*Contact, error)
+	SetLID(contactID int, lid string) error
+	SetNumber(contactID int, number string) error
 	GetBySector(sectorID int) ([]*Contact, error)
 	Update(contact *Contact) error
 	CreateIfNotExists(sectorID int, number string) (*Contact, error)
@@ -34,4 +47,6 @@ type ContactRepository interface {
 	GetViewedStatus(sectorID int) (map[int]bool, error)
 	SendUnreadStatusUpdate(sectorID int) error
 	UpdateContactOrder(sectorID int, contactID int) error
+	FindDuplicates(sectorID int) ([]*DuplicateContactGroup, error)
+	Merge(sectorID int, survivorID int, duplicateIDs []int) (*Contact, error)
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Estados de uma chave de idempotência
+const (
+	IdempotencyStatusPending   = "pending"   // Requisição original ainda em processamento
+	IdempotencyStatusCompleted = "completed" // Resposta gravada e disponível para replay
+)
+
+// IdempotencyTTL define por quanto tempo a resposta de uma chave fica disponível para replay
+const IdempotencyTTL = 24 * time.Hour
+
+// IdempotencyLockTimeout define por quanto tempo uma chave pendente fica reservada. Se a requisição original
+// não terminar nesse prazo (ex: o processo caiu), uma nova requisição pode assumir a chave
+const IdempotencyLockTimeout = 5 * time.Minute
+
+type IdempotencyRecord struct {
+	SectorID          int
+	Key               string
+	Endpoint          string
+	RequestHash       string
+	Status            string
+	StatusCode        int
+	ResponseBody      []byte
+	OutboundMessageID int64
+	LockedUntil       time.Time
+	ExpiresAt         time.Time
+	CreatedAt         time.Time
+}
+
+type IdempotencyRepository interface {
+	Reserve(record *IdempotencyRecord) (bool, error)
+	Get(sectorID int, key string, endpoint string) (*IdempotencyRecord, error)
+	Complete(record *IdempotencyRecord) error
+	Release(sectorID int, key string, endpoint string) error
+	PurgeExpired(limit int) (int64, error)
+}
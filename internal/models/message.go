@@ -12,6 +12,7 @@ const (
 type Message struct {
 	ID                int
 	Conteudo          string
+	ConteudoEnviado   string // Texto enviado ao WhatsApp, incluindo a assinatura do atendente
 	Tipo              string
 	URL               string
 	NomeArquivo       string
@@ -36,4 +37,6 @@ type MessageRepository interface {
 	GetByContact(sectorID int, contactID string, limit int) ([]*Message, error)
 	UpdateMessageStatus(messageID int, status string) error
 	MarkMessagesAsRead(messageIDs []int) error
+	GetUnreadIncoming(sectorID int, contactID int) ([]*Message, error)
+	MarkContactMessagesAsRead(sectorID int, contactID int) (int64, error)
 }
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Estados de uma mensagem na fila de envio
+const (
+	OutboundStatusQueued  = "queued"  // Aguardando envio (ou nova tentativa)
+	OutboundStatusSending = "sending" // Em processamento pelo despachante do setor
+	OutboundStatusSent    = "sent"    // Entregue ao WhatsApp
+	OutboundStatusDead    = "dead"    // Excedeu as tentativas e precisa de intervenção manual
+)
+
+// Tipos de mensagem aceitos pela fila de envio
+const (
+	OutboundKindText     = "text"
+	OutboundKindImage    = "image"
+	OutboundKindAudio    = "audio"
+	OutboundKindDocument = "document"
+)
+
+// Origem da mensagem enfileirada
+const (
+	OutboundSourceAPI       = "api"
+	OutboundSourceCall      = "call"
+	OutboundSourceScheduled = "scheduled"
+	OutboundSourceCampaign  = "campaign"
+)
+
+// DefaultOutboundMaxAttempts é o número de tentativas antes de mover a mensagem para dead
+const DefaultOutboundMaxAttempts = 10
+
+type OutboundMessage struct {
+	ID                int64     `json:"id"`
+	SectorID          int       `json:"sector_id"`
+	Kind              string    `json:"kind"`
+	Recipient         string    `json:"recipient"`
+	Content           string    `json:"content"`
+	MediaKey          string    `json:"media_key,omitempty"` // Chave do arquivo no S3 para mensagens de mídia
+	FileName          string    `json:"file_name,omitempty"`
+	UserID            *int      `json:"user_id,omitempty"`
+	IsAnonymous       bool      `json:"is_anonymous"`
+	SentAt            time.Time `json:"sent_at"`
+	Status            string    `json:"status"`
+	Attempts          int       `json:"attempts"`
+	MaxAttempts       int       `json:"max_attempts"`
+	NextAttemptAt     time.Time `json:"next_attempt_at"`
+	LastError         string    `json:"last_error,omitempty"`
+	WhatsAppMessageID string    `json:"whatsapp_message_id,omitempty"`
+	PendingMessageID  string    `json:"pending_message_id,omitempty"` // ID reservado na primeira tentativa e reaproveitado nas seguintes
+	Source            string    `json:"source"`
+	SourceID          int64     `json:"source_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type OutboundMessageRepository interface {
+	Enqueue(message *OutboundMessage) error
+	GetByID(id int64) (*OutboundMessage, error)
+	ClaimDue(sectorID int, limit int) ([]*OutboundMessage, error)
+	SetPendingMessageID(id int64, messageID string) error
+	MarkSent(id int64, whatsappMessageID string) error
+	MarkRetry(id int64, lastError string, nextAttemptAt time.Time) error
+	Postpone(id int64, nextAttemptAt time.Time) error
+	MarkDead(id int64, lastError string) error
+	Requeue(id int64) error
+	ListBySector(sectorID int, status string, limit int) ([]*OutboundMessage, error)
+	ReleaseClaimed(ids []int64) error
+	ResetSending() error
+	PendingSectors() ([]int, error)
+}
@@ -0,0 +1,23 @@
+package models
+
+// Estados de presença que o atendente pode exibir em uma conversa
+const (
+	PresenceStateComposing = "composing" // Digitando
+	PresenceStateRecording = "recording" // Gravando áudio
+	PresenceStatePaused    = "paused"    // Parou de digitar/gravar
+)
+
+// Limites da duração de uma indicação de presença, em segundos
+const (
+	DefaultPresenceDuration = 10
+	MaxPresenceDuration     = 60
+)
+
+// IsValidPresenceState verifica se o estado de presença informado é suportado
+func IsValidPresenceState(state string) bool {
+	switch state {
+	case PresenceStateComposing, PresenceStateRecording, PresenceStatePaused:
+		return true
+	}
+	return false
+}
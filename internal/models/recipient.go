@@ -0,0 +1,13 @@
+package models
+
+// Quantidade máxima de números aceitos por requisição de verificação
+const MaxCheckNumbers = 500
+
+// NumberCheckResult informa se um número possui WhatsApp e qual o seu JID canônico
+type NumberCheckResult struct {
+	Input  string `json:"input"`
+	Number string `json:"number,omitempty"` // Número canônico, ex: 5511999999999
+	JID    string `json:"jid,omitempty"`
+	Exists bool   `json:"exists"`
+	Error  string `json:"error,omitempty"`
+}
@@ -5,17 +5,21 @@ import (
 )
 
 type MessageRequest struct {
-	SectorID    int       `json:"sector_id" example:"1" swagger:"required" description:"ID do setor"`
-	Recipient   string    `json:"recipient" example:"5511999999999" swagger:"required" description:"Número do telefone no formato DDDNúmero"`
-	Message     string    `json:"message" example:"Olá, como vai?" swagger:"required" description:"Texto da mensagem"`
-	UserID      *int      `json:"userId"`
-	IsAnonymous bool      `json:"isAnonymous"`
-	SentAt      time.Time `json:"sentAt" swagger:"required" description:"Timestamp do momento do envio da mensagem"`
-	Timezone    string    `json:"timezone" swagger:"required" description:"Timezone do frontend, ex: America/Sao_Paulo"`
+	SectorID    int               `json:"sector_id" example:"1" swagger:"required" description:"ID do setor"`
+	Recipient   string            `json:"recipient" example:"5511999999999" swagger:"required" description:"Número do telefone no formato DDDNúmero"`
+	Message     string            `json:"message" example:"Olá, como vai?" description:"Texto da mensagem (obrigatório quando templateId não é informado)"`
+	TemplateID  int64             `json:"templateId" description:"ID de um template do setor a ser usado no lugar de message"`
+	Variables   map[string]string `json:"variables" description:"Valores para os placeholders do template"`
+	UserID      *int              `json:"userId"`
+	IsAnonymous bool              `json:"isAnonymous"`
+	SentAt      time.Time         `json:"sentAt" swagger:"required" description:"Timestamp do momento do envio da mensagem"`
+	Timezone    string            `json:"timezone" swagger:"required" description:"Timezone do frontend, ex: America/Sao_Paulo"`
 }
 
 type MediaMessageRequest struct {
 	Base64File  string    `json:"base64File"`
+	MediaURL    string    `json:"mediaUrl" description:"Alternativa ao base64File: endereço de onde o arquivo é baixado (apenas hosts permitidos)"`
+	ObjectKey   string    `json:"objectKey" description:"Alternativa ao base64File: chave de um arquivo já enviado por /upload, reaproveitado sem novo upload"`
 	MediaType   string    `json:"mediaType"`
 	FileName    string    `json:"fileName"`
 	Caption     string    `json:"caption"`
@@ -33,9 +37,79 @@ type TypingRequest struct {
 	Duration  int    `json:"duration" example:"5" default:"5" description:"Duração em segundos da indicação de digitação"`
 }
 
+type MergeContactsRequest struct {
+	SectorID     int   `json:"sector_id" example:"1" swagger:"required" description:"ID do setor"`
+	SurvivorID   int   `json:"survivor_id" example:"10" swagger:"required" description:"Contato que permanece após a mesclagem"`
+	DuplicateIDs []int `json:"duplicate_ids" swagger:"required" description:"Contatos que serão incorporados ao sobrevivente e removidos"`
+}
+
+type CheckNumbersRequest struct {
+	SectorID int      `json:"sector_id" example:"1" swagger:"required" description:"ID do setor"`
+	Numbers  []string `json:"numbers" swagger:"required" description:"Números a verificar (máximo 500)"`
+}
+
+type PresenceRequest struct {
+	SectorID  int    `json:"sector_id" example:"1" swagger:"required" description:"ID do setor"`
+	Recipient string `json:"recipient" example:"5511999999999" swagger:"required" description:"Número do telefone no formato DDDNúmero"`
+	State     string `json:"state" example:"composing" swagger:"required" description:"composing, recording ou paused"`
+	Duration  int    `json:"duration" example:"10" default:"10" description:"Segundos até a presença voltar a paused automaticamente (máximo 60)"`
+}
+
 type ImageMessageRequest struct {
 	SectorID  int    `json:"sector_id"`
 	Recipient string `json:"recipient"`
 	ImagePath string `json:"image_path"`
 	Caption   string `json:"caption"`
 }
+
+type ScheduledMessageRequest struct {
+	SectorID    int    `json:"sectorId" example:"1" swagger:"required" description:"ID do setor"`
+	Kind        string `json:"kind" example:"text" swagger:"required" description:"text, image, audio ou document"`
+	Recipient   string `json:"recipient" example:"5511999999999" swagger:"required" description:"Número do telefone no formato DDDNúmero"`
+	Message     string `json:"message" description:"Texto da mensagem ou legenda da mídia"`
+	Base64File  string `json:"base64File" description:"Arquivo em base64 para mensagens de mídia"`
+	MediaURL    string `json:"mediaUrl" description:"Alternativa ao base64File: endereço de onde o arquivo é baixado (apenas hosts permitidos)"`
+	ObjectKey   string `json:"objectKey" description:"Alternativa ao base64File: chave de um arquivo já enviado por /upload, reaproveitado sem novo upload"`
+	FileName    string `json:"fileName"`
+	UserID      *int   `json:"userId"`
+	IsAnonymous bool   `json:"isAnonymous"`
+	SendAt      string `json:"sendAt" example:"2025-05-20T09:00:00" swagger:"required" description:"Horário de envio no fuso informado (ou RFC3339 com offset)"`
+	Timezone    string `json:"timezone" example:"America/Sao_Paulo" swagger:"required" description:"Fuso horário do cliente"`
+}
+
+type RescheduleRequest struct {
+	SendAt   string `json:"sendAt" example:"2025-05-20T09:00:00" swagger:"required" description:"Novo horário de envio no fuso informado"`
+	Timezone string `json:"timezone" example:"America/Sao_Paulo" swagger:"required" description:"Fuso horário do cliente"`
+}
+
+type CampaignRequest struct {
+	SectorID      int    `json:"sectorId" example:"1" swagger:"required" description:"ID do setor"`
+	Name          string `json:"name" example:"Aviso de pedido pronto" swagger:"required"`
+	Kind          string `json:"kind" example:"text" description:"text, image, audio ou document"`
+	Message       string `json:"message" description:"Texto da mensagem ou legenda da mídia"`
+	Base64File    string `json:"base64File" description:"Arquivo em base64 para campanhas de mídia"`
+	MediaURL      string `json:"mediaUrl" description:"Alternativa ao base64File: endereço de onde o arquivo é baixado (apenas hosts permitidos)"`
+	ObjectKey     string `json:"objectKey" description:"Alternativa ao base64File: chave de um arquivo já enviado por /upload, reaproveitado sem novo upload"`
+	FileName      string `json:"fileName"`
+	UserID        *int   `json:"userId"`
+	IsAnonymous   bool   `json:"isAnonymous"`
+	RatePerMinute int    `json:"ratePerMinute" example:"20" description:"Mensagens despachadas por minuto"`
+	TemplateID    int64  `json:"templateId" description:"ID de um template do setor a ser usado no lugar de message"`
+	ContactIDs    []int  `json:"contactIds" description:"IDs de contatos do setor"`
+	TagID         int    `json:"tagId" description:"Adicionar todos os contatos do setor com esta etiqueta"`
+}
+
+type CampaignRecipientsRequest struct {
+	ContactIDs []int    `json:"contactIds" description:"IDs de contatos do setor"`
+	TagID      int      `json:"tagId" description:"Adicionar todos os contatos do setor com esta etiqueta"`
+	Numbers    []string `json:"numbers" description:"Números avulsos no formato DDDNúmero"`
+}
+
+type MessageTemplateRequest struct {
+	SectorID   int    `json:"sectorId" example:"1" swagger:"required" description:"ID do setor"`
+	Name       string `json:"name" example:"Saudação" swagger:"required"`
+	Kind       string `json:"kind" example:"text" description:"text, image, audio ou document"`
+	Content    string `json:"content" example:"Olá {{contact.name}}, aqui é {{agent.name}}!" description:"Texto ou legenda com placeholders"`
+	Base64File string `json:"base64File" description:"Anexo em base64 para templates de mídia"`
+	FileName   string `json:"fileName"`
+}
@@ -8,6 +8,7 @@ import (
 
 type APIResponse struct {
 	Status    string      `json:"status"`
+	Code      string      `json:"code,omitempty"`
 	Message   string      `json:"message"`
 	Data      interface{} `json:"data,omitempty"`
 	Timestamp time.Time   `json:"-"`
@@ -41,6 +42,13 @@ func NewErrorResponse(message string) *APIResponse {
 	}
 }
 
+// NewErrorResponseWithCode cria uma resposta de erro com um código estável para tratamento pelo cliente
+func NewErrorResponseWithCode(code string, message string) *APIResponse {
+	response := NewErrorResponse(message)
+	response.Code = code
+	return response
+}
+
 func NewWaitingResponse(message string) *APIResponse {
 	return &APIResponse{
 		Status:    "waiting",
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Estados de uma mensagem agendada
+const (
+	ScheduledStatusPending  = "pending"  // Aguardando o horário de envio
+	ScheduledStatusQueued   = "queued"   // Horário atingido, entregue à fila de envio do setor
+	ScheduledStatusSent     = "sent"     // Enviada ao WhatsApp
+	ScheduledStatusFailed   = "failed"   // Não pôde ser enviada
+	ScheduledStatusCanceled = "canceled" // Cancelada antes do envio
+)
+
+type ScheduledMessage struct {
+	ID                int64     `json:"id"`
+	SectorID          int       `json:"sector_id"`
+	Kind              string    `json:"kind"`
+	Recipient         string    `json:"recipient"`
+	Content           string    `json:"content"`
+	MediaKey          string    `json:"media_key,omitempty"`
+	FileName          string    `json:"file_name,omitempty"`
+	UserID            *int      `json:"user_id,omitempty"`
+	IsAnonymous       bool      `json:"is_anonymous"`
+	SendAt            time.Time `json:"send_at"`  // Horário de envio em UTC
+	Timezone          string    `json:"timezone"` // Fuso informado no agendamento, ex: America/Sao_Paulo
+	Status            string    `json:"status"`
+	OutboundMessageID int64     `json:"outbound_message_id,omitempty"`
+	WhatsAppMessageID string    `json:"whatsapp_message_id,omitempty"`
+	LastError         string    `json:"last_error,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type ScheduledMessageRepository interface {
+	Create(message *ScheduledMessage) error
+	GetByID(id int64) (*ScheduledMessage, error)
+	ListDue(limit int) ([]*ScheduledMessage, error)
+	Claim(id int64) (bool, error)
+	SetOutbound(id int64, outboundMessageID int64) error
+	ResetQueued() error
+	MarkResult(id int64, status string, whatsappMessageID string, lastError string) error
+	Cancel(id int64) error
+	Reschedule(id int64, sendAt time.Time, timezone string) error
+	ListBySector(sectorID int, status string, limit int) ([]*ScheduledMessage, error)
+}
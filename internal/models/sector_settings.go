@@ -0,0 +1,63 @@
+package models
+
+// Políticas de tratamento de chamadas recebidas
+const (
+	CallPolicyIgnore        = "ignore"         // Apenas registra a chamada na conversa
+	CallPolicyReject        = "reject"         // Rejeita a chamada automaticamente
+	CallPolicyRejectMessage = "reject_message" // Rejeita e envia uma mensagem de texto ao contato
+)
+
+// SectorSettings reúne as configurações de comportamento de um setor
+type SectorSettings struct {
+	SectorID          int    `json:"sector_id"`
+	CallPolicy        string `json:"call_policy"`
+	CallRejectMessage string `json:"call_reject_message"`
+
+	// Limites de envio (0 desativa o limite correspondente). O limite por destinatário vem desativado
+	SectorRatePerMinute    int  `json:"sector_rate_per_minute"`
+	SectorBurst            int  `json:"sector_burst"`
+	RecipientRatePerMinute int  `json:"recipient_rate_per_minute"`
+	RecipientBurst         int  `json:"recipient_burst"`
+	SendJitterMaxMs        int  `json:"send_jitter_max_ms"` // Atraso aleatório máximo antes de cada envio
+	TypingBeforeSend       bool `json:"typing_before_send"` // Simula digitação/gravação antes de cada envio
+
+	// Assinatura do atendente aplicada às mensagens enviadas com userId
+	SignatureTemplate  string `json:"signature_template"`  // Aceita os placeholders de templates, ex: *{{agent.name}}*:
+	SignaturePlacement string `json:"signature_placement"` // prefix, suffix ou none
+
+	// Marca a conversa como lida no WhatsApp sempre que o setor responde
+	AutoMarkReadOnReply bool `json:"auto_mark_read_on_reply"`
+
+	// Região (ISO 3166-1 alfa-2) usada para números informados sem o código do país
+	DefaultRegion string `json:"default_region"`
+}
+
+// DefaultSectorSettings retorna as configurações usadas quando o setor ainda não possui registro
+func DefaultSectorSettings(sectorID int) *SectorSettings {
+	return &SectorSettings{
+		SectorID:               sectorID,
+		CallPolicy:             CallPolicyIgnore,
+		SectorRatePerMinute:    30,
+		SectorBurst:            10,
+		RecipientRatePerMinute: 0,
+		RecipientBurst:         0,
+		SignatureTemplate:      DefaultSignatureTemplate,
+		SignaturePlacement:     SignaturePlacementPrefix,
+		AutoMarkReadOnReply:    true,
+		DefaultRegion:          "BR",
+	}
+}
+
+// IsValidCallPolicy verifica se a política de chamadas informada é suportada
+func IsValidCallPolicy(policy string) bool {
+	switch policy {
+	case CallPolicyIgnore, CallPolicyReject, CallPolicyRejectMessage:
+		return true
+	}
+	return false
+}
+
+type SectorSettingsRepository interface {
+	GetBySector(sectorID int) (*SectorSettings, error)
+	Save(settings *SectorSettings) error
+}
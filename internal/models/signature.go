@@ -0,0 +1,33 @@
+package models
+
+// Posição da assinatura do atendente na mensagem
+const (
+	SignaturePlacementPrefix = "prefix" // Assinatura antes do texto
+	SignaturePlacementSuffix = "suffix" // Assinatura depois do texto
+	SignaturePlacementNone   = "none"   // Mensagens enviadas sem assinatura
+)
+
+// DefaultSignatureTemplate reproduz o formato histórico "*Nome*:" antes do texto
+const DefaultSignatureTemplate = "*{{agent.name}}*:"
+
+// UserSignature sobrescreve a assinatura do setor para um atendente. Campos vazios herdam o valor do setor
+type UserSignature struct {
+	UserID             int    `json:"user_id"`
+	SignatureTemplate  string `json:"signature_template"`
+	SignaturePlacement string `json:"signature_placement"`
+}
+
+// IsValidSignaturePlacement verifica se a posição de assinatura informada é suportada
+func IsValidSignaturePlacement(placement string) bool {
+	switch placement {
+	case SignaturePlacementPrefix, SignaturePlacementSuffix, SignaturePlacementNone:
+		return true
+	}
+	return false
+}
+
+type UserSignatureRepository interface {
+	GetByUser(userID int) (*UserSignature, error)
+	Save(signature *UserSignature) error
+	Delete(userID int) error
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type MessageTemplate struct {
+	ID        int64     `json:"id"`
+	SectorID  int       `json:"sector_id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`    // text, image, audio ou document
+	Content   string    `json:"content"` // Texto com placeholders, ex: Olá {{contact.name}}
+	MediaKey  string    `json:"media_key,omitempty"`
+	FileName  string    `json:"file_name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MessageTemplateRepository interface {
+	Create(template *MessageTemplate) error
+	GetByID(id int64) (*MessageTemplate, error)
+	ListBySector(sectorID int) ([]*MessageTemplate, error)
+	Update(template *MessageTemplate) error
+	Delete(id int64) error
+}
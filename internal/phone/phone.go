@@ -0,0 +1,214 @@
+// Package phone normaliza números de telefone para o formato E.164 usado como
+// identificador dos contatos, independentemente de como o número foi digitado
+// ou de como chegou do WhatsApp (JID com dispositivo, sufixo do servidor, etc.).
+package phone
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Região usada quando o setor não define a sua
+const DefaultRegion = "BR"
+
+var (
+	ErrInvalid = errors.New("número de telefone inválido")
+	ErrLID     = errors.New("identificador é um LID, não um número de telefone")
+)
+
+// Number é um número de telefone normalizado
+type Number struct {
+	CountryCode string // Ex: 55
+	National    string // Ex: 11999999999
+}
+
+// Digits retorna o número completo sem o sinal de +, formato em que os contatos são gravados
+func (n Number) Digits() string {
+	return n.CountryCode + n.National
+}
+
+// E164 retorna o número no formato internacional, ex: +5511999999999
+func (n Number) E164() string {
+	return "+" + n.Digits()
+}
+
+// JID retorna o JID do WhatsApp correspondente ao número
+func (n Number) JID() types.JID {
+	return types.NewJID(n.Digits(), types.DefaultUserServer)
+}
+
+// Variants retorna as formas em que o mesmo número pode estar registrado.
+// Para celulares brasileiros inclui a forma sem o nono dígito, ainda usada por contas antigas
+func (n Number) Variants() []string {
+	variants := []string{n.Digits()}
+	if n.CountryCode == "55" && len(n.National) == 11 && n.National[2] == '9' && n.National[3] >= '6' {
+		variants = append(variants, n.CountryCode+n.National[:2]+n.National[3:])
+	}
+	return variants
+}
+
+// IsLID indica se o identificador é um LID (@lid) em vez de um número de telefone
+func IsLID(input string) bool {
+	return strings.HasSuffix(strings.TrimSpace(input), "@"+types.HiddenUserServer)
+}
+
+// Parse normaliza o número informado. Números sem código do país são interpretados na região indicada.
+// Aceita JIDs do WhatsApp, inclusive com agente e dispositivo (5511999999999:12@s.whatsapp.net)
+func Parse(input string, region string) (Number, error) {
+	input = strings.TrimSpace(input)
+	if IsLID(input) {
+		return Number{}, ErrLID
+	}
+
+	international := false
+	if at := strings.Index(input, "@"); at >= 0 {
+		// JIDs de usuário sempre trazem o número internacional
+		input = input[:at]
+		if i := strings.IndexAny(input, ".:"); i >= 0 {
+			input = input[:i]
+		}
+		international = true
+	}
+
+	if strings.HasPrefix(input, "+") {
+		international = true
+	}
+
+	digits := onlyDigits(input)
+	if strings.HasPrefix(digits, "00") && !international {
+		digits = strings.TrimPrefix(digits, "00")
+		international = true
+	}
+	if digits == "" {
+		return Number{}, ErrInvalid
+	}
+
+	region = strings.ToUpper(region)
+	code, known := callingCodes[region]
+	if !known {
+		region = DefaultRegion
+		code = callingCodes[region]
+	}
+	lengths, exists := nationalLengths[region]
+	if !exists {
+		lengths = defaultNationalLength
+	}
+
+	var number Number
+	if !international && len(strings.TrimLeft(digits, "0")) <= lengths.max {
+		national := digits
+		if !keepsTrunkZero[region] {
+			national = strings.TrimLeft(national, "0")
+		}
+		number = Number{CountryCode: code, National: national}
+	} else {
+		number = Number{}
+		for size := 1; size <= 3 && size < len(digits); size++ {
+			if countryCodes[digits[:size]] {
+				number = Number{CountryCode: digits[:size], National: digits[size:]}
+				break
+			}
+		}
+		if number.CountryCode == "" {
+			return Number{}, ErrInvalid
+		}
+	}
+
+	if number.CountryCode == "55" {
+		number.National = brazilianNinthDigit(number.National)
+	}
+
+	total := len(number.Digits())
+	if len(number.National) < 4 || total < 8 || total > 15 {
+		return Number{}, ErrInvalid
+	}
+	return number, nil
+}
+
+// brazilianNinthDigit adiciona o nono dígito aos celulares brasileiros informados no formato antigo
+func brazilianNinthDigit(national string) string {
+	if len(national) == 10 && national[2] >= '6' {
+		return national[:2] + "9" + national[2:]
+	}
+	return national
+}
+
+func onlyDigits(input string) string {
+	var digits strings.Builder
+	for _, r := range input {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return digits.String()
+}
+
+// Tempo que a região de um setor fica em cache antes de ser consultada novamente
+const regionCacheTTL = time.Minute
+
+type cachedRegion struct {
+	region    string
+	expiresAt time.Time
+}
+
+var (
+	regionResolver func(sectorID int) string
+	regionCache    = make(map[int]cachedRegion)
+	regionMutex    sync.Mutex
+)
+
+// SetRegionResolver define como descobrir a região padrão de cada setor
+func SetRegionResolver(resolver func(sectorID int) string) {
+	regionMutex.Lock()
+	defer regionMutex.Unlock()
+
+	regionResolver = resolver
+	regionCache = make(map[int]cachedRegion)
+}
+
+// InvalidateRegion descarta a região em cache do setor, ex: após alterar as configurações
+func InvalidateRegion(sectorID int) {
+	regionMutex.Lock()
+	delete(regionCache, sectorID)
+	regionMutex.Unlock()
+}
+
+// RegionForSector retorna a região padrão do setor
+func RegionForSector(sectorID int) string {
+	regionMutex.Lock()
+	cached, exists := regionCache[sectorID]
+	resolver := regionResolver
+	regionMutex.Unlock()
+
+	if exists && time.Now().Before(cached.expiresAt) {
+		return cached.region
+	}
+	if resolver == nil {
+		return DefaultRegion
+	}
+
+	region := resolver(sectorID)
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	regionMutex.Lock()
+	regionCache[sectorID] = cachedRegion{region: region, expiresAt: time.Now().Add(regionCacheTTL)}
+	regionMutex.Unlock()
+	return region
+}
+
+// ForSector normaliza o número usando a região padrão do setor
+func ForSector(sectorID int, input string) (Number, error) {
+	return Parse(input, RegionForSector(sectorID))
+}
+
+// IsValidRegion verifica se a região é conhecida
+func IsValidRegion(region string) bool {
+	_, exists := callingCodes[strings.ToUpper(region)]
+	return exists
+}
@@ -0,0 +1,113 @@
+package phone
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		region string
+		want   string
+		err    error
+	}{
+		{name: "celular brasileiro sem formatação", input: "11999999999", region: "BR", want: "5511999999999"},
+		{name: "celular brasileiro formatado", input: "(11) 99999-9999", region: "BR", want: "5511999999999"},
+		{name: "celular brasileiro sem o nono dígito", input: "1188887777", region: "BR", want: "5511988887777"},
+		{name: "fixo brasileiro mantém 8 dígitos", input: "1133334444", region: "BR", want: "551133334444"},
+		{name: "zero de tronco é removido", input: "011999999999", region: "BR", want: "5511999999999"},
+		{name: "prefixo internacional 00", input: "005511999999999", region: "BR", want: "5511999999999"},
+		{name: "número internacional com +", input: "+55 11 99999-9999", region: "BR", want: "5511999999999"},
+		{name: "número internacional de outro país", input: "+1 415 555 2671", region: "BR", want: "14155552671"},
+		{name: "JID com dispositivo", input: "5511999999999:12@s.whatsapp.net", region: "BR", want: "5511999999999"},
+		{name: "JID antigo sem o nono dígito", input: "551188887777@s.whatsapp.net", region: "PT", want: "5511988887777"},
+		{name: "número local de Portugal", input: "912 345 678", region: "PT", want: "351912345678"},
+		{name: "número local dos Estados Unidos", input: "(415) 555-2671", region: "us", want: "14155552671"},
+		{name: "zero de tronco removido no Reino Unido", input: "07911 123456", region: "GB", want: "447911123456"},
+		{name: "zero inicial mantido na Itália", input: "06 1234 5678", region: "IT", want: "390612345678"},
+		{name: "região desconhecida usa a padrão", input: "11999999999", region: "XX", want: "5511999999999"},
+		{name: "vazio", input: "", region: "BR", err: ErrInvalid},
+		{name: "sem dígitos", input: "abc", region: "BR", err: ErrInvalid},
+		{name: "curto demais", input: "123", region: "BR", err: ErrInvalid},
+		{name: "código de país desconhecido", input: "+999123456", region: "BR", err: ErrInvalid},
+		{name: "LID", input: "123456789012345@lid", region: "BR", err: ErrLID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := Parse(tt.input, tt.region)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Parse(%q, %q) erro = %v, esperado %v", tt.input, tt.region, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q, %q) erro inesperado: %v", tt.input, tt.region, err)
+			}
+			if got := number.Digits(); got != tt.want {
+				t.Errorf("Parse(%q, %q) = %s, esperado %s", tt.input, tt.region, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "celular brasileiro inclui a forma sem o nono dígito", input: "+5511999999999", want: []string{"5511999999999", "551199999999"}},
+		{name: "fixo brasileiro", input: "+551133334444", want: []string{"551133334444"}},
+		{name: "outro país", input: "+14155552671", want: []string{"14155552671"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := Parse(tt.input, DefaultRegion)
+			if err != nil {
+				t.Fatalf("Parse(%q) erro inesperado: %v", tt.input, err)
+			}
+			if got := number.Variants(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variants(%q) = %v, esperado %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForSector(t *testing.T) {
+	SetRegionResolver(func(sectorID int) string {
+		if sectorID == 2 {
+			return "PT"
+		}
+		return ""
+	})
+	t.Cleanup(func() { SetRegionResolver(nil) })
+
+	tests := []struct {
+		name     string
+		sectorID int
+		input    string
+		want     string
+	}{
+		{name: "setor com região própria", sectorID: 2, input: "912345678", want: "351912345678"},
+		{name: "setor sem região usa a padrão", sectorID: 1, input: "11999999999", want: "5511999999999"},
+		{name: "número internacional ignora a região do setor", sectorID: 2, input: "+5511999999999", want: "5511999999999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := ForSector(tt.sectorID, tt.input)
+			if err != nil {
+				t.Fatalf("ForSector(%d, %q) erro inesperado: %v", tt.sectorID, tt.input, err)
+			}
+			if got := number.Digits(); got != tt.want {
+				t.Errorf("ForSector(%d, %q) = %s, esperado %s", tt.sectorID, tt.input, got, tt.want)
+			}
+		})
+	}
+}
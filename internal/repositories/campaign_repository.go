@@ -0,0 +1,568 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+type MySQLCampaignRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLCampaignRepository(db *sql.DB) *MySQLCampaignRepository {
+	return &MySQLCampaignRepository{db: db}
+}
+
+const campaignColumns = `
+	id, sector_id, name, kind, content, media_key, file_name, user_id,
+	is_anonymous, rate_per_minute, status, started_at, finished_at,
+	created_at, updated_at`
+
+const campaignRecipientColumns = `
+	id, campaign_id, contact_id, recipient, name, status, outbound_message_id,
+	whatsapp_message_id, last_error, created_at, updated_at`
+
+// receiptUpgradeFrom lista de quais estados cada recibo pode avançar, para que o status nunca regrida
+// (o ID da mensagem é gravado antes do envio, então o recibo pode chegar com o destinatário ainda em sending)
+var receiptUpgradeFrom = map[string][]string{
+	models.CampaignRecipientDelivered: {models.CampaignRecipientSending, models.CampaignRecipientSent},
+	models.CampaignRecipientRead:      {models.CampaignRecipientSending, models.CampaignRecipientSent, models.CampaignRecipientDelivered},
+}
+
+func (r *MySQLCampaignRepository) Create(campaign *models.Campaign) error {
+	now := time.Now().UTC()
+	if campaign.Status == "" {
+		campaign.Status = models.CampaignStatusDraft
+	}
+	if campaign.RatePerMinute <= 0 {
+		campaign.RatePerMinute = models.DefaultCampaignRatePerMinute
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO campaigns (
+			sector_id, name, kind, content, media_key, file_name, user_id,
+			is_anonymous, rate_per_minute, status, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		campaign.SectorID,
+		campaign.Name,
+		campaign.Kind,
+		campaign.Content,
+		utils.NullString(campaign.MediaKey),
+		utils.NullString(campaign.FileName),
+		utils.NullInt(utils.GetIntFromPointer(campaign.UserID)),
+		utils.BoolToInt(campaign.IsAnonymous),
+		campaign.RatePerMinute,
+		campaign.Status,
+		now,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating campaign: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert id: %v", err)
+	}
+
+	campaign.ID = id
+	campaign.CreatedAt = now
+	campaign.UpdatedAt = now
+	return nil
+}
+
+func (r *MySQLCampaignRepository) GetByID(id int64) (*models.Campaign, error) {
+	campaigns, err := r.fetchCampaigns(`SELECT `+campaignColumns+` FROM campaigns WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(campaigns) == 0 {
+		return nil, nil
+	}
+	return campaigns[0], nil
+}
+
+func (r *MySQLCampaignRepository) ListBySector(sectorID int, limit int) ([]*models.Campaign, error) {
+	return r.fetchCampaigns(`
+		SELECT `+campaignColumns+`
+		FROM campaigns
+		WHERE sector_id = ?
+		ORDER BY id DESC
+		LIMIT ?`,
+		sectorID, limit)
+}
+
+func (r *MySQLCampaignRepository) ListRunning() ([]*models.Campaign, error) {
+	return r.fetchCampaigns(`
+		SELECT `+campaignColumns+`
+		FROM campaigns
+		WHERE status = ?
+		ORDER BY id ASC`,
+		models.CampaignStatusRunning)
+}
+
+// UpdateStatus muda o estado da campanha, opcionalmente apenas se ela estiver em um dos estados informados
+func (r *MySQLCampaignRepository) UpdateStatus(id int64, status string, fromStatuses ...string) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE campaigns
+		SET status = ?,
+			started_at = CASE WHEN ? = ? AND started_at IS NULL THEN ? ELSE started_at END,
+			finished_at = CASE WHEN ? IN (?, ?) THEN ? ELSE finished_at END,
+			updated_at = ?
+		WHERE id = ?`
+	args := []interface{}{
+		status,
+		status, models.CampaignStatusRunning, now,
+		status, models.CampaignStatusCanceled, models.CampaignStatusCompleted, now,
+		now,
+		id,
+	}
+
+	if len(fromStatuses) > 0 {
+		query += " AND status IN (?" + strings.Repeat(",?", len(fromStatuses)-1) + ")"
+		for _, from := range fromStatuses {
+			args = append(args, from)
+		}
+	}
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error updating campaign status: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("campaign not found or cannot change to %s", status)
+	}
+	return nil
+}
+
+// AddRecipients adiciona números avulsos, ignorando os que já estão na campanha
+func (r *MySQLCampaignRepository) AddRecipients(campaignID int64, recipients []*models.CampaignRecipient) (int, error) {
+	campaign, err := r.GetByID(campaignID)
+	if err != nil {
+		return 0, err
+	}
+	if campaign == nil {
+		return 0, fmt.Errorf("campaign not found")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT IGNORE INTO campaign_recipients (
+			campaign_id, sector_id, contact_id, recipient, name, status, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing campaign recipient insert: %v", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	added := 0
+	for _, recipient := range recipients {
+		result, err := stmt.Exec(
+			campaignID,
+			campaign.SectorID,
+			utils.NullInt(recipient.ContactID),
+			recipient.Recipient,
+			utils.NullString(recipient.Name),
+			models.CampaignRecipientQueued,
+			now,
+			now,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("error adding campaign recipient: %v", err)
+		}
+		if rows, err := result.RowsAffected(); err == nil {
+			added += int(rows)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing campaign recipients: %v", err)
+	}
+	return added, nil
+}
+
+// AddContactRecipients adiciona contatos do setor da campanha pelos seus IDs
+func (r *MySQLCampaignRepository) AddContactRecipients(campaign *models.Campaign, contactIDs []int) (int, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+
+	args := []interface{}{campaign.SectorID}
+	for _, id := range contactIDs {
+		args = append(args, id)
+	}
+
+	return r.insertFromContacts(campaign, `AND id IN (?`+strings.Repeat(",?", len(contactIDs)-1)+`)`, args...)
+}
+
+// AddTagRecipients adiciona todos os contatos do setor da campanha que possuem a etiqueta
+func (r *MySQLCampaignRepository) AddTagRecipients(campaign *models.Campaign, tagID int) (int, error) {
+	return r.insertFromContacts(campaign, `AND tag_id = ?`, campaign.SectorID, tagID)
+}
+
+// insertFromContacts adiciona os contatos do filtro com o número normalizado na região do setor,
+// da mesma forma que os números avulsos
+func (r *MySQLCampaignRepository) insertFromContacts(campaign *models.Campaign, filter string, args ...interface{}) (int, error) {
+	rows, err := r.db.Query(`
+		SELECT id, number, name
+		FROM contacts
+		WHERE sector_id = ? `+filter, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching contacts for campaign: %v", err)
+	}
+	defer rows.Close()
+
+	var recipients []*models.CampaignRecipient
+	for rows.Next() {
+		var contactID int
+		var number string
+		var name sql.NullString
+		if err := rows.Scan(&contactID, &number, &name); err != nil {
+			return 0, fmt.Errorf("error scanning contact for campaign: %v", err)
+		}
+
+		normalized, _ := normalizeNumber(campaign.SectorID, number)
+		recipients = append(recipients, &models.CampaignRecipient{
+			ContactID: contactID,
+			Recipient: normalized,
+			Name:      name.String,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating contacts for campaign: %v", err)
+	}
+
+	if len(recipients) == 0 {
+		return 0, nil
+	}
+	return r.AddRecipients(campaign.ID, recipients)
+}
+
+func (r *MySQLCampaignRepository) CountByStatus(campaignID int64) (map[string]int, error) {
+	rows, err := r.db.Query(`
+		SELECT status, COUNT(*)
+		FROM campaign_recipients
+		WHERE campaign_id = ?
+		GROUP BY status`,
+		campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error counting campaign recipients: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("error scanning campaign count: %v", err)
+		}
+		counts[status] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating campaign counts: %v", err)
+	}
+
+	return counts, nil
+}
+
+// ClaimRecipients seleciona os próximos destinatários da campanha e os marca como "sending"
+func (r *MySQLCampaignRepository) ClaimRecipients(campaignID int64, limit int) ([]*models.CampaignRecipient, error) {
+	recipients, err := r.fetchRecipients(`
+		SELECT `+campaignRecipientColumns+`
+		FROM campaign_recipients
+		WHERE campaign_id = ? AND status = ?
+		ORDER BY id ASC
+		LIMIT ?`,
+		campaignID, models.CampaignRecipientQueued, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{models.CampaignRecipientSending, time.Now().UTC()}
+	for _, recipient := range recipients {
+		args = append(args, recipient.ID)
+		recipient.Status = models.CampaignRecipientSending
+	}
+	query := "UPDATE campaign_recipients SET status = ?, updated_at = ? WHERE id IN (?" + strings.Repeat(",?", len(recipients)-1) + ")"
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return nil, fmt.Errorf("error claiming campaign recipients: %v", err)
+	}
+
+	return recipients, nil
+}
+
+func (r *MySQLCampaignRepository) GetRecipientByID(id int64) (*models.CampaignRecipient, error) {
+	recipients, err := r.fetchRecipients(`SELECT `+campaignRecipientColumns+` FROM campaign_recipients WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	return recipients[0], nil
+}
+
+func (r *MySQLCampaignRepository) SetRecipientOutbound(id int64, outboundMessageID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE campaign_recipients
+		SET outbound_message_id = ?, updated_at = ?
+		WHERE id = ?`,
+		outboundMessageID, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error setting campaign recipient outbound id: %v", err)
+	}
+	return nil
+}
+
+// SetRecipientMessageID grava o ID da mensagem do WhatsApp antes do envio
+func (r *MySQLCampaignRepository) SetRecipientMessageID(id int64, whatsappMessageID string) error {
+	_, err := r.db.Exec(`
+		UPDATE campaign_recipients
+		SET whatsapp_message_id = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		whatsappMessageID, time.Now().UTC(), id, models.CampaignRecipientSending)
+	if err != nil {
+		return fmt.Errorf("error setting campaign recipient message id: %v", err)
+	}
+	return nil
+}
+
+// RequeueRecipient devolve um destinatário que falhou ao estado de envio quando a mensagem da fila é reenviada
+// manualmente, para que o novo ID da mensagem, o resultado e os recibos voltem a ser registrados nele
+func (r *MySQLCampaignRepository) RequeueRecipient(id int64) error {
+	_, err := r.db.Exec(`
+		UPDATE campaign_recipients
+		SET status = ?,
+			whatsapp_message_id = NULL,
+			last_error = NULL,
+			updated_at = ?
+		WHERE id = ? AND status = ?`,
+		models.CampaignRecipientSending, time.Now().UTC(), id, models.CampaignRecipientFailed)
+	if err != nil {
+		return fmt.Errorf("error requeueing campaign recipient: %v", err)
+	}
+	return nil
+}
+
+// MarkRecipientResult registra o resultado do envio apenas em destinatários ainda não enviados, para que
+// um recibo de entrega ou leitura aplicado antes do resultado não seja desfeito
+func (r *MySQLCampaignRepository) MarkRecipientResult(id int64, status string, whatsappMessageID string, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE campaign_recipients
+		SET status = ?,
+			whatsapp_message_id = ?,
+			last_error = ?,
+			updated_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		status, utils.NullString(whatsappMessageID), utils.NullString(lastError), time.Now().UTC(), id,
+		models.CampaignRecipientQueued, models.CampaignRecipientSending)
+	if err != nil {
+		return fmt.Errorf("error updating campaign recipient: %v", err)
+	}
+	return nil
+}
+
+// UpdateRecipientReceipts avança os destinatários cujas mensagens receberam recibo de entrega ou leitura
+// e retorna os destinatários atualizados
+func (r *MySQLCampaignRepository) UpdateRecipientReceipts(sectorID int, whatsappMessageIDs []string, status string) ([]*models.CampaignRecipient, error) {
+	fromStatuses, ok := receiptUpgradeFrom[status]
+	if !ok || len(whatsappMessageIDs) == 0 {
+		return nil, nil
+	}
+
+	filter := " AND whatsapp_message_id IN (?" + strings.Repeat(",?", len(whatsappMessageIDs)-1) + ")" +
+		" AND status IN (?" + strings.Repeat(",?", len(fromStatuses)-1) + ")"
+	args := []interface{}{sectorID}
+	for _, id := range whatsappMessageIDs {
+		args = append(args, id)
+	}
+	for _, from := range fromStatuses {
+		args = append(args, from)
+	}
+
+	recipients, err := r.fetchRecipients(`
+		SELECT `+campaignRecipientColumns+`
+		FROM campaign_recipients
+		WHERE sector_id = ?`+filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE campaign_recipients
+		SET status = ?, updated_at = ?
+		WHERE sector_id = ?`+filter,
+		append([]interface{}{status, time.Now().UTC()}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error updating campaign receipts: %v", err)
+	}
+
+	for _, recipient := range recipients {
+		recipient.Status = status
+	}
+	return recipients, nil
+}
+
+// CancelQueuedRecipients cancela os destinatários que ainda não foram despachados
+func (r *MySQLCampaignRepository) CancelQueuedRecipients(campaignID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE campaign_recipients
+		SET status = ?, updated_at = ?
+		WHERE campaign_id = ? AND status = ?`,
+		models.CampaignRecipientCanceled, time.Now().UTC(), campaignID, models.CampaignRecipientQueued)
+	if err != nil {
+		return fmt.Errorf("error canceling campaign recipients: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLCampaignRepository) ListRecipients(campaignID int64, status string, limit int) ([]*models.CampaignRecipient, error) {
+	if status == "" {
+		return r.fetchRecipients(`
+			SELECT `+campaignRecipientColumns+`
+			FROM campaign_recipients
+			WHERE campaign_id = ?
+			ORDER BY id ASC
+			LIMIT ?`,
+			campaignID, limit)
+	}
+
+	return r.fetchRecipients(`
+		SELECT `+campaignRecipientColumns+`
+		FROM campaign_recipients
+		WHERE campaign_id = ? AND status = ?
+		ORDER BY id ASC
+		LIMIT ?`,
+		campaignID, status, limit)
+}
+
+func (r *MySQLCampaignRepository) fetchCampaigns(query string, args ...interface{}) ([]*models.Campaign, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying campaigns: %v", err)
+	}
+	defer rows.Close()
+
+	var campaigns []*models.Campaign
+
+	for rows.Next() {
+		campaign := &models.Campaign{}
+		var content, mediaKey, fileName sql.NullString
+		var userID sql.NullInt64
+		var startedAt, finishedAt sql.NullTime
+
+		err := rows.Scan(
+			&campaign.ID,
+			&campaign.SectorID,
+			&campaign.Name,
+			&campaign.Kind,
+			&content,
+			&mediaKey,
+			&fileName,
+			&userID,
+			&campaign.IsAnonymous,
+			&campaign.RatePerMinute,
+			&campaign.Status,
+			&startedAt,
+			&finishedAt,
+			&campaign.CreatedAt,
+			&campaign.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning campaign: %v", err)
+		}
+
+		campaign.Content = content.String
+		campaign.MediaKey = mediaKey.String
+		campaign.FileName = fileName.String
+		if userID.Valid {
+			id := int(userID.Int64)
+			campaign.UserID = &id
+		}
+		if startedAt.Valid {
+			campaign.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			campaign.FinishedAt = &finishedAt.Time
+		}
+
+		campaigns = append(campaigns, campaign)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating campaigns: %v", err)
+	}
+
+	return campaigns, nil
+}
+
+func (r *MySQLCampaignRepository) fetchRecipients(query string, args ...interface{}) ([]*models.CampaignRecipient, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying campaign recipients: %v", err)
+	}
+	defer rows.Close()
+
+	var recipients []*models.CampaignRecipient
+
+	for rows.Next() {
+		recipient := &models.CampaignRecipient{}
+		var name, whatsappMessageID, lastError sql.NullString
+		var contactID, outboundMessageID sql.NullInt64
+
+		err := rows.Scan(
+			&recipient.ID,
+			&recipient.CampaignID,
+			&contactID,
+			&recipient.Recipient,
+			&name,
+			&recipient.Status,
+			&outboundMessageID,
+			&whatsappMessageID,
+			&lastError,
+			&recipient.CreatedAt,
+			&recipient.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning campaign recipient: %v", err)
+		}
+
+		recipient.ContactID = int(contactID.Int64)
+		recipient.Name = name.String
+		recipient.OutboundMessageID = outboundMessageID.Int64
+		recipient.WhatsAppMessageID = whatsappMessageID.String
+		recipient.LastError = lastError.String
+
+		recipients = append(recipients, recipient)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating campaign recipients: %v", err)
+	}
+
+	return recipients, nil
+}
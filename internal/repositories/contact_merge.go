@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+// FindDuplicates agrupa os contatos do setor pelo número normalizado, retornando apenas os grupos
+// com mais de um contato. O sobrevivente sugerido é o contato já gravado no formato canônico
+// ou, na falta dele, o mais antigo
+func (r *MySQLContactRepository) FindDuplicates(sectorID int) ([]*models.DuplicateContactGroup, error) {
+	contacts, err := r.GetBySector(sectorID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*models.DuplicateContactGroup)
+	var numbers []string
+	for _, contact := range contacts {
+		number, _ := normalizeNumber(sectorID, contact.Number)
+		group, exists := groups[number]
+		if !exists {
+			group = &models.DuplicateContactGroup{Number: number}
+			groups[number] = group
+			numbers = append(numbers, number)
+		}
+		group.Contacts = append(group.Contacts, contact)
+	}
+
+	var duplicates []*models.DuplicateContactGroup
+	for _, number := range numbers {
+		group := groups[number]
+		if len(group.Contacts) < 2 {
+			continue
+		}
+
+		sort.Slice(group.Contacts, func(i, j int) bool { return group.Contacts[i].ID < group.Contacts[j].ID })
+		group.SuggestedSurvivorID = group.Contacts[0].ID
+		for _, contact := range group.Contacts {
+			if contact.Number == number {
+				group.SuggestedSurvivorID = contact.ID
+				break
+			}
+		}
+		duplicates = append(duplicates, group)
+	}
+
+	return duplicates, nil
+}
+
+// Merge incorpora os contatos duplicados ao sobrevivente: move mensagens, card e destinatários de
+// campanhas, combina os dados cadastrais e remove os duplicados, tudo em uma única transação
+func (r *MySQLContactRepository) Merge(sectorID int, survivorID int, duplicateIDs []int) (*models.Contact, error) {
+	survivor, err := r.getContactByID(sectorID, survivorID)
+	if err != nil {
+		return nil, err
+	}
+	if survivor == nil {
+		return nil, fmt.Errorf("contato sobrevivente %d não encontrado no setor", survivorID)
+	}
+
+	var duplicates []*models.Contact
+	for _, id := range duplicateIDs {
+		if id == survivorID {
+			return nil, fmt.Errorf("o contato sobrevivente não pode estar entre os duplicados")
+		}
+		duplicate, err := r.getContactByID(sectorID, id)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate == nil {
+			return nil, fmt.Errorf("contato duplicado %d não encontrado no setor", id)
+		}
+		if !sameContact(sectorID, survivor, duplicate) {
+			return nil, fmt.Errorf("contato %d não tem o mesmo número nem o mesmo LID do contato sobrevivente", id)
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+	if len(duplicates) == 0 {
+		return nil, fmt.Errorf("nenhum contato duplicado informado")
+	}
+
+	mergeContactData(sectorID, survivor, duplicates)
+
+	placeholders := make([]string, len(duplicates))
+	ids := make([]interface{}, len(duplicates))
+	for i, duplicate := range duplicates {
+		placeholders[i] = "?"
+		ids[i] = duplicate.ID
+	}
+	in := "(" + strings.Join(placeholders, ", ") + ")"
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE messages SET contato_id = ? WHERE id_setor = ? AND contato_id IN `+in,
+		append([]interface{}{survivorID, sectorID}, ids...)...); err != nil {
+		return nil, fmt.Errorf("error moving messages: %v", err)
+	}
+
+	// O contato tem um único card no quadro: o sobrevivente herda o card mais antigo se ainda não tiver um
+	var survivorHasCard bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM cards WHERE contact_id = ? AND sector_id = ?)`,
+		survivorID, sectorID).Scan(&survivorHasCard); err != nil {
+		return nil, fmt.Errorf("error checking survivor card: %v", err)
+	}
+	if !survivorHasCard {
+		if _, err := tx.Exec(`UPDATE cards SET contact_id = ? WHERE sector_id = ? AND contact_id IN `+in+` ORDER BY id ASC LIMIT 1`,
+			append([]interface{}{survivorID, sectorID}, ids...)...); err != nil {
+			return nil, fmt.Errorf("error moving card: %v", err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM cards WHERE sector_id = ? AND contact_id IN `+in,
+		append([]interface{}{sectorID}, ids...)...); err != nil {
+		return nil, fmt.Errorf("error removing duplicate cards: %v", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE campaign_recipients SET contact_id = ? WHERE contact_id IN `+in,
+		append([]interface{}{survivorID}, ids...)...); err != nil {
+		return nil, fmt.Errorf("error moving campaign recipients: %v", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE contacts
+		SET name = ?, number = ?, lid = ?, avatar_url = ?, tag_id = ?, email = ?, notes = ?, updated_at = NOW()
+		WHERE id = ?`,
+		survivor.Name,
+		survivor.Number,
+		utils.NullString(survivor.LID),
+		utils.NullString(survivor.AvatarURL),
+		utils.NullInt(survivor.TagID),
+		utils.NullString(survivor.Email),
+		utils.NullString(survivor.Notes),
+		survivorID,
+	); err != nil {
+		return nil, fmt.Errorf("error updating survivor contact: %v", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM contacts WHERE sector_id = ? AND id IN `+in,
+		append([]interface{}{sectorID}, ids...)...); err != nil {
+		return nil, fmt.Errorf("error removing duplicate contacts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing merge: %v", err)
+	}
+
+	utils.LogInfo("Contatos %v incorporados ao contato %d no setor %d", duplicateIDs, survivorID, sectorID)
+	go r.sendContactsList(sectorID)
+
+	return r.getContactByID(sectorID, survivorID)
+}
+
+// sameContact indica se o duplicado é a mesma pessoa do sobrevivente: o número normalizado ou o LID precisam coincidir
+func sameContact(sectorID int, survivor *models.Contact, duplicate *models.Contact) bool {
+	survivorNumber, _ := normalizeNumber(sectorID, survivor.Number)
+	duplicateNumber, _ := normalizeNumber(sectorID, duplicate.Number)
+	if survivorNumber == duplicateNumber {
+		return true
+	}
+	return survivor.LID != "" && (duplicate.LID == survivor.LID || duplicate.Number == survivor.LID)
+}
+
+// mergeContactData preenche os dados vazios do sobrevivente com os dos duplicados e junta as observações
+func mergeContactData(sectorID int, survivor *models.Contact, duplicates []*models.Contact) {
+	number, _ := normalizeNumber(sectorID, survivor.Number)
+	nameIsNumber := survivor.Name == "" || survivor.Name == survivor.Number || survivor.Name == number
+
+	notes := []string{}
+	if strings.TrimSpace(survivor.Notes) != "" {
+		notes = append(notes, strings.TrimSpace(survivor.Notes))
+	}
+
+	for _, duplicate := range duplicates {
+		if nameIsNumber && duplicate.Name != "" && duplicate.Name != duplicate.Number {
+			if duplicateNumber, _ := normalizeNumber(sectorID, duplicate.Number); duplicate.Name != duplicateNumber {
+				survivor.Name = duplicate.Name
+				nameIsNumber = false
+			}
+		}
+		if survivor.AvatarURL == "" {
+			survivor.AvatarURL = duplicate.AvatarURL
+		}
+		if survivor.Email == "" {
+			survivor.Email = duplicate.Email
+		}
+		if survivor.LID == "" {
+			survivor.LID = duplicate.LID
+		}
+		if survivor.TagID == 0 {
+			survivor.TagID = duplicate.TagID
+		}
+
+		note := strings.TrimSpace(duplicate.Notes)
+		if note != "" && !containsString(notes, note) {
+			notes = append(notes, note)
+		}
+	}
+
+	survivor.Number = number
+	survivor.Notes = strings.Join(notes, "\n\n")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
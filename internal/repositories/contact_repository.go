@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
 	"whatsapp-bot/internal/utils"
 	"whatsapp-bot/internal/wsnotify"
 )
@@ -56,28 +57,54 @@ func (r *MySQLContactRepository) Save(contact *models.Contact) error {
 	return nil
 }
 
-func (r *MySQLContactRepository) GetByNumber(sectorID int, number string) (*models.Contact, error) {
-	// Normalizar o número usando o mesmo padrão
-	normalizedNumber := number
-	normalizedNumber = strings.TrimSuffix(normalizedNumber, "@s.whatsapp.net")
-	if len(normalizedNumber) == 11 || len(normalizedNumber) == 10 {
-		normalizedNumber = "55" + normalizedNumber
+// normalizeNumber retorna o número no formato gravado nos contatos e as outras formas em que
+// o mesmo número pode ter sido gravado anteriormente (com sufixo do servidor, sem o nono dígito)
+func normalizeNumber(sectorID int, number string) (string, []string) {
+	parsed, err := phone.ForSector(sectorID, number)
+	if err != nil {
+		// Identificadores que não são números (ex: LIDs) são usados como vieram
+		raw := strings.TrimSuffix(strings.TrimSpace(number), "@s.whatsapp.net")
+		return raw, []string{raw}
 	}
 
+	var candidates []string
+	for _, variant := range parsed.Variants() {
+		candidates = append(candidates, variant, variant+"@s.whatsapp.net")
+	}
+	return parsed.Digits(), candidates
+}
+
+// numberCondition monta o filtro SQL que encontra o contato por qualquer uma das formas do número
+func numberCondition(candidates []string) (string, []interface{}) {
+	placeholders := make([]string, len(candidates))
+	args := make([]interface{}, len(candidates))
+	for i, candidate := range candidates {
+		placeholders[i] = "?"
+		args[i] = candidate
+	}
+	return "number IN (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+func (r *MySQLContactRepository) GetByNumber(sectorID int, number string) (*models.Contact, error) {
+	_, candidates := normalizeNumber(sectorID, number)
+	condition, conditionArgs := numberCondition(candidates)
+
 	query := `
 		SELECT 
 			id, name, number, avatar_url, sector_id, tag_id,
 			is_active, email, notes, ai_active, assigned_to,
-			priority, contact_status, created_at, updated_at, is_official, is_viewed
+			priority, contact_status, created_at, updated_at, is_official, is_viewed, lid
 		FROM contacts 
 		WHERE sector_id = ? 
-		AND (number = ? OR number LIKE ?)`
+		AND ` + condition + `
+		ORDER BY id ASC
+		LIMIT 1`
 
 	contact := &models.Contact{}
-	var avatarURL, email, notes sql.NullString
+	var avatarURL, email, notes, lid sql.NullString
 	var tagID, assignedTo sql.NullInt64
 
-	err := r.db.QueryRow(query, sectorID, normalizedNumber, "%"+normalizedNumber+"%").Scan(
+	err := r.db.QueryRow(query, append([]interface{}{sectorID}, conditionArgs...)...).Scan(
 		&contact.ID,
 		&contact.Name,
 		&contact.Number,
@@ -95,6 +122,7 @@ func (r *MySQLContactRepository) GetByNumber(sectorID int, number string) (*mode
 		&contact.UpdatedAt,
 		&contact.IsOfficial,
 		&contact.IsViewed,
+		&lid,
 	)
 
 	if err == sql.ErrNoRows {
@@ -107,6 +135,7 @@ func (r *MySQLContactRepository) GetByNumber(sectorID int, number string) (*mode
 	contact.AvatarURL = avatarURL.String
 	contact.Email = email.String
 	contact.Notes = notes.String
+	contact.LID = lid.String
 	if tagID.Valid {
 		contact.TagID = int(tagID.Int64)
 	}
@@ -122,7 +151,7 @@ func (r *MySQLContactRepository) GetBySector(sectorID int) ([]*models.Contact, e
 		SELECT 
 			id, name, number, avatar_url, sector_id, tag_id, 
 			is_active, email, notes, ai_active, assigned_to,
-			priority, contact_status, created_at, updated_at, is_official, is_viewed, COALESCE(` + "`order`" + `, id) AS contact_order
+			priority, contact_status, created_at, updated_at, is_official, is_viewed, lid, COALESCE(` + "`order`" + `, id) AS contact_order
 		FROM contacts 
 		WHERE sector_id = ? 
 		ORDER BY contact_order ASC`
@@ -137,7 +166,7 @@ func (r *MySQLContactRepository) GetBySector(sectorID int) ([]*models.Contact, e
 
 	for rows.Next() {
 		contact := &models.Contact{}
-		var avatarURL, email, notes sql.NullString
+		var avatarURL, email, notes, lid sql.NullString
 		var tagID, assignedTo sql.NullInt64
 
 		err := rows.Scan(
@@ -158,6 +187,7 @@ func (r *MySQLContactRepository) GetBySector(sectorID int) ([]*models.Contact, e
 			&contact.UpdatedAt,
 			&contact.IsOfficial,
 			&contact.IsViewed,
+			&lid,
 			&contact.Order,
 		)
 
@@ -168,6 +198,7 @@ func (r *MySQLContactRepository) GetBySector(sectorID int) ([]*models.Contact, e
 		contact.AvatarURL = avatarURL.String
 		contact.Email = email.String
 		contact.Notes = notes.String
+		contact.LID = lid.String
 
 		if tagID.Valid {
 			contact.TagID = int(tagID.Int64)
@@ -233,11 +264,7 @@ func (r *MySQLContactRepository) Update(contact *models.Contact) error {
 }
 
 func (r *MySQLContactRepository) CreateIfNotExists(sectorID int, number string) (*models.Contact, error) {
-	normalizedNumber := number
-	normalizedNumber = strings.TrimSuffix(normalizedNumber, "@s.whatsapp.net")
-	if len(normalizedNumber) == 11 || len(normalizedNumber) == 10 {
-		normalizedNumber = "55" + normalizedNumber
-	}
+	normalizedNumber, _ := normalizeNumber(sectorID, number)
 
 	contact, err := r.GetByNumber(sectorID, normalizedNumber)
 	if err != nil {
@@ -393,13 +420,16 @@ func (r *MySQLContactRepository) CreateIfNotExists(sectorID int, number string)
 }
 
 func (r *MySQLContactRepository) SetViewed(sectorID int, number string) error {
+	_, candidates := normalizeNumber(sectorID, number)
+	condition, conditionArgs := numberCondition(candidates)
+
 	query := `
 		UPDATE contacts 
 		SET is_viewed = 1,
 			updated_at = NOW()
-		WHERE sector_id = ? AND number = ?`
+		WHERE sector_id = ? AND ` + condition
 
-	result, err := r.db.Exec(query, sectorID, number)
+	result, err := r.db.Exec(query, append([]interface{}{sectorID}, conditionArgs...)...)
 	if err != nil {
 		return fmt.Errorf("error updating contact viewed status: %v", err)
 	}
@@ -463,18 +493,70 @@ func (r *MySQLContactRepository) SetViewedByID(sectorID int, contactID int) erro
 	return nil
 }
 
+// GetByID busca um contato do setor pelo seu ID
+func (r *MySQLContactRepository) GetByID(sectorID int, contactID int) (*models.Contact, error) {
+	return r.getContactByID(sectorID, contactID)
+}
+
+// GetByLID busca um contato do setor pelo identificador LID (ex: 123456789@lid)
+func (r *MySQLContactRepository) GetByLID(sectorID int, lid string) (*models.Contact, error) {
+	var contactID int
+	err := r.db.QueryRow(`
+		SELECT id FROM contacts
+		WHERE sector_id = ? AND (lid = ? OR number = ?)
+		ORDER BY id ASC
+		LIMIT 1`,
+		sectorID, lid, lid).Scan(&contactID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting contact by lid: %v", err)
+	}
+
+	return r.getContactByID(sectorID, contactID)
+}
+
+// SetLID registra o identificador LID do contato
+func (r *MySQLContactRepository) SetLID(contactID int, lid string) error {
+	_, err := r.db.Exec(`
+		UPDATE contacts
+		SET lid = ?, updated_at = NOW()
+		WHERE id = ?`,
+		utils.NullString(lid), contactID)
+	if err != nil {
+		return fmt.Errorf("error updating contact lid: %v", err)
+	}
+	return nil
+}
+
+// SetNumber substitui o número do contato, usado quando um contato criado só com o LID
+// passa a ter o telefone conhecido
+func (r *MySQLContactRepository) SetNumber(contactID int, number string) error {
+	_, err := r.db.Exec(`
+		UPDATE contacts
+		SET number = ?, updated_at = NOW()
+		WHERE id = ?`,
+		number, contactID)
+	if err != nil {
+		return fmt.Errorf("error updating contact number: %v", err)
+	}
+	return nil
+}
+
 // Função auxiliar para buscar contato por ID
 func (r *MySQLContactRepository) getContactByID(sectorID int, contactID int) (*models.Contact, error) {
 	query := `
 		SELECT 
 			id, name, number, avatar_url, sector_id, tag_id, 
 			is_active, email, notes, ai_active, assigned_to,
-			priority, contact_status, created_at, updated_at, is_official, is_viewed, COALESCE(` + "`order`" + `, id) AS contact_order
+			priority, contact_status, created_at, updated_at, is_official, is_viewed, lid, COALESCE(` + "`order`" + `, id) AS contact_order
 		FROM contacts 
 		WHERE sector_id = ? AND id = ?`
 
 	contact := &models.Contact{}
-	var avatarURL, email, notes sql.NullString
+	var avatarURL, email, notes, lid sql.NullString
 	var tagID, assignedTo sql.NullInt64
 
 	err := r.db.QueryRow(query, sectorID, contactID).Scan(
@@ -495,6 +577,7 @@ func (r *MySQLContactRepository) getContactByID(sectorID int, contactID int) (*m
 		&contact.UpdatedAt,
 		&contact.IsOfficial,
 		&contact.IsViewed,
+		&lid,
 		&contact.Order,
 	)
 
@@ -509,6 +592,7 @@ func (r *MySQLContactRepository) getContactByID(sectorID int, contactID int) (*m
 	contact.AvatarURL = avatarURL.String
 	contact.Email = email.String
 	contact.Notes = notes.String
+	contact.LID = lid.String
 
 	if tagID.Valid {
 		contact.TagID = int(tagID.Int64)
@@ -562,20 +646,17 @@ func (r *MySQLContactRepository) GetViewedStatus(sectorID int) (map[int]bool, er
 
 func (r *MySQLContactRepository) SetUnviewed(sectorID int, number string) error {
 	// Normalizar o número usando o mesmo padrão do CreateIfNotExists
-	normalizedNumber := number
-	normalizedNumber = strings.TrimSuffix(normalizedNumber, "@s.whatsapp.net")
-	if len(normalizedNumber) == 11 || len(normalizedNumber) == 10 {
-		normalizedNumber = "55" + normalizedNumber
-	}
+	normalizedNumber, candidates := normalizeNumber(sectorID, number)
+	condition, conditionArgs := numberCondition(candidates)
 
 	query := `
 		UPDATE contacts 
 		SET is_viewed = 0,
 			updated_at = NOW()
 		WHERE sector_id = ? 
-		AND (number = ? OR number LIKE ?)`
+		AND ` + condition
 
-	result, err := r.db.Exec(query, sectorID, normalizedNumber, "%"+normalizedNumber+"%")
+	result, err := r.db.Exec(query, append([]interface{}{sectorID}, conditionArgs...)...)
 	if err != nil {
 		return fmt.Errorf("error updating contact unviewed status: %v", err)
 	}
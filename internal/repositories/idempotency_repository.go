@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"whatsapp-bot/internal/models"
+)
+
+type MySQLIdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLIdempotencyRepository(db *sql.DB) *MySQLIdempotencyRepository {
+	return &MySQLIdempotencyRepository{db: db}
+}
+
+// Reserve grava a chave como pendente. Retorna false se a chave já estiver em uso e ainda válida. Chaves
+// expiradas e chaves pendentes cuja reserva venceu (a requisição original não terminou) são assumidas
+func (r *MySQLIdempotencyRepository) Reserve(record *models.IdempotencyRecord) (bool, error) {
+	now := time.Now().UTC()
+
+	record.Status = models.IdempotencyStatusPending
+	record.CreatedAt = now
+	record.LockedUntil = now.Add(models.IdempotencyLockTimeout)
+	if record.ExpiresAt.IsZero() {
+		record.ExpiresAt = now.Add(models.IdempotencyTTL)
+	}
+
+	result, err := r.db.Exec(`
+		INSERT IGNORE INTO idempotency_keys (
+			sector_id, idem_key, endpoint, request_hash, status, locked_until, expires_at, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.SectorID,
+		record.Key,
+		record.Endpoint,
+		record.RequestHash,
+		record.Status,
+		record.LockedUntil,
+		record.ExpiresAt.UTC(),
+		record.CreatedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error reserving idempotency key: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rows > 0 {
+		return true, nil
+	}
+
+	// A chave existe: assumi-la apenas se expirou ou se a reserva da requisição original venceu.
+	// A condição é reavaliada na atualização, então só uma requisição concorrente consegue assumir
+	result, err = r.db.Exec(`
+		UPDATE idempotency_keys
+		SET request_hash = ?,
+			status = ?,
+			status_code = NULL,
+			response_body = NULL,
+			outbound_message_id = NULL,
+			locked_until = ?,
+			expires_at = ?,
+			created_at = ?
+		WHERE sector_id = ? AND idem_key = ? AND endpoint = ?
+			AND (expires_at < ? OR (status = ? AND (locked_until IS NULL OR locked_until < ?)))`,
+		record.RequestHash,
+		record.Status,
+		record.LockedUntil,
+		record.ExpiresAt.UTC(),
+		record.CreatedAt,
+		record.SectorID,
+		record.Key,
+		record.Endpoint,
+		now,
+		models.IdempotencyStatusPending,
+		now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error taking over idempotency key: %v", err)
+	}
+
+	rows, err = result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *MySQLIdempotencyRepository) Get(sectorID int, key string, endpoint string) (*models.IdempotencyRecord, error) {
+	record := &models.IdempotencyRecord{}
+	var statusCode, outboundMessageID sql.NullInt64
+	var lockedUntil sql.NullTime
+
+	err := r.db.QueryRow(`
+		SELECT sector_id, idem_key, endpoint, request_hash, status, status_code,
+			response_body, outbound_message_id, locked_until, expires_at, created_at
+		FROM idempotency_keys
+		WHERE sector_id = ? AND idem_key = ? AND endpoint = ? AND expires_at >= ?`,
+		sectorID, key, endpoint, time.Now().UTC()).Scan(
+		&record.SectorID,
+		&record.Key,
+		&record.Endpoint,
+		&record.RequestHash,
+		&record.Status,
+		&statusCode,
+		&record.ResponseBody,
+		&outboundMessageID,
+		&lockedUntil,
+		&record.ExpiresAt,
+		&record.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting idempotency key: %v", err)
+	}
+
+	record.StatusCode = int(statusCode.Int64)
+	record.OutboundMessageID = outboundMessageID.Int64
+	record.LockedUntil = lockedUntil.Time
+	return record, nil
+}
+
+// Complete grava a resposta da requisição original para ser devolvida nas repetições
+func (r *MySQLIdempotencyRepository) Complete(record *models.IdempotencyRecord) error {
+	record.Status = models.IdempotencyStatusCompleted
+
+	_, err := r.db.Exec(`
+		UPDATE idempotency_keys
+		SET status = ?,
+			status_code = ?,
+			response_body = ?,
+			outbound_message_id = ?,
+			locked_until = NULL
+		WHERE sector_id = ? AND idem_key = ? AND endpoint = ?`,
+		record.Status,
+		record.StatusCode,
+		record.ResponseBody,
+		nullInt64(record.OutboundMessageID),
+		record.SectorID,
+		record.Key,
+		record.Endpoint,
+	)
+	if err != nil {
+		return fmt.Errorf("error completing idempotency key: %v", err)
+	}
+	return nil
+}
+
+// Release libera a chave para que o cliente possa repetir uma requisição que falhou
+func (r *MySQLIdempotencyRepository) Release(sectorID int, key string, endpoint string) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE sector_id = ? AND idem_key = ? AND endpoint = ?`, sectorID, key, endpoint)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key: %v", err)
+	}
+	return nil
+}
+
+// PurgeExpired remove até limit chaves expiradas e retorna quantas foram removidas
+func (r *MySQLIdempotencyRepository) PurgeExpired(limit int) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at < ? LIMIT ?`, time.Now().UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("error purging idempotency keys: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rows, nil
+}
@@ -21,13 +21,14 @@ func (r *MySQLMessageRepository) Save(message *models.Message) error {
 
 	query := `
 		INSERT INTO messages (
-			conteudo, tipo, url, nome_arquivo, mime_type, 
+			conteudo, conteudo_enviado, tipo, url, nome_arquivo, mime_type, 
 			id_setor, contato_id, data_envio, enviado, lido, 
 			WhatsAppMessageId, is_official
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	result, err := r.db.Exec(query,
 		message.Conteudo,
+		utils.NullString(message.ConteudoEnviado),
 		message.Tipo,
 		utils.NullString(message.URL),
 		utils.NullString(message.NomeArquivo),
@@ -195,3 +196,36 @@ func (r *MySQLMessageRepository) MarkMessagesAsRead(messageIDs []int) error {
 	_, err := r.db.Exec(query, args...)
 	return err
 }
+
+// GetUnreadIncoming retorna as mensagens recebidas do contato que ainda não foram lidas. Os registros de
+// chamada ficam de fora: guardam o ID da chamada, que não é uma mensagem a confirmar no WhatsApp
+func (r *MySQLMessageRepository) GetUnreadIncoming(sectorID int, contactID int) ([]*models.Message, error) {
+	query := `
+		SELECT 
+			id, conteudo, tipo, url, nome_arquivo, mime_type,
+			id_setor, contato_id, data_envio, enviado, lido,
+			WhatsAppMessageId, is_official, created_at
+		FROM messages 
+		WHERE id_setor = ? AND contato_id = ? AND enviado = 0 AND lido = 0 AND tipo <> 'call'
+		ORDER BY id ASC`
+
+	return r.fetchMessages(query, sectorID, contactID)
+}
+
+// MarkContactMessagesAsRead marca todas as mensagens recebidas do contato como lidas em um único comando
+func (r *MySQLMessageRepository) MarkContactMessagesAsRead(sectorID int, contactID int) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE messages
+		SET lido = 1
+		WHERE id_setor = ? AND contato_id = ? AND enviado = 0 AND lido = 0`,
+		sectorID, contactID)
+	if err != nil {
+		return 0, fmt.Errorf("error marking contact messages as read: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rows, nil
+}
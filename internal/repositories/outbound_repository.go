@@ -0,0 +1,402 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+type MySQLOutboundMessageRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLOutboundMessageRepository(db *sql.DB) *MySQLOutboundMessageRepository {
+	return &MySQLOutboundMessageRepository{db: db}
+}
+
+const outboundColumns = `
+	id, sector_id, kind, recipient, content, media_key, file_name,
+	user_id, is_anonymous, sent_at, status, attempts, max_attempts,
+	next_attempt_at, last_error, whatsapp_message_id, pending_message_id,
+	source, source_id, created_at, updated_at`
+
+func (r *MySQLOutboundMessageRepository) Enqueue(message *models.OutboundMessage) error {
+	now := time.Now().UTC()
+	if message.Status == "" {
+		message.Status = models.OutboundStatusQueued
+	}
+	if message.MaxAttempts <= 0 {
+		message.MaxAttempts = models.DefaultOutboundMaxAttempts
+	}
+	if message.Source == "" {
+		message.Source = models.OutboundSourceAPI
+	}
+	if message.NextAttemptAt.IsZero() {
+		message.NextAttemptAt = now
+	}
+
+	query := `
+		INSERT INTO outbound_messages (
+			sector_id, kind, recipient, content, media_key, file_name,
+			user_id, is_anonymous, sent_at, status, attempts, max_attempts,
+			next_attempt_at, source, source_id, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.Exec(query,
+		message.SectorID,
+		message.Kind,
+		message.Recipient,
+		message.Content,
+		utils.NullString(message.MediaKey),
+		utils.NullString(message.FileName),
+		utils.NullInt(utils.GetIntFromPointer(message.UserID)),
+		utils.BoolToInt(message.IsAnonymous),
+		message.SentAt.UTC(),
+		message.Status,
+		message.MaxAttempts,
+		message.NextAttemptAt.UTC(),
+		message.Source,
+		nullInt64(message.SourceID),
+		now,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("error enqueuing outbound message: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert id: %v", err)
+	}
+
+	message.ID = id
+	message.CreatedAt = now
+	message.UpdatedAt = now
+	return nil
+}
+
+func (r *MySQLOutboundMessageRepository) GetByID(id int64) (*models.OutboundMessage, error) {
+	messages, err := r.fetchOutbound(`SELECT `+outboundColumns+` FROM outbound_messages WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return messages[0], nil
+}
+
+// ClaimDue seleciona as mensagens prontas para envio do setor e as marca como "sending". A seleção trava as linhas
+// até o fim da transação para que duas instâncias não reservem a mesma mensagem
+func (r *MySQLOutboundMessageRepository) ClaimDue(sectorID int, limit int) ([]*models.OutboundMessage, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT `+outboundColumns+`
+		FROM outbound_messages
+		WHERE sector_id = ? AND status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+		FOR UPDATE`,
+		sectorID, models.OutboundStatusQueued, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying outbound messages: %v", err)
+	}
+	messages, err := scanOutbound(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{models.OutboundStatusSending, time.Now().UTC()}
+	for _, message := range messages {
+		args = append(args, message.ID)
+		message.Status = models.OutboundStatusSending
+	}
+	args = append(args, models.OutboundStatusQueued)
+	query := "UPDATE outbound_messages SET status = ?, updated_at = ? WHERE id IN (?" + strings.Repeat(",?", len(messages)-1) + ") AND status = ?"
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming outbound messages: %v", err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if claimed != int64(len(messages)) {
+		return nil, fmt.Errorf("error claiming outbound messages: %d of %d messages changed", claimed, len(messages))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing outbound claim: %v", err)
+	}
+
+	return messages, nil
+}
+
+// SetPendingMessageID grava o ID do WhatsApp reservado para a mensagem, reaproveitado em todas as tentativas
+func (r *MySQLOutboundMessageRepository) SetPendingMessageID(id int64, messageID string) error {
+	_, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET pending_message_id = ?, updated_at = ?
+		WHERE id = ?`,
+		messageID, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error setting outbound pending message id: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLOutboundMessageRepository) MarkSent(id int64, whatsappMessageID string) error {
+	_, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET status = ?,
+			attempts = attempts + 1,
+			whatsapp_message_id = ?,
+			last_error = NULL,
+			updated_at = ?
+		WHERE id = ?`,
+		models.OutboundStatusSent, utils.NullString(whatsappMessageID), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error marking outbound message as sent: %v", err)
+	}
+	return nil
+}
+
+// MarkRetry registra a falha da tentativa e agenda a próxima
+func (r *MySQLOutboundMessageRepository) MarkRetry(id int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET status = ?,
+			attempts = attempts + 1,
+			last_error = ?,
+			next_attempt_at = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		models.OutboundStatusQueued, lastError, nextAttemptAt.UTC(), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error scheduling outbound retry: %v", err)
+	}
+	return nil
+}
+
+// Postpone devolve a mensagem para a fila sem contar tentativa, adiando o próximo envio
+func (r *MySQLOutboundMessageRepository) Postpone(id int64, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET status = ?,
+			next_attempt_at = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		models.OutboundStatusQueued, nextAttemptAt.UTC(), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error postponing outbound message: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLOutboundMessageRepository) MarkDead(id int64, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET status = ?,
+			attempts = attempts + 1,
+			last_error = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		models.OutboundStatusDead, lastError, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error marking outbound message as dead: %v", err)
+	}
+	return nil
+}
+
+// Requeue devolve uma mensagem dead para a fila, zerando as tentativas
+func (r *MySQLOutboundMessageRepository) Requeue(id int64) error {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET status = ?,
+			attempts = 0,
+			next_attempt_at = ?,
+			updated_at = ?
+		WHERE id = ? AND status = ?`,
+		models.OutboundStatusQueued, now, now, id, models.OutboundStatusDead)
+	if err != nil {
+		return fmt.Errorf("error requeuing outbound message: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("outbound message not found or not dead")
+	}
+	return nil
+}
+
+func (r *MySQLOutboundMessageRepository) ListBySector(sectorID int, status string, limit int) ([]*models.OutboundMessage, error) {
+	if status == "" {
+		return r.fetchOutbound(`
+			SELECT `+outboundColumns+`
+			FROM outbound_messages
+			WHERE sector_id = ?
+			ORDER BY id DESC
+			LIMIT ?`,
+			sectorID, limit)
+	}
+
+	return r.fetchOutbound(`
+		SELECT `+outboundColumns+`
+		FROM outbound_messages
+		WHERE sector_id = ? AND status = ?
+		ORDER BY id DESC
+		LIMIT ?`,
+		sectorID, status, limit)
+}
+
+// ReleaseClaimed devolve para a fila mensagens reservadas que não chegaram a ser enviadas, sem contar tentativa
+func (r *MySQLOutboundMessageRepository) ReleaseClaimed(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{models.OutboundStatusQueued, time.Now().UTC()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, models.OutboundStatusSending)
+	query := "UPDATE outbound_messages SET status = ?, updated_at = ? WHERE id IN (?" + strings.Repeat(",?", len(ids)-1) + ") AND status = ?"
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("error releasing outbound messages: %v", err)
+	}
+	return nil
+}
+
+// ResetSending devolve para a fila mensagens que ficaram presas em "sending" após uma reinicialização
+func (r *MySQLOutboundMessageRepository) ResetSending() error {
+	_, err := r.db.Exec(`
+		UPDATE outbound_messages
+		SET status = ?, updated_at = ?
+		WHERE status = ?`,
+		models.OutboundStatusQueued, time.Now().UTC(), models.OutboundStatusSending)
+	if err != nil {
+		return fmt.Errorf("error resetting outbound messages: %v", err)
+	}
+	return nil
+}
+
+// PendingSectors lista os setores que possuem mensagens aguardando envio
+func (r *MySQLOutboundMessageRepository) PendingSectors() ([]int, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT sector_id
+		FROM outbound_messages
+		WHERE status = ?`,
+		models.OutboundStatusQueued)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pending sectors: %v", err)
+	}
+	defer rows.Close()
+
+	var sectors []int
+	for rows.Next() {
+		var sectorID int
+		if err := rows.Scan(&sectorID); err != nil {
+			return nil, fmt.Errorf("error scanning pending sector: %v", err)
+		}
+		sectors = append(sectors, sectorID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending sectors: %v", err)
+	}
+
+	return sectors, nil
+}
+
+func (r *MySQLOutboundMessageRepository) fetchOutbound(query string, args ...interface{}) ([]*models.OutboundMessage, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying outbound messages: %v", err)
+	}
+	return scanOutbound(rows)
+}
+
+// scanOutbound lê e fecha o resultado de uma consulta feita com outboundColumns
+func scanOutbound(rows *sql.Rows) ([]*models.OutboundMessage, error) {
+	defer rows.Close()
+
+	var messages []*models.OutboundMessage
+
+	for rows.Next() {
+		message := &models.OutboundMessage{}
+		var content, mediaKey, fileName, lastError, whatsappMessageID, pendingMessageID sql.NullString
+		var userID, sourceID sql.NullInt64
+		var sentAt sql.NullTime
+
+		err := rows.Scan(
+			&message.ID,
+			&message.SectorID,
+			&message.Kind,
+			&message.Recipient,
+			&content,
+			&mediaKey,
+			&fileName,
+			&userID,
+			&message.IsAnonymous,
+			&sentAt,
+			&message.Status,
+			&message.Attempts,
+			&message.MaxAttempts,
+			&message.NextAttemptAt,
+			&lastError,
+			&whatsappMessageID,
+			&pendingMessageID,
+			&message.Source,
+			&sourceID,
+			&message.CreatedAt,
+			&message.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning outbound message: %v", err)
+		}
+
+		message.Content = content.String
+		message.MediaKey = mediaKey.String
+		message.FileName = fileName.String
+		message.LastError = lastError.String
+		message.WhatsAppMessageID = whatsappMessageID.String
+		message.PendingMessageID = pendingMessageID.String
+		message.SentAt = sentAt.Time
+		message.SourceID = sourceID.Int64
+		if userID.Valid {
+			id := int(userID.Int64)
+			message.UserID = &id
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbound messages: %v", err)
+	}
+
+	return messages, nil
+}
+
+func nullInt64(i int64) sql.NullInt64 {
+	if i == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: i, Valid: true}
+}
@@ -0,0 +1,283 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+type MySQLScheduledMessageRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLScheduledMessageRepository(db *sql.DB) *MySQLScheduledMessageRepository {
+	return &MySQLScheduledMessageRepository{db: db}
+}
+
+const scheduledColumns = `
+	id, sector_id, kind, recipient, content, media_key, file_name,
+	user_id, is_anonymous, send_at, timezone, status, outbound_message_id,
+	whatsapp_message_id, last_error, created_at, updated_at`
+
+func (r *MySQLScheduledMessageRepository) Create(message *models.ScheduledMessage) error {
+	now := time.Now().UTC()
+	if message.Status == "" {
+		message.Status = models.ScheduledStatusPending
+	}
+
+	query := `
+		INSERT INTO scheduled_messages (
+			sector_id, kind, recipient, content, media_key, file_name,
+			user_id, is_anonymous, send_at, timezone, status, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := r.db.Exec(query,
+		message.SectorID,
+		message.Kind,
+		message.Recipient,
+		message.Content,
+		utils.NullString(message.MediaKey),
+		utils.NullString(message.FileName),
+		utils.NullInt(utils.GetIntFromPointer(message.UserID)),
+		utils.BoolToInt(message.IsAnonymous),
+		message.SendAt.UTC(),
+		message.Timezone,
+		message.Status,
+		now,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating scheduled message: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert id: %v", err)
+	}
+
+	message.ID = id
+	message.CreatedAt = now
+	message.UpdatedAt = now
+	return nil
+}
+
+func (r *MySQLScheduledMessageRepository) GetByID(id int64) (*models.ScheduledMessage, error) {
+	messages, err := r.fetchScheduled(`SELECT `+scheduledColumns+` FROM scheduled_messages WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return messages[0], nil
+}
+
+// ListDue retorna as mensagens pendentes cujo horário de envio já foi atingido
+func (r *MySQLScheduledMessageRepository) ListDue(limit int) ([]*models.ScheduledMessage, error) {
+	return r.fetchScheduled(`
+		SELECT `+scheduledColumns+`
+		FROM scheduled_messages
+		WHERE status = ? AND send_at <= ?
+		ORDER BY send_at ASC, id ASC
+		LIMIT ?`,
+		models.ScheduledStatusPending, time.Now().UTC(), limit)
+}
+
+// Claim marca a mensagem como "queued" somente se ela ainda estiver pendente,
+// evitando disparar uma mensagem cancelada ou reagendada no meio do caminho
+func (r *MySQLScheduledMessageRepository) Claim(id int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE scheduled_messages
+		SET status = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND send_at <= ?`,
+		models.ScheduledStatusQueued, time.Now().UTC(), id, models.ScheduledStatusPending, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("error claiming scheduled message: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *MySQLScheduledMessageRepository) SetOutbound(id int64, outboundMessageID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE scheduled_messages
+		SET outbound_message_id = ?, updated_at = ?
+		WHERE id = ?`,
+		outboundMessageID, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error setting scheduled message outbound id: %v", err)
+	}
+	return nil
+}
+
+// ResetQueued recupera as mensagens reservadas que não chegaram a ser vinculadas à fila de envio após uma
+// reinicialização: as que já estão na fila recebem o vínculo e as demais voltam para "pending"
+func (r *MySQLScheduledMessageRepository) ResetQueued() error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec(`
+		UPDATE scheduled_messages s
+		JOIN outbound_messages o ON o.source = ? AND o.source_id = s.id
+		SET s.outbound_message_id = o.id, s.updated_at = ?
+		WHERE s.status = ? AND s.outbound_message_id IS NULL`,
+		models.OutboundSourceScheduled, now, models.ScheduledStatusQueued)
+	if err != nil {
+		return fmt.Errorf("error linking queued scheduled messages: %v", err)
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE scheduled_messages
+		SET status = ?, updated_at = ?
+		WHERE status = ? AND outbound_message_id IS NULL`,
+		models.ScheduledStatusPending, now, models.ScheduledStatusQueued)
+	if err != nil {
+		return fmt.Errorf("error resetting queued scheduled messages: %v", err)
+	}
+	return nil
+}
+
+// MarkResult registra o resultado final do envio (sent ou failed)
+func (r *MySQLScheduledMessageRepository) MarkResult(id int64, status string, whatsappMessageID string, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE scheduled_messages
+		SET status = ?,
+			whatsapp_message_id = ?,
+			last_error = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		status, utils.NullString(whatsappMessageID), utils.NullString(lastError), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error updating scheduled message result: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLScheduledMessageRepository) Cancel(id int64) error {
+	result, err := r.db.Exec(`
+		UPDATE scheduled_messages
+		SET status = ?, updated_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		models.ScheduledStatusCanceled, time.Now().UTC(), id, models.ScheduledStatusPending, models.ScheduledStatusFailed)
+	if err != nil {
+		return fmt.Errorf("error canceling scheduled message: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("scheduled message not found or already sent")
+	}
+	return nil
+}
+
+// Reschedule altera o horário de uma mensagem pendente ou com falha, devolvendo-a para "pending"
+func (r *MySQLScheduledMessageRepository) Reschedule(id int64, sendAt time.Time, timezone string) error {
+	result, err := r.db.Exec(`
+		UPDATE scheduled_messages
+		SET status = ?,
+			send_at = ?,
+			timezone = ?,
+			outbound_message_id = NULL,
+			last_error = NULL,
+			updated_at = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		models.ScheduledStatusPending, sendAt.UTC(), timezone, time.Now().UTC(), id,
+		models.ScheduledStatusPending, models.ScheduledStatusFailed)
+	if err != nil {
+		return fmt.Errorf("error rescheduling message: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("scheduled message not found or already sent")
+	}
+	return nil
+}
+
+func (r *MySQLScheduledMessageRepository) ListBySector(sectorID int, status string, limit int) ([]*models.ScheduledMessage, error) {
+	if status == "" {
+		return r.fetchScheduled(`
+			SELECT `+scheduledColumns+`
+			FROM scheduled_messages
+			WHERE sector_id = ?
+			ORDER BY send_at DESC, id DESC
+			LIMIT ?`,
+			sectorID, limit)
+	}
+
+	return r.fetchScheduled(`
+		SELECT `+scheduledColumns+`
+		FROM scheduled_messages
+		WHERE sector_id = ? AND status = ?
+		ORDER BY send_at ASC, id ASC
+		LIMIT ?`,
+		sectorID, status, limit)
+}
+
+func (r *MySQLScheduledMessageRepository) fetchScheduled(query string, args ...interface{}) ([]*models.ScheduledMessage, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying scheduled messages: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.ScheduledMessage
+
+	for rows.Next() {
+		message := &models.ScheduledMessage{}
+		var content, mediaKey, fileName, whatsappMessageID, lastError sql.NullString
+		var userID, outboundMessageID sql.NullInt64
+
+		err := rows.Scan(
+			&message.ID,
+			&message.SectorID,
+			&message.Kind,
+			&message.Recipient,
+			&content,
+			&mediaKey,
+			&fileName,
+			&userID,
+			&message.IsAnonymous,
+			&message.SendAt,
+			&message.Timezone,
+			&message.Status,
+			&outboundMessageID,
+			&whatsappMessageID,
+			&lastError,
+			&message.CreatedAt,
+			&message.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning scheduled message: %v", err)
+		}
+
+		message.Content = content.String
+		message.MediaKey = mediaKey.String
+		message.FileName = fileName.String
+		message.WhatsAppMessageID = whatsappMessageID.String
+		message.LastError = lastError.String
+		message.OutboundMessageID = outboundMessageID.Int64
+		if userID.Valid {
+			id := int(userID.Int64)
+			message.UserID = &id
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled messages: %v", err)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+type MySQLSectorSettingsRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLSectorSettingsRepository(db *sql.DB) *MySQLSectorSettingsRepository {
+	return &MySQLSectorSettingsRepository{db: db}
+}
+
+// GetBySector retorna as configurações do setor, ou os valores padrão se não houver registro
+func (r *MySQLSectorSettingsRepository) GetBySector(sectorID int) (*models.SectorSettings, error) {
+	query := `
+		SELECT sector_id, call_policy, call_reject_message,
+			sector_rate_per_minute, sector_burst, recipient_rate_per_minute,
+			recipient_burst, send_jitter_max_ms, typing_before_send,
+			signature_template, signature_placement, auto_mark_read_on_reply,
+			default_region
+		FROM sector_settings
+		WHERE sector_id = ?`
+
+	settings := models.DefaultSectorSettings(sectorID)
+	var callRejectMessage, signatureTemplate sql.NullString
+
+	err := r.db.QueryRow(query, sectorID).Scan(
+		&settings.SectorID,
+		&settings.CallPolicy,
+		&callRejectMessage,
+		&settings.SectorRatePerMinute,
+		&settings.SectorBurst,
+		&settings.RecipientRatePerMinute,
+		&settings.RecipientBurst,
+		&settings.SendJitterMaxMs,
+		&settings.TypingBeforeSend,
+		&signatureTemplate,
+		&settings.SignaturePlacement,
+		&settings.AutoMarkReadOnReply,
+		&settings.DefaultRegion,
+	)
+	if err == sql.ErrNoRows {
+		return models.DefaultSectorSettings(sectorID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting sector settings: %v", err)
+	}
+
+	settings.CallRejectMessage = callRejectMessage.String
+	settings.SignatureTemplate = signatureTemplate.String
+	return settings, nil
+}
+
+func (r *MySQLSectorSettingsRepository) Save(settings *models.SectorSettings) error {
+	query := `
+		INSERT INTO sector_settings (
+			sector_id, call_policy, call_reject_message,
+			sector_rate_per_minute, sector_burst, recipient_rate_per_minute,
+			recipient_burst, send_jitter_max_ms, typing_before_send,
+			signature_template, signature_placement, auto_mark_read_on_reply,
+			default_region, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE
+			call_policy = VALUES(call_policy),
+			call_reject_message = VALUES(call_reject_message),
+			sector_rate_per_minute = VALUES(sector_rate_per_minute),
+			sector_burst = VALUES(sector_burst),
+			recipient_rate_per_minute = VALUES(recipient_rate_per_minute),
+			recipient_burst = VALUES(recipient_burst),
+			send_jitter_max_ms = VALUES(send_jitter_max_ms),
+			typing_before_send = VALUES(typing_before_send),
+			signature_template = VALUES(signature_template),
+			signature_placement = VALUES(signature_placement),
+			auto_mark_read_on_reply = VALUES(auto_mark_read_on_reply),
+			default_region = VALUES(default_region),
+			updated_at = NOW()`
+
+	_, err := r.db.Exec(query,
+		settings.SectorID,
+		settings.CallPolicy,
+		utils.NullString(settings.CallRejectMessage),
+		settings.SectorRatePerMinute,
+		settings.SectorBurst,
+		settings.RecipientRatePerMinute,
+		settings.RecipientBurst,
+		settings.SendJitterMaxMs,
+		utils.BoolToInt(settings.TypingBeforeSend),
+		utils.NullString(settings.SignatureTemplate),
+		settings.SignaturePlacement,
+		utils.BoolToInt(settings.AutoMarkReadOnReply),
+		settings.DefaultRegion,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving sector settings: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+type MySQLMessageTemplateRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLMessageTemplateRepository(db *sql.DB) *MySQLMessageTemplateRepository {
+	return &MySQLMessageTemplateRepository{db: db}
+}
+
+const templateColumns = `id, sector_id, name, kind, content, media_key, file_name, created_at, updated_at`
+
+func (r *MySQLMessageTemplateRepository) Create(template *models.MessageTemplate) error {
+	now := time.Now().UTC()
+	if template.Kind == "" {
+		template.Kind = models.OutboundKindText
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO message_templates (
+			sector_id, name, kind, content, media_key, file_name, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		template.SectorID,
+		template.Name,
+		template.Kind,
+		template.Content,
+		utils.NullString(template.MediaKey),
+		utils.NullString(template.FileName),
+		now,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating message template: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error getting last insert id: %v", err)
+	}
+
+	template.ID = id
+	template.CreatedAt = now
+	template.UpdatedAt = now
+	return nil
+}
+
+func (r *MySQLMessageTemplateRepository) GetByID(id int64) (*models.MessageTemplate, error) {
+	templates, err := r.fetchTemplates(`SELECT `+templateColumns+` FROM message_templates WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	return templates[0], nil
+}
+
+func (r *MySQLMessageTemplateRepository) ListBySector(sectorID int) ([]*models.MessageTemplate, error) {
+	return r.fetchTemplates(`
+		SELECT `+templateColumns+`
+		FROM message_templates
+		WHERE sector_id = ?
+		ORDER BY name ASC`,
+		sectorID)
+}
+
+func (r *MySQLMessageTemplateRepository) Update(template *models.MessageTemplate) error {
+	template.UpdatedAt = time.Now().UTC()
+
+	_, err := r.db.Exec(`
+		UPDATE message_templates
+		SET name = ?,
+			kind = ?,
+			content = ?,
+			media_key = ?,
+			file_name = ?,
+			updated_at = ?
+		WHERE id = ?`,
+		template.Name,
+		template.Kind,
+		template.Content,
+		utils.NullString(template.MediaKey),
+		utils.NullString(template.FileName),
+		template.UpdatedAt,
+		template.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating message template: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLMessageTemplateRepository) Delete(id int64) error {
+	if _, err := r.db.Exec(`DELETE FROM message_templates WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("error deleting message template: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLMessageTemplateRepository) fetchTemplates(query string, args ...interface{}) ([]*models.MessageTemplate, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying message templates: %v", err)
+	}
+	defer rows.Close()
+
+	var templates []*models.MessageTemplate
+
+	for rows.Next() {
+		template := &models.MessageTemplate{}
+		var content, mediaKey, fileName sql.NullString
+
+		err := rows.Scan(
+			&template.ID,
+			&template.SectorID,
+			&template.Name,
+			&template.Kind,
+			&content,
+			&mediaKey,
+			&fileName,
+			&template.CreatedAt,
+			&template.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning message template: %v", err)
+		}
+
+		template.Content = content.String
+		template.MediaKey = mediaKey.String
+		template.FileName = fileName.String
+
+		templates = append(templates, template)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating message templates: %v", err)
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+)
+
+type MySQLUserSignatureRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLUserSignatureRepository(db *sql.DB) *MySQLUserSignatureRepository {
+	return &MySQLUserSignatureRepository{db: db}
+}
+
+// GetByUser retorna a assinatura própria do atendente, ou nil se ele herda a do setor
+func (r *MySQLUserSignatureRepository) GetByUser(userID int) (*models.UserSignature, error) {
+	signature := &models.UserSignature{UserID: userID}
+	var template, placement sql.NullString
+
+	err := r.db.QueryRow(`
+		SELECT signature_template, signature_placement
+		FROM user_signatures
+		WHERE user_id = ?`, userID).Scan(&template, &placement)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting user signature: %v", err)
+	}
+
+	signature.SignatureTemplate = template.String
+	signature.SignaturePlacement = placement.String
+	return signature, nil
+}
+
+func (r *MySQLUserSignatureRepository) Save(signature *models.UserSignature) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_signatures (
+			user_id, signature_template, signature_placement, created_at, updated_at
+		) VALUES (?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE
+			signature_template = VALUES(signature_template),
+			signature_placement = VALUES(signature_placement),
+			updated_at = NOW()`,
+		signature.UserID,
+		utils.NullString(signature.SignatureTemplate),
+		utils.NullString(signature.SignaturePlacement),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving user signature: %v", err)
+	}
+	return nil
+}
+
+func (r *MySQLUserSignatureRepository) Delete(userID int) error {
+	if _, err := r.db.Exec(`DELETE FROM user_signatures WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("error deleting user signature: %v", err)
+	}
+	return nil
+}
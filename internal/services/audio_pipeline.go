@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+	"whatsapp-bot/internal/utils"
+)
+
+const (
+	audioConvertTimeout = 2 * time.Minute
+
+	// voiceMimeType é o tipo das mensagens de voz, que são sempre enviadas e guardadas em OGG (Opus)
+	voiceMimeType = "audio/ogg; codecs=opus"
+
+	// O PCM usado na análise é mono, 16 bits, a 16 kHz, agrupado em blocos de 10 ms
+	analysisSampleRate   = 16000
+	analysisBlockSamples = analysisSampleRate / 100
+
+	// O WhatsApp desenha a waveform das mensagens de voz com 64 barras de 0 a 100
+	waveformSamples = 64
+	waveformMax     = 100
+
+	// A posição (granule) das páginas OGG com Opus é sempre contada a 48 kHz
+	opusGranuleRate = 48000
+)
+
+// preparedAudio é o áudio pronto para envio como mensagem de voz
+type preparedAudio struct {
+	data     []byte
+	duration float32
+	waveform []byte
+}
+
+// prepareAudio prepara o áudio para envio como mensagem de voz. Áudios que já estão em OGG (Opus) são
+// enviados como estão; os demais são convertidos pelo FFmpeg
+func prepareAudio(audioBytes []byte) (*preparedAudio, error) {
+	if isOggOpus(audioBytes) {
+		utils.LogInfo("Áudio já está em OGG (Opus), enviando sem conversão")
+		return prepareOggOpus(audioBytes), nil
+	}
+	return transcodeAudio(audioBytes)
+}
+
+// audioFallbackFileName gera o nome do áudio enviado como documento, com a extensão do tipo detectado
+func audioFallbackFileName(mimeType string) string {
+	if extensions, err := mime.ExtensionsByType(mimeType); err == nil && len(extensions) > 0 {
+		return "audio" + extensions[0]
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return "audio." + utils.GetExtensionFromMime(mediaType)
+	}
+	return "audio.bin"
+}
+
+// transcodeAudio converte o áudio para OGG (Opus) em uma única execução do FFmpeg, sem arquivos temporários:
+// o áudio entra pela entrada padrão, o OGG sai pela saída padrão e o PCM da análise sai pelo descritor 3
+func transcodeAudio(audioBytes []byte) (*preparedAudio, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), audioConvertTimeout)
+	defer cancel()
+
+	pcmReader, pcmWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar pipe para o PCM: %v", err)
+	}
+	defer pcmReader.Close()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", "pipe:0",
+		// OGG (Opus) enviado ao WhatsApp
+		"-map", "0:a:0",
+		"-c:a", "libopus",
+		"-ar", "48000",
+		"-ac", "1",
+		"-b:a", "128k",
+		"-application", "voip",
+		"-f", "ogg",
+		"pipe:1",
+		// PCM usado para calcular a duração e a waveform
+		"-map", "0:a:0",
+		"-c:a", "pcm_s16le",
+		"-ar", strconv.Itoa(analysisSampleRate),
+		"-ac", "1",
+		"-f", "s16le",
+		"pipe:3",
+	)
+
+	var oggBuffer, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(audioBytes)
+	cmd.Stdout = &oggBuffer
+	cmd.Stderr = &stderr
+	cmd.ExtraFiles = []*os.File{pcmWriter}
+
+	utils.LogInfo("Convertendo áudio para OGG (Opus) usando FFmpeg...")
+	if err := cmd.Start(); err != nil {
+		pcmWriter.Close()
+		return nil, fmt.Errorf("erro ao executar FFmpeg: %v", err)
+	}
+	// O FFmpeg tem a sua cópia do descritor; fechar a nossa para que a leitura termine quando ele sair
+	pcmWriter.Close()
+
+	analyzer := &pcmAnalyzer{}
+	analyzed := make(chan struct{})
+	go func() {
+		defer close(analyzed)
+		io.Copy(analyzer, pcmReader)
+	}()
+
+	err = cmd.Wait()
+	<-analyzed
+	if err != nil {
+		utils.LogError("Erro ao executar FFmpeg: %v\nSaída de erro: %s", err, stderr.String())
+		return nil, fmt.Errorf("erro ao converter áudio: %v\nDetalhes: %s", err, stderr.String())
+	}
+	if oggBuffer.Len() == 0 {
+		return nil, fmt.Errorf("FFmpeg não gerou o áudio convertido")
+	}
+
+	audio := &preparedAudio{
+		data:     oggBuffer.Bytes(),
+		duration: analyzer.duration(),
+		waveform: analyzer.waveform(),
+	}
+	utils.LogInfo("Conversão concluída com sucesso. Tamanho do arquivo: %d bytes, Duração: %.2f segundos", len(audio.data), audio.duration)
+	return audio, nil
+}
+
+// prepareOggOpus lê a duração do próprio arquivo e decodifica o áudio apenas para gerar a waveform.
+// Sem o FFmpeg, o áudio é enviado sem waveform
+func prepareOggOpus(audioBytes []byte) *preparedAudio {
+	audio := &preparedAudio{data: audioBytes}
+
+	duration, durationErr := oggOpusDuration(audioBytes)
+	if durationErr == nil {
+		audio.duration = duration
+	}
+
+	analyzer, err := decodeAudio(audioBytes)
+	if err != nil {
+		utils.LogWarning("Erro ao decodificar áudio, enviando sem waveform: %v", err)
+	} else {
+		audio.waveform = analyzer.waveform()
+		if durationErr != nil {
+			audio.duration = analyzer.duration()
+			durationErr = nil
+		}
+	}
+
+	if durationErr != nil {
+		utils.LogWarning("Erro ao obter duração do áudio: %v", durationErr)
+	}
+	return audio
+}
+
+// decodeAudio decodifica o áudio para PCM pela saída padrão do FFmpeg e o analisa
+func decodeAudio(audioBytes []byte) (*pcmAnalyzer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), audioConvertTimeout)
+	defer cancel()
+
+	analyzer := &pcmAnalyzer{}
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", "pipe:0",
+		"-map", "0:a:0",
+		"-c:a", "pcm_s16le",
+		"-ar", strconv.Itoa(analysisSampleRate),
+		"-ac", "1",
+		"-f", "s16le",
+		"pipe:1",
+	)
+	var stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(audioBytes)
+	cmd.Stdout = analyzer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v\nDetalhes: %s", err, stderr.String())
+	}
+	return analyzer, nil
+}
+
+// pcmAnalyzer recebe o PCM (16 bits, little-endian, mono) conforme é gerado e guarda apenas a amplitude
+// média de cada bloco de 10 ms, para que áudios longos não precisem ficar inteiros em memória
+type pcmAnalyzer struct {
+	samples    int64
+	blockSum   int64
+	blockCount int
+	blocks     []float64
+
+	// Byte de uma amostra dividida entre duas escritas
+	carry    byte
+	hasCarry bool
+}
+
+func (a *pcmAnalyzer) Write(p []byte) (int, error) {
+	data := p
+	if a.hasCarry && len(data) > 0 {
+		a.add(int16(uint16(a.carry) | uint16(data[0])<<8))
+		a.hasCarry = false
+		data = data[1:]
+	}
+	for len(data) >= 2 {
+		a.add(int16(binary.LittleEndian.Uint16(data)))
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		a.carry = data[0]
+		a.hasCarry = true
+	}
+	return len(p), nil
+}
+
+func (a *pcmAnalyzer) add(sample int16) {
+	value := int64(sample)
+	if value < 0 {
+		value = -value
+	}
+	a.blockSum += value
+	a.blockCount++
+	a.samples++
+
+	if a.blockCount == analysisBlockSamples {
+		a.flush()
+	}
+}
+
+func (a *pcmAnalyzer) flush() {
+	if a.blockCount == 0 {
+		return
+	}
+	a.blocks = append(a.blocks, float64(a.blockSum)/float64(a.blockCount))
+	a.blockSum = 0
+	a.blockCount = 0
+}
+
+func (a *pcmAnalyzer) duration() float32 {
+	return float32(a.samples) / analysisSampleRate
+}
+
+// waveform divide o áudio em 64 partes e normaliza a amplitude média de cada uma pela maior delas
+func (a *pcmAnalyzer) waveform() []byte {
+	a.flush()
+	if len(a.blocks) == 0 {
+		return nil
+	}
+
+	averages := make([]float64, waveformSamples)
+	peak := 0.0
+	for i := range averages {
+		start := i * len(a.blocks) / waveformSamples
+		end := max((i+1)*len(a.blocks)/waveformSamples, start+1)
+
+		sum := 0.0
+		for _, block := range a.blocks[start:end] {
+			sum += block
+		}
+		averages[i] = sum / float64(end-start)
+		peak = max(peak, averages[i])
+	}
+
+	waveform := make([]byte, waveformSamples)
+	if peak == 0 {
+		return waveform
+	}
+	for i, average := range averages {
+		waveform[i] = byte(math.Round(average / peak * waveformMax))
+	}
+	return waveform
+}
+
+// isOggOpus verifica se o primeiro pacote do OGG é o cabeçalho do Opus
+func isOggOpus(data []byte) bool {
+	body, ok := oggFirstPageBody(data)
+	return ok && bytes.HasPrefix(body, []byte("OpusHead"))
+}
+
+func oggFirstPageBody(data []byte) ([]byte, bool) {
+	if len(data) < 27 || string(data[:4]) != "OggS" {
+		return nil, false
+	}
+	segments := int(data[26])
+	start := 27 + segments
+	if start > len(data) {
+		return nil, false
+	}
+	length := 0
+	for _, segment := range data[27:start] {
+		length += int(segment)
+	}
+	return data[start:min(start+length, len(data))], true
+}
+
+// oggOpusDuration calcula a duração pela posição da última página do fluxo, descontando as amostras
+// iniciais que o decodificador descarta (pre-skip do cabeçalho OpusHead)
+func oggOpusDuration(data []byte) (float32, error) {
+	head, ok := oggFirstPageBody(data)
+	if !ok || len(head) < 12 || !bytes.HasPrefix(head, []byte("OpusHead")) {
+		return 0, fmt.Errorf("cabeçalho OpusHead não encontrado")
+	}
+	preSkip := int64(binary.LittleEndian.Uint16(head[10:12]))
+	serial := binary.LittleEndian.Uint32(data[14:18])
+
+	granule := int64(-1)
+	for pos := 0; pos+27 <= len(data); {
+		if string(data[pos:pos+4]) != "OggS" {
+			return 0, fmt.Errorf("página OGG inválida na posição %d", pos)
+		}
+		segments := int(data[pos+26])
+		bodyStart := pos + 27 + segments
+		if bodyStart > len(data) {
+			break
+		}
+		length := 0
+		for _, segment := range data[pos+27 : bodyStart] {
+			length += int(segment)
+		}
+
+		// -1 indica uma página em que nenhum pacote termina
+		if binary.LittleEndian.Uint32(data[pos+14:pos+18]) == serial {
+			if position := int64(binary.LittleEndian.Uint64(data[pos+6 : pos+14])); position >= 0 {
+				granule = position
+			}
+		}
+		pos = bodyStart + length
+	}
+
+	if granule < 0 {
+		return 0, fmt.Errorf("posição final do áudio não encontrada")
+	}
+	return float32(max(granule-preSkip, 0)) / opusGranuleRate, nil
+}
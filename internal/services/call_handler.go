@@ -0,0 +1,142 @@
+package services
+
+import (
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleCallOffer aplica a política de chamadas do setor e registra a chamada na conversa
+func (s *WhatsAppService) handleCallOffer(evt interface{}) {
+	call, ok := evt.(*events.CallOffer)
+	if !ok {
+		return
+	}
+
+	sectorID := s.sectorID
+	callerJID := call.From.ToNonAD()
+	if !call.CallCreator.IsEmpty() {
+		callerJID = call.CallCreator.ToNonAD()
+	}
+
+	isVideo := false
+	if call.Data != nil {
+		_, isVideo = call.Data.GetOptionalChildByTag("video")
+	}
+
+	utils.LogInfo("Chamada recebida de %s para setor %d (vídeo: %v)", callerJID.String(), sectorID, isVideo)
+
+	settings, err := s.sectorSettingsRepository.GetBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar configurações do setor %d: %v", sectorID, err)
+		settings = models.DefaultSectorSettings(sectorID)
+	}
+
+	action := models.CallPolicyIgnore
+	if settings.CallPolicy == models.CallPolicyReject || settings.CallPolicy == models.CallPolicyRejectMessage {
+		if s.client == nil {
+			utils.LogError("Cliente não inicializado ao rejeitar chamada do setor %d", sectorID)
+		} else if err := s.client.RejectCall(call.From, call.CallID); err != nil {
+			utils.LogError("Erro ao rejeitar chamada %s: %v", call.CallID, err)
+		} else {
+			action = settings.CallPolicy
+		}
+	}
+
+	calledAt := call.Timestamp
+	if calledAt.IsZero() {
+		calledAt = time.Now()
+	}
+
+	s.saveCallMessage(sectorID, callerJID, call.CallID, isVideo, action, calledAt)
+
+	if action == models.CallPolicyRejectMessage && settings.CallRejectMessage != "" {
+		// Chamadas de contatos endereçados pelo LID são respondidas pelo próprio LID
+		recipient := callerJID.User
+		if callerJID.Server == types.HiddenUserServer {
+			recipient = callerJID.String()
+		}
+		err := s.manager.EnqueueOutbound(&models.OutboundMessage{
+			SectorID:  sectorID,
+			Kind:      models.OutboundKindText,
+			Recipient: recipient,
+			Content:   settings.CallRejectMessage,
+			SentAt:    time.Now().UTC(),
+			Source:    models.OutboundSourceCall,
+		})
+		if err != nil {
+			utils.LogError("Erro ao enfileirar mensagem de chamada rejeitada para %s: %v", callerJID.String(), err)
+		}
+	}
+}
+
+// saveCallMessage grava a chamada como uma mensagem do tipo "call" e notifica os clientes do setor
+func (s *WhatsAppService) saveCallMessage(sectorID int, callerJID types.JID, callID string, isVideo bool, action string, calledAt time.Time) {
+	contact, _, err := s.contactForJID(sectorID, callerJID, types.EmptyJID)
+	if err != nil {
+		utils.LogError("Erro ao buscar contato da chamada: %v", err)
+		return
+	}
+
+	content := "Chamada de voz recebida"
+	if isVideo {
+		content = "Chamada de vídeo recebida"
+	}
+	if action != models.CallPolicyIgnore {
+		content += " (rejeitada automaticamente)"
+	}
+
+	message := &models.Message{
+		Conteudo:          content,
+		Tipo:              "call",
+		IDSetor:           sectorID,
+		ContatoID:         int64(contact.ID),
+		DataEnvio:         calledAt.UTC().Format("02/01/2006 15:04:05"),
+		Enviado:           false,
+		Lido:              false,
+		WhatsAppMessageID: callID,
+		IsOfficial:        false,
+	}
+
+	if err := s.messageRepository.Save(message); err != nil {
+		utils.LogError("Erro ao salvar registro de chamada: %v", err)
+		return
+	}
+
+	// Mover o contato para o topo da lista e marcar como não visualizado
+	go s.contactRepository.UpdateContactOrder(sectorID, contact.ID)
+	if err := s.contactRepository.SetUnviewed(sectorID, contact.Number); err != nil {
+		utils.LogError("Erro ao marcar contato como não visualizado: %v", err)
+	}
+
+	wsnotify.SendMessageEvent(
+		message.ID,
+		int(message.ContatoID),
+		message.IDSetor,
+		message.Conteudo,
+		message.Tipo,
+		nil,
+		nil,
+		nil,
+		calledAt,
+		false,
+		false,
+		models.StatusReceived,
+	)
+
+	wsnotify.SendCallEvent(
+		message.ID,
+		contact.ID,
+		sectorID,
+		callID,
+		contact.Number,
+		isVideo,
+		action,
+		calledAt,
+	)
+}
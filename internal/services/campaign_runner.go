@@ -0,0 +1,370 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+)
+
+const (
+	campaignTickInterval = 3 * time.Second
+	// campaignMaxInFlight limita quantos destinatários de uma campanha ficam na fila de envio ao mesmo tempo,
+	// para que pausar ou cancelar tenha efeito quase imediato
+	campaignMaxInFlight = 5
+
+	campaignReceiptTTL        = 10 * time.Minute
+	campaignReceiptMaxEntries = 5000
+)
+
+type campaignReceipt struct {
+	status    string
+	expiresAt time.Time
+}
+
+// campaignReceiptCache guarda os recibos que não encontraram destinatário de campanha, para aplicá-los quando
+// o resultado do envio for registrado (ex.: o recibo chegou antes de o ID da mensagem ser gravado)
+type campaignReceiptCache struct {
+	mutex   sync.Mutex
+	entries map[string]campaignReceipt
+}
+
+func newCampaignReceiptCache() *campaignReceiptCache {
+	return &campaignReceiptCache{entries: make(map[string]campaignReceipt)}
+}
+
+func campaignReceiptKey(sectorID int, whatsappMessageID string) string {
+	return fmt.Sprintf("%d:%s", sectorID, whatsappMessageID)
+}
+
+func (c *campaignReceiptCache) add(sectorID int, whatsappMessageIDs []string, status string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if len(c.entries)+len(whatsappMessageIDs) > campaignReceiptMaxEntries {
+		for key, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+	}
+
+	for _, id := range whatsappMessageIDs {
+		if len(c.entries) >= campaignReceiptMaxEntries {
+			return
+		}
+		key := campaignReceiptKey(sectorID, id)
+		// A leitura vale mais que a entrega, mesmo que os recibos cheguem fora de ordem
+		if existing, exists := c.entries[key]; exists && existing.status == models.CampaignRecipientRead {
+			continue
+		}
+		c.entries[key] = campaignReceipt{status: status, expiresAt: now.Add(campaignReceiptTTL)}
+	}
+}
+
+func (c *campaignReceiptCache) take(sectorID int, whatsappMessageID string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := campaignReceiptKey(sectorID, whatsappMessageID)
+	entry, exists := c.entries[key]
+	if !exists {
+		return "", false
+	}
+	delete(c.entries, key)
+	if time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.status, true
+}
+
+// CampaignMediaKey gera a chave no armazenamento da mídia de uma campanha, mantida durante toda a campanha
+func CampaignMediaKey(sectorID int, fileName string) string {
+	return fmt.Sprintf("sector_%d/campaigns/%d_%s", sectorID, time.Now().UnixNano(), fileName)
+}
+
+// StartCampaignRunner inicia o processo que despacha os destinatários das campanhas em andamento
+func (cm *ConnectionManager) StartCampaignRunner() {
+	cm.campaignOnce.Do(func() {
+		go cm.runCampaigns()
+	})
+}
+
+func (cm *ConnectionManager) runCampaigns() {
+	utils.LogInfo("Iniciando despachante de campanhas")
+
+	for {
+		cm.dispatchCampaigns()
+
+		select {
+		case <-cm.campaignStop:
+			utils.LogInfo("Despachante de campanhas encerrado")
+			return
+		case <-time.After(campaignTickInterval):
+		}
+	}
+}
+
+func (cm *ConnectionManager) stopCampaignRunner() {
+	select {
+	case <-cm.campaignStop:
+	default:
+		close(cm.campaignStop)
+	}
+}
+
+func (cm *ConnectionManager) dispatchCampaigns() {
+	campaigns, err := cm.campaignRepository.ListRunning()
+	if err != nil {
+		utils.LogError("Erro ao buscar campanhas em andamento: %v", err)
+		return
+	}
+
+	for _, campaign := range campaigns {
+		cm.dispatchCampaign(campaign)
+	}
+}
+
+func (cm *ConnectionManager) dispatchCampaign(campaign *models.Campaign) {
+	counts, err := cm.campaignRepository.CountByStatus(campaign.ID)
+	if err != nil {
+		utils.LogError("Erro ao contar destinatários da campanha %d: %v", campaign.ID, err)
+		return
+	}
+
+	if counts[models.CampaignRecipientQueued] == 0 && counts[models.CampaignRecipientSending] == 0 {
+		if err := cm.campaignRepository.UpdateStatus(campaign.ID, models.CampaignStatusCompleted, models.CampaignStatusRunning); err != nil {
+			utils.LogError("Erro ao concluir campanha %d: %v", campaign.ID, err)
+			return
+		}
+		utils.LogInfo("Campanha %d do setor %d concluída", campaign.ID, campaign.SectorID)
+		campaign.Status = models.CampaignStatusCompleted
+		campaign.Counts = counts
+		cm.publishCampaignStatus(campaign)
+		return
+	}
+
+	// Enquanto o setor estiver desconectado os destinatários aguardam na campanha
+	if cm.activeConnection(campaign.SectorID) == nil {
+		return
+	}
+
+	available := campaignMaxInFlight - counts[models.CampaignRecipientSending]
+	limit := 0
+	key := fmt.Sprintf("campaign:%d", campaign.ID)
+	for limit < available && cm.campaignLimiter.AllowKey(key, campaign.RatePerMinute, 1) {
+		limit++
+	}
+	if limit == 0 {
+		return
+	}
+
+	recipients, err := cm.campaignRepository.ClaimRecipients(campaign.ID, limit)
+	if err != nil {
+		utils.LogError("Erro ao reservar destinatários da campanha %d: %v", campaign.ID, err)
+		return
+	}
+
+	for _, recipient := range recipients {
+		cm.dispatchCampaignRecipient(campaign, recipient)
+	}
+}
+
+// dispatchCampaignRecipient entrega o destinatário à fila de envio do setor
+func (cm *ConnectionManager) dispatchCampaignRecipient(campaign *models.Campaign, recipient *models.CampaignRecipient) {
+	content := campaign.Content
+	if strings.Contains(content, "{{") {
+		var variables map[string]string
+		if recipient.Name != "" {
+			variables = map[string]string{"contact.name": recipient.Name}
+		}
+		content = RenderTemplate(content, cm.TemplateData(campaign.SectorID, recipient.Recipient, campaign.UserID, variables, nil))
+	}
+
+	message := &models.OutboundMessage{
+		SectorID:    campaign.SectorID,
+		Kind:        campaign.Kind,
+		Recipient:   recipient.Recipient,
+		Content:     content,
+		MediaKey:    campaign.MediaKey,
+		FileName:    campaign.FileName,
+		UserID:      campaign.UserID,
+		IsAnonymous: campaign.IsAnonymous,
+		SentAt:      time.Now().UTC(),
+		Source:      models.OutboundSourceCampaign,
+		SourceID:    recipient.ID,
+	}
+
+	if err := cm.EnqueueOutbound(message); err != nil {
+		utils.LogError("Erro ao enfileirar destinatário %d da campanha %d: %v", recipient.ID, campaign.ID, err)
+		recipient.Status = models.CampaignRecipientFailed
+		recipient.LastError = err.Error()
+		if markErr := cm.campaignRepository.MarkRecipientResult(recipient.ID, recipient.Status, "", recipient.LastError); markErr != nil {
+			utils.LogError("Erro ao marcar destinatário %d como falha: %v", recipient.ID, markErr)
+		}
+		cm.publishCampaignRecipient(campaign.SectorID, recipient)
+		return
+	}
+
+	recipient.OutboundMessageID = message.ID
+	if err := cm.campaignRepository.SetRecipientOutbound(recipient.ID, message.ID); err != nil {
+		utils.LogError("Erro ao vincular destinatário %d à fila: %v", recipient.ID, err)
+	}
+	cm.publishCampaignRecipient(campaign.SectorID, recipient)
+}
+
+// recordCampaignMessageID grava no destinatário o ID da mensagem antes do envio, para que os recibos que
+// chegarem enquanto o envio ainda está em andamento encontrem o destinatário
+func (cm *ConnectionManager) recordCampaignMessageID(recipientID int64, whatsappMessageID string) {
+	if err := cm.campaignRepository.SetRecipientMessageID(recipientID, whatsappMessageID); err != nil {
+		utils.LogError("Erro ao gravar ID da mensagem do destinatário de campanha %d: %v", recipientID, err)
+	}
+}
+
+// requeueCampaignRecipient reabre o destinatário de uma mensagem de campanha devolvida manualmente para a fila.
+// Sem isso o destinatário continuaria failed e o novo ID da mensagem, o resultado e os recibos seriam ignorados
+func (cm *ConnectionManager) requeueCampaignRecipient(message *models.OutboundMessage) {
+	if err := cm.campaignRepository.RequeueRecipient(message.SourceID); err != nil {
+		utils.LogError("Erro ao reabrir destinatário de campanha %d: %v", message.SourceID, err)
+		return
+	}
+
+	recipient, err := cm.campaignRepository.GetRecipientByID(message.SourceID)
+	if err != nil {
+		utils.LogError("Erro ao buscar destinatário de campanha %d: %v", message.SourceID, err)
+		return
+	}
+	if recipient != nil {
+		cm.publishCampaignRecipient(message.SectorID, recipient)
+	}
+}
+
+// handleCampaignResult registra o resultado da fila de envio no destinatário da campanha
+func (cm *ConnectionManager) handleCampaignResult(message *models.OutboundMessage) {
+	status := models.CampaignRecipientSent
+	lastError := ""
+	if message.Status == models.OutboundStatusDead {
+		status = models.CampaignRecipientFailed
+		lastError = message.LastError
+	}
+
+	// Só avança destinatários ainda não enviados; um recibo já aplicado não é desfeito
+	if err := cm.campaignRepository.MarkRecipientResult(message.SourceID, status, message.WhatsAppMessageID, lastError); err != nil {
+		utils.LogError("Erro ao atualizar destinatário de campanha %d: %v", message.SourceID, err)
+	}
+
+	if status == models.CampaignRecipientSent {
+		if receiptStatus, ok := cm.campaignReceipts.take(message.SectorID, message.WhatsAppMessageID); ok {
+			if _, err := cm.campaignRepository.UpdateRecipientReceipts(message.SectorID, []string{message.WhatsAppMessageID}, receiptStatus); err != nil {
+				utils.LogError("Erro ao aplicar recibo guardado ao destinatário de campanha %d: %v", message.SourceID, err)
+			}
+		}
+	}
+
+	recipient, err := cm.campaignRepository.GetRecipientByID(message.SourceID)
+	if err != nil {
+		utils.LogError("Erro ao buscar destinatário de campanha %d: %v", message.SourceID, err)
+		return
+	}
+	if recipient != nil {
+		cm.publishCampaignRecipient(message.SectorID, recipient)
+	}
+}
+
+// handleCampaignReceipts aplica recibos de entrega e leitura aos destinatários de campanhas. Os recibos sem
+// destinatário ficam guardados por um tempo, caso o destinatário ainda não tenha o ID da mensagem
+func (cm *ConnectionManager) handleCampaignReceipts(sectorID int, whatsappMessageIDs []string, status string) {
+	recipients, err := cm.campaignRepository.UpdateRecipientReceipts(sectorID, whatsappMessageIDs, status)
+	if err != nil {
+		utils.LogError("Erro ao aplicar recibos às campanhas do setor %d: %v", sectorID, err)
+		return
+	}
+
+	matched := make(map[string]bool, len(recipients))
+	for _, recipient := range recipients {
+		matched[recipient.WhatsAppMessageID] = true
+		cm.publishCampaignRecipient(sectorID, recipient)
+	}
+
+	unmatched := make([]string, 0, len(whatsappMessageIDs)-len(matched))
+	for _, id := range whatsappMessageIDs {
+		if !matched[id] {
+			unmatched = append(unmatched, id)
+		}
+	}
+	cm.campaignReceipts.add(sectorID, unmatched, status)
+}
+
+// StartCampaign inicia uma campanha em rascunho
+func (cm *ConnectionManager) StartCampaign(id int64) (*models.Campaign, error) {
+	return cm.changeCampaignStatus(id, models.CampaignStatusRunning, models.CampaignStatusDraft)
+}
+
+// PauseCampaign interrompe o despacho de novos destinatários; os que já estão na fila seguem normalmente
+func (cm *ConnectionManager) PauseCampaign(id int64) (*models.Campaign, error) {
+	return cm.changeCampaignStatus(id, models.CampaignStatusPaused, models.CampaignStatusRunning)
+}
+
+func (cm *ConnectionManager) ResumeCampaign(id int64) (*models.Campaign, error) {
+	return cm.changeCampaignStatus(id, models.CampaignStatusRunning, models.CampaignStatusPaused)
+}
+
+// CancelCampaign encerra a campanha e cancela os destinatários ainda não despachados
+func (cm *ConnectionManager) CancelCampaign(id int64) (*models.Campaign, error) {
+	campaign, err := cm.changeCampaignStatus(id, models.CampaignStatusCanceled,
+		models.CampaignStatusDraft, models.CampaignStatusRunning, models.CampaignStatusPaused)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cm.campaignRepository.CancelQueuedRecipients(id); err != nil {
+		return nil, err
+	}
+
+	campaign.Counts, _ = cm.campaignRepository.CountByStatus(id)
+	return campaign, nil
+}
+
+func (cm *ConnectionManager) changeCampaignStatus(id int64, status string, fromStatuses ...string) (*models.Campaign, error) {
+	if err := cm.campaignRepository.UpdateStatus(id, status, fromStatuses...); err != nil {
+		return nil, err
+	}
+
+	campaign, err := cm.campaignRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, fmt.Errorf("campanha não encontrada")
+	}
+
+	campaign.Counts, err = cm.campaignRepository.CountByStatus(id)
+	if err != nil {
+		utils.LogError("Erro ao contar destinatários da campanha %d: %v", id, err)
+	}
+
+	utils.LogInfo("Campanha %d do setor %d agora está %s", id, campaign.SectorID, status)
+	cm.publishCampaignStatus(campaign)
+	return campaign, nil
+}
+
+func (cm *ConnectionManager) publishCampaignStatus(campaign *models.Campaign) {
+	wsnotify.SendCampaignStatusEvent(campaign.ID, campaign.SectorID, campaign.Status, campaign.Counts)
+}
+
+func (cm *ConnectionManager) publishCampaignRecipient(sectorID int, recipient *models.CampaignRecipient) {
+	wsnotify.SendCampaignRecipientEvent(
+		recipient.CampaignID,
+		recipient.ID,
+		sectorID,
+		recipient.Recipient,
+		recipient.Status,
+		recipient.WhatsAppMessageID,
+		recipient.LastError,
+	)
+}
@@ -10,6 +10,9 @@ import (
 
 	"encoding/base64"
 	"whatsapp-bot/config"
+	"whatsapp-bot/internal/mediaurl"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
 	"whatsapp-bot/internal/repositories"
 	"whatsapp-bot/internal/utils"
 
@@ -21,17 +24,74 @@ type ConnectionManager struct {
 	mutex             sync.RWMutex
 	connections       map[int]*WhatsAppService
 	config            *config.Config
+	mediaStore        MediaStore
 	messageRepository *repositories.MySQLMessageRepository
 	contactRepository *repositories.MySQLContactRepository
+	userRepository    *repositories.MySQLUserRepository
+
+	outboundRepository *repositories.MySQLOutboundMessageRepository
+	dispatchers        map[int]*outboundDispatcher
+	dispatcherMutex    sync.Mutex
+
+	sectorSettingsRepository *repositories.MySQLSectorSettingsRepository
+	admissionLimiter         *RateLimiter
+	sendLimiter              *RateLimiter
+
+	scheduledRepository *repositories.MySQLScheduledMessageRepository
+	schedulerOnce       sync.Once
+	schedulerStop       chan struct{}
+
+	campaignRepository *repositories.MySQLCampaignRepository
+	campaignLimiter    *RateLimiter
+	campaignOnce       sync.Once
+	campaignStop       chan struct{}
+	campaignReceipts   *campaignReceiptCache
 }
 
-func NewConnectionManager(db *sql.DB, config *config.Config) *ConnectionManager {
+func NewConnectionManager(db *sql.DB, config *config.Config, mediaStore MediaStore) *ConnectionManager {
+	sectorSettingsRepository := repositories.NewMySQLSectorSettingsRepository(db)
+
+	// Números sem código do país são interpretados na região configurada para o setor
+	phone.SetRegionResolver(func(sectorID int) string {
+		settings, err := sectorSettingsRepository.GetBySector(sectorID)
+		if err != nil {
+			utils.LogError("Erro ao buscar região do setor %d: %v", sectorID, err)
+			return phone.DefaultRegion
+		}
+		return settings.DefaultRegion
+	})
+
+	// Mensagens e avatares guardam só a chave da mídia; o frontend recebe links temporários
+	// e os endereços estáveis, assinados, que geram um link novo a cada acesso
+	mediaurl.ConfigureResources(config.Storage.SigningKey, config.Storage.PublicURL, config.Storage.ResourceURLTTL)
+	if mediaStore != nil {
+		mediaurl.Configure(mediaStore.SignedURL, config.Storage.SignedURLTTL,
+			config.S3Config.BucketUrl, config.Storage.PublicURL+"/media")
+	}
+
 	return &ConnectionManager{
 		connections:       make(map[int]*WhatsAppService),
 		db:                db,
 		config:            config,
+		mediaStore:        mediaStore,
 		messageRepository: repositories.NewMySQLMessageRepository(db),
 		contactRepository: repositories.NewMySQLContactRepository(db),
+		userRepository:    repositories.NewMySQLUserRepository(db),
+
+		outboundRepository: repositories.NewMySQLOutboundMessageRepository(db),
+		dispatchers:        make(map[int]*outboundDispatcher),
+
+		sectorSettingsRepository: sectorSettingsRepository,
+		admissionLimiter:         NewRateLimiter(),
+		sendLimiter:              NewRateLimiter(),
+
+		scheduledRepository: repositories.NewMySQLScheduledMessageRepository(db),
+		schedulerStop:       make(chan struct{}),
+
+		campaignRepository: repositories.NewMySQLCampaignRepository(db),
+		campaignLimiter:    NewRateLimiter(),
+		campaignStop:       make(chan struct{}),
+		campaignReceipts:   newCampaignReceiptCache(),
 	}
 }
 
@@ -43,18 +103,8 @@ func (cm *ConnectionManager) GetConnection(sectorID int) (*WhatsAppService, erro
 	}
 	cm.mutex.RUnlock()
 
-	utils.LogDebug("Verificando setor %d", sectorID)
-	var isOfficial bool
-	err := cm.db.QueryRow("SELECT is_official FROM setores WHERE id = ?", sectorID).Scan(&isOfficial)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("setor não encontrado")
-		}
-		return nil, fmt.Errorf("erro ao verificar setor: %v", err)
-	}
-
-	if isOfficial {
-		return nil, fmt.Errorf("este setor está configurado para usar WhatsApp oficial")
+	if err := cm.CheckSector(sectorID); err != nil {
+		return nil, err
 	}
 
 	cm.mutex.Lock()
@@ -68,7 +118,7 @@ func (cm *ConnectionManager) GetConnection(sectorID int) (*WhatsAppService, erro
 	service := NewWhatsAppService(cm.config, cm, cm.messageRepository, cm.contactRepository)
 	service.SetSectorAndManager(sectorID, cm)
 
-	_, err = cm.db.Exec(`
+	_, err := cm.db.Exec(`
 		INSERT INTO whatsapp_connections 
 		(setor_id, status, created_at, updated_at) 
 		VALUES (?, 'disconnected', NOW(), NOW())
@@ -94,6 +144,73 @@ func (cm *ConnectionManager) GetConnection(sectorID int) (*WhatsAppService, erro
 	return service, nil
 }
 
+// CheckSector verifica se o setor existe e usa a conexão não oficial do WhatsApp
+func (cm *ConnectionManager) CheckSector(sectorID int) error {
+	utils.LogDebug("Verificando setor %d", sectorID)
+	var isOfficial bool
+	err := cm.db.QueryRow("SELECT is_official FROM setores WHERE id = ?", sectorID).Scan(&isOfficial)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSectorNotFound
+		}
+		return fmt.Errorf("erro ao verificar setor: %v", err)
+	}
+
+	if isOfficial {
+		return ErrOfficialSector
+	}
+
+	return nil
+}
+
+// AllowSend verifica se o setor ainda tem orçamento para aceitar uma nova mensagem ao destinatário.
+// Quando não tem, retorna o tempo que o cliente deve aguardar antes de tentar novamente
+func (cm *ConnectionManager) AllowSend(sectorID int, recipient string) (bool, time.Duration) {
+	settings, err := cm.sectorSettingsRepository.GetBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar configurações do setor %d: %v", sectorID, err)
+		settings = models.DefaultSectorSettings(sectorID)
+	}
+
+	if number, err := phone.ForSector(sectorID, recipient); err == nil {
+		recipient = number.Digits()
+	}
+
+	return cm.admissionLimiter.Allow(settings, recipient)
+}
+
+// activeConnection retorna a conexão do setor apenas se ela já existir e estiver conectada
+func (cm *ConnectionManager) activeConnection(sectorID int) *WhatsAppService {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	service, exists := cm.connections[sectorID]
+	if !exists || service == nil || !service.IsConnected() {
+		return nil
+	}
+	return service
+}
+
+// AgentSessionOpened marca o setor como disponível no WhatsApp enquanto há um atendente com o painel aberto
+func (cm *ConnectionManager) AgentSessionOpened(sectorID int) {
+	service := cm.activeConnection(sectorID)
+	if service == nil {
+		return
+	}
+	if err := service.markAgentAvailable(); err != nil {
+		utils.LogDebug("Não foi possível definir o setor %d como disponível: %v", sectorID, err)
+	}
+}
+
+// AgentSessionClosed volta o setor a indisponível quando o último painel do setor é fechado
+func (cm *ConnectionManager) AgentSessionClosed(sectorID int) {
+	service := cm.activeConnection(sectorID)
+	if service == nil {
+		return
+	}
+	service.releaseAvailability()
+}
+
 func (cm *ConnectionManager) updateConnectionStatus(sectorID int, status string, errorMsg string) error {
 	_, err := cm.db.Exec(`
 		UPDATE whatsapp_connections 
@@ -281,47 +398,47 @@ func (cm *ConnectionManager) GetQRCode(sectorID int) (string, error) {
 	return "", fmt.Errorf("não foi possível gerar o QR code. por favor, tente novamente")
 }
 
-func (cm *ConnectionManager) SendImage(sectorID int, recipient string, imagePath string, caption string) error {
+func (cm *ConnectionManager) SendImage(sectorID int, recipient string, imagePath string, caption string) (string, error) {
 	service, err := cm.GetConnection(sectorID)
 	if err != nil {
-		return fmt.Errorf("erro ao obter conexão: %v", err)
+		return "", fmt.Errorf("erro ao obter conexão: %v", err)
 	}
 
 	imageBytes, err := os.ReadFile(imagePath)
 	if err != nil {
-		return fmt.Errorf("erro ao ler arquivo de imagem: %v", err)
+		return "", fmt.Errorf("erro ao ler arquivo de imagem: %v", err)
 	}
 
-	return service.SendImage(sectorID, recipient, imageBytes, caption, nil, false, time.Now())
+	return service.SendImage(sectorID, recipient, imageBytes, caption, nil, false, time.Now(), "")
 }
 
-func (cm *ConnectionManager) SendAudio(sectorID int, recipient string, audioPath string) error {
+func (cm *ConnectionManager) SendAudio(sectorID int, recipient string, audioPath string) (string, error) {
 	service, err := cm.GetConnection(sectorID)
 	if err != nil {
-		return fmt.Errorf("erro ao obter conexão: %v", err)
+		return "", fmt.Errorf("erro ao obter conexão: %v", err)
 	}
 
 	audioBytes, err := os.ReadFile(audioPath)
 	if err != nil {
-		return fmt.Errorf("erro ao ler arquivo de áudio: %v", err)
+		return "", fmt.Errorf("erro ao ler arquivo de áudio: %v", err)
 	}
 
-	return service.SendAudio(sectorID, recipient, audioBytes, nil, false, time.Now())
+	return service.SendAudio(sectorID, recipient, audioBytes, nil, false, time.Now(), "")
 }
 
-func (cm *ConnectionManager) SendDocument(sectorID int, recipient string, filePath string) error {
+func (cm *ConnectionManager) SendDocument(sectorID int, recipient string, filePath string) (string, error) {
 	service, err := cm.GetConnection(sectorID)
 	if err != nil {
-		return fmt.Errorf("erro ao obter conexão: %v", err)
+		return "", fmt.Errorf("erro ao obter conexão: %v", err)
 	}
 
 	fileBytes, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("erro ao ler arquivo: %v", err)
+		return "", fmt.Errorf("erro ao ler arquivo: %v", err)
 	}
 
 	fileName := filepath.Base(filePath)
-	return service.SendDocument(sectorID, recipient, fileBytes, fileName, nil, false, time.Now())
+	return service.SendDocument(sectorID, recipient, fileBytes, fileName, "", nil, false, time.Now(), "")
 }
 
 func (cm *ConnectionManager) SendTyping(sectorID int, recipient string, duration int) error {
@@ -333,6 +450,16 @@ func (cm *ConnectionManager) SendTyping(sectorID int, recipient string, duration
 	return service.SendTyping(recipient, duration)
 }
 
+// SetChatPresence altera a presença do setor (digitando, gravando ou pausado) em uma conversa
+func (cm *ConnectionManager) SetChatPresence(sectorID int, recipient string, state string, duration int) error {
+	service, err := cm.GetConnection(sectorID)
+	if err != nil {
+		return fmt.Errorf("erro ao obter conexão: %v", err)
+	}
+
+	return service.SetChatPresence(recipient, state, duration)
+}
+
 func (cm *ConnectionManager) SaveWhatsAppSession(sectorID int, sessionData []byte) error {
 	utils.LogDebug("Salvando referência de sessão do WhatsApp no banco para setor %d", sectorID)
 
@@ -509,6 +636,10 @@ func (cm *ConnectionManager) GetWhatsAppSession(sectorID int) ([]byte, error) {
 func (cm *ConnectionManager) CloseAllConnections() error {
 	utils.LogInfo("Iniciando limpeza total do sistema e fechamento de todas as conexões")
 
+	cm.stopOutboundDispatchers()
+	cm.stopScheduler()
+	cm.stopCampaignRunner()
+
 	done := make(chan bool)
 	go func() {
 		cm.mutex.Lock()
@@ -594,6 +725,11 @@ func (cm *ConnectionManager) CloseConnection(sectorID int) error {
 	return nil
 }
 
+// MediaStore retorna o armazenamento de mídia compartilhado pelos setores
+func (cm *ConnectionManager) MediaStore() MediaStore {
+	return cm.mediaStore
+}
+
 func (cm *ConnectionManager) GetDB() *sql.DB {
 	return cm.db
 }
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"sync"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	// Intervalo mínimo entre eventos de presença de um mesmo contato enviados ao frontend
+	contactPresenceInterval = 2 * time.Second
+
+	// Conversas sem mensagens por presenceSubscriptionIdle deixam de ter a presença repassada
+	presenceSubscriptionIdle       = 30 * time.Minute
+	presenceSubscriptionMaxEntries = 500
+
+	presenceLookupTTL        = 10 * time.Minute
+	presenceLookupMaxEntries = 5000
+)
+
+// Estados de presença do contato repassados ao frontend
+const (
+	ContactPresenceAvailable   = "available"
+	ContactPresenceUnavailable = "unavailable"
+	ContactPresenceComposing   = "composing"
+	ContactPresenceRecording   = "recording"
+	ContactPresencePaused      = "paused"
+)
+
+// contactPresenceUpdate guarda o último estado de presença conhecido de um contato
+type contactPresenceUpdate struct {
+	contactID int
+	number    string
+	state     string
+	lastSeen  *time.Time
+}
+
+// presenceThrottle limita os eventos de presença por contato, sempre entregando o estado mais recente
+type presenceThrottle struct {
+	mutex    sync.Mutex
+	sectorID int
+	sent     map[int]time.Time
+	lastSent map[int]contactPresenceUpdate
+	pending  map[int]contactPresenceUpdate
+	timers   map[int]*time.Timer
+}
+
+func newPresenceThrottle(sectorID int) *presenceThrottle {
+	return &presenceThrottle{
+		sectorID: sectorID,
+		sent:     make(map[int]time.Time),
+		lastSent: make(map[int]contactPresenceUpdate),
+		pending:  make(map[int]contactPresenceUpdate),
+		timers:   make(map[int]*time.Timer),
+	}
+}
+
+// push envia a atualização imediatamente ou a guarda até o fim do intervalo do contato
+func (t *presenceThrottle) push(update contactPresenceUpdate) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if last, exists := t.lastSent[update.contactID]; exists && last.state == update.state && update.lastSeen == nil {
+		delete(t.pending, update.contactID)
+		return
+	}
+
+	wait := contactPresenceInterval - time.Since(t.sent[update.contactID])
+	if wait <= 0 {
+		t.emit(update)
+		return
+	}
+
+	t.pending[update.contactID] = update
+	if _, scheduled := t.timers[update.contactID]; !scheduled {
+		contactID := update.contactID
+		t.timers[contactID] = time.AfterFunc(wait, func() { t.flush(contactID) })
+	}
+}
+
+// flush envia a última atualização pendente do contato
+func (t *presenceThrottle) flush(contactID int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.timers, contactID)
+	if update, exists := t.pending[contactID]; exists {
+		delete(t.pending, contactID)
+		t.emit(update)
+	}
+}
+
+// emit deve ser chamado com o mutex travado
+func (t *presenceThrottle) emit(update contactPresenceUpdate) {
+	t.sent[update.contactID] = time.Now()
+	t.lastSent[update.contactID] = update
+	wsnotify.SendContactPresenceEvent(t.sectorID, update.contactID, update.number, update.state, update.lastSeen)
+}
+
+// presenceSubscription é uma conversa ativa cuja presença é acompanhada
+type presenceSubscription struct {
+	contactID  int
+	lastActive time.Time
+	subscribed bool // Assinatura já enviada ao WhatsApp nesta conexão
+}
+
+// presenceLookup guarda o contato de um JID buscado no banco, inclusive quando ele não existe no setor
+type presenceLookup struct {
+	contactID int
+	expiresAt time.Time
+}
+
+// markAvailable marca o dispositivo como disponível, uma única vez por conexão, e envia as assinaturas
+// de presença pendentes, que o WhatsApp só atende enquanto o dispositivo está disponível
+func (s *WhatsAppService) markAvailable() error {
+	s.availabilityMutex.Lock()
+	defer s.availabilityMutex.Unlock()
+	return s.sendAvailable()
+}
+
+// markAgentAvailable marca o dispositivo como disponível apenas se ainda houver um painel do setor aberto.
+// A verificação é feita com as mudanças de disponibilidade serializadas para que um painel fechado logo após
+// ser aberto não deixe o dispositivo disponível sem nenhum atendente
+func (s *WhatsAppService) markAgentAvailable() error {
+	s.availabilityMutex.Lock()
+	defer s.availabilityMutex.Unlock()
+	if !wsnotify.Manager.HasSectorClients(s.sectorID) {
+		return nil
+	}
+	return s.sendAvailable()
+}
+
+// sendAvailable deve ser chamado com o availabilityMutex travado
+func (s *WhatsAppService) sendAvailable() error {
+	if s.client == nil {
+		return ErrNotConnected
+	}
+
+	s.presenceMutex.Lock()
+	available := s.available
+	s.presenceMutex.Unlock()
+	if available {
+		return nil
+	}
+
+	if err := s.client.SendPresence(types.PresenceAvailable); err != nil {
+		return err
+	}
+
+	s.presenceMutex.Lock()
+	s.available = true
+	pending := make([]types.JID, 0, len(s.presenceSubscriptions))
+	for key, subscription := range s.presenceSubscriptions {
+		if !subscription.subscribed {
+			if jid, err := types.ParseJID(key); err == nil {
+				pending = append(pending, jid)
+			}
+		}
+	}
+	s.presenceMutex.Unlock()
+
+	for _, jid := range pending {
+		s.sendPresenceSubscription(jid)
+	}
+	return nil
+}
+
+// releaseAvailability volta o dispositivo a indisponível quando nenhum atendente está com o painel do setor
+// aberto, para que o celular do titular volte a receber as notificações
+func (s *WhatsAppService) releaseAvailability() {
+	s.availabilityMutex.Lock()
+	defer s.availabilityMutex.Unlock()
+	if s.client == nil || wsnotify.Manager.HasSectorClients(s.sectorID) {
+		return
+	}
+
+	s.presenceMutex.Lock()
+	available := s.available
+	s.available = false
+	s.presenceMutex.Unlock()
+	if !available {
+		return
+	}
+
+	if err := s.client.SendPresence(types.PresenceUnavailable); err != nil {
+		utils.LogDebug("Não foi possível definir o setor %d como indisponível: %v", s.sectorID, err)
+	}
+}
+
+// subscribeContactPresence marca a conversa como ativa e assina a presença do contato, uma vez por conexão.
+// Sem um atendente com o painel aberto a assinatura fica pendente até o dispositivo ficar disponível
+func (s *WhatsAppService) subscribeContactPresence(jid types.JID, contactID int) {
+	if s.client == nil || (jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer) {
+		return
+	}
+
+	key := jid.String()
+	now := time.Now()
+
+	s.presenceMutex.Lock()
+	if subscription, exists := s.presenceSubscriptions[key]; exists {
+		subscription.lastActive = now
+		if contactID != 0 {
+			subscription.contactID = contactID
+		}
+		s.presenceMutex.Unlock()
+		return
+	}
+	s.expirePresenceSubscriptions(now)
+	s.presenceSubscriptions[key] = &presenceSubscription{contactID: contactID, lastActive: now}
+	available := s.available
+	s.presenceMutex.Unlock()
+
+	if available {
+		s.sendPresenceSubscription(jid)
+	}
+}
+
+// sendPresenceSubscription envia a assinatura ao WhatsApp e a marca como feita nesta conexão
+func (s *WhatsAppService) sendPresenceSubscription(jid types.JID) {
+	if err := s.client.SubscribePresence(jid); err != nil {
+		utils.LogDebug("Não foi possível assinar a presença de %s: %v", jid.User, err)
+		return
+	}
+
+	s.presenceMutex.Lock()
+	if subscription, exists := s.presenceSubscriptions[jid.String()]; exists {
+		subscription.subscribed = true
+	}
+	s.presenceMutex.Unlock()
+}
+
+// expirePresenceSubscriptions descarta as conversas paradas e, com o limite atingido, a menos recente.
+// Deve ser chamado com o mutex travado
+func (s *WhatsAppService) expirePresenceSubscriptions(now time.Time) {
+	oldestKey := ""
+	var oldest time.Time
+	for key, subscription := range s.presenceSubscriptions {
+		if now.Sub(subscription.lastActive) > presenceSubscriptionIdle {
+			delete(s.presenceSubscriptions, key)
+			continue
+		}
+		if oldestKey == "" || subscription.lastActive.Before(oldest) {
+			oldestKey, oldest = key, subscription.lastActive
+		}
+	}
+	if len(s.presenceSubscriptions) >= presenceSubscriptionMaxEntries && oldestKey != "" {
+		delete(s.presenceSubscriptions, oldestKey)
+	}
+}
+
+// activePresenceSubscription retorna o contato da conversa ativa do JID, ou 0 se ela não existe ou está parada
+func (s *WhatsAppService) activePresenceSubscription(jid types.JID) (int, bool) {
+	s.presenceMutex.Lock()
+	defer s.presenceMutex.Unlock()
+
+	subscription, exists := s.presenceSubscriptions[jid.String()]
+	if !exists {
+		return 0, false
+	}
+	if time.Since(subscription.lastActive) > presenceSubscriptionIdle {
+		delete(s.presenceSubscriptions, jid.String())
+		return 0, false
+	}
+	return subscription.contactID, true
+}
+
+// resetPresenceSubscriptions marca as assinaturas como pendentes, já que elas e a disponibilidade do
+// dispositivo não sobrevivem a uma reconexão
+func (s *WhatsAppService) resetPresenceSubscriptions() {
+	s.presenceMutex.Lock()
+	s.available = false
+	for _, subscription := range s.presenceSubscriptions {
+		subscription.subscribed = false
+	}
+	s.presenceMutex.Unlock()
+
+	// Com um atendente já com o painel aberto, o dispositivo volta a ficar disponível
+	if wsnotify.Manager.HasSectorClients(s.sectorID) {
+		go func() {
+			if err := s.markAgentAvailable(); err != nil {
+				utils.LogDebug("Não foi possível definir o setor %d como disponível: %v", s.sectorID, err)
+			}
+		}()
+	}
+}
+
+// presenceContactID retorna o contato do setor associado ao JID. A busca no banco, inclusive sem resultado,
+// fica guardada por presenceLookupTTL para que eventos de JIDs desconhecidos não consultem o banco a cada vez
+func (s *WhatsAppService) presenceContactID(jid types.JID) int {
+	if contactID, _ := s.activePresenceSubscription(jid); contactID != 0 {
+		return contactID
+	}
+
+	key := jid.String()
+	now := time.Now()
+
+	s.presenceMutex.Lock()
+	lookup, exists := s.presenceLookups[key]
+	s.presenceMutex.Unlock()
+	if exists && now.Before(lookup.expiresAt) {
+		return lookup.contactID
+	}
+
+	var contact *models.Contact
+	var err error
+	if jid.Server == types.HiddenUserServer {
+		contact, err = s.contactRepository.GetByLID(s.sectorID, key)
+	} else {
+		contact, err = s.contactRepository.GetByNumber(s.sectorID, key)
+	}
+	if err != nil {
+		utils.LogDebug("Erro ao buscar contato da presença de %s: %v", jid.User, err)
+		return 0
+	}
+
+	contactID := 0
+	if contact != nil {
+		contactID = contact.ID
+	}
+
+	s.presenceMutex.Lock()
+	if len(s.presenceLookups) >= presenceLookupMaxEntries {
+		for lookupKey, entry := range s.presenceLookups {
+			if now.After(entry.expiresAt) {
+				delete(s.presenceLookups, lookupKey)
+			}
+		}
+		if len(s.presenceLookups) >= presenceLookupMaxEntries {
+			s.presenceLookups = make(map[string]presenceLookup)
+		}
+	}
+	s.presenceLookups[key] = presenceLookup{contactID: contactID, expiresAt: now.Add(presenceLookupTTL)}
+	if subscription, exists := s.presenceSubscriptions[key]; exists && contactID != 0 {
+		subscription.contactID = contactID
+	}
+	s.presenceMutex.Unlock()
+	return contactID
+}
+
+// handlePresence repassa ao frontend quando o contato fica online ou offline
+func (s *WhatsAppService) handlePresence(evt interface{}) {
+	presence, ok := evt.(*events.Presence)
+	if !ok {
+		return
+	}
+
+	// Online e offline só são repassados enquanto a conversa está ativa
+	jid := presence.From.ToNonAD()
+	if _, active := s.activePresenceSubscription(jid); !active {
+		return
+	}
+	contactID := s.presenceContactID(jid)
+	if contactID == 0 {
+		return
+	}
+
+	update := contactPresenceUpdate{
+		contactID: contactID,
+		number:    jid.User,
+		state:     ContactPresenceAvailable,
+	}
+	if presence.Unavailable {
+		update.state = ContactPresenceUnavailable
+		if !presence.LastSeen.IsZero() {
+			lastSeen := presence.LastSeen
+			update.lastSeen = &lastSeen
+		}
+	}
+
+	s.contactPresence.push(update)
+}
+
+// handleChatPresence repassa ao frontend quando o contato está digitando ou gravando
+func (s *WhatsAppService) handleChatPresence(evt interface{}) {
+	presence, ok := evt.(*events.ChatPresence)
+	if !ok || presence.IsGroup || presence.IsFromMe {
+		return
+	}
+
+	jid := presence.Sender.ToNonAD()
+	contactID := s.presenceContactID(jid)
+	if contactID == 0 {
+		return
+	}
+
+	state := ContactPresencePaused
+	if presence.State == types.ChatPresenceComposing {
+		state = ContactPresenceComposing
+		if presence.Media == types.ChatPresenceMediaAudio {
+			state = ContactPresenceRecording
+		}
+	}
+
+	s.contactPresence.push(contactPresenceUpdate{
+		contactID: contactID,
+		number:    jid.User,
+		state:     state,
+	})
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"whatsapp-bot/internal/imageproc"
+	"whatsapp-bot/internal/utils"
+)
+
+const (
+	documentRenderTimeout  = 30 * time.Second
+	documentConvertTimeout = 90 * time.Second
+	// documentThumbnailDimension é o maior lado do JPEGThumbnail do documento, exibido acima do nome do arquivo
+	documentThumbnailDimension     = 240
+	documentPreviewCacheMaxEntries = 200
+	// documentPreviewFailureTTL é por quanto tempo uma renderização que falhou não é tentada de novo
+	documentPreviewFailureTTL = time.Hour
+)
+
+// officeExtensions são os formatos convertidos para PDF pelo LibreOffice antes de gerar a miniatura
+var officeExtensions = map[string]bool{
+	".doc": true, ".docx": true, ".odt": true, ".rtf": true,
+	".xls": true, ".xlsx": true, ".ods": true,
+	".ppt": true, ".pptx": true, ".odp": true,
+}
+
+// documentPreview é a primeira página renderizada de um documento
+type documentPreview struct {
+	thumbnail []byte
+	width     int
+	height    int
+	preview   []byte
+	pageCount int
+}
+
+// documentPreviewEntry é a prévia renderizada ou, quando preview é nil, uma renderização que falhou
+type documentPreviewEntry struct {
+	preview   *documentPreview
+	expiresAt time.Time
+}
+
+// documentPreviewCache guarda as prévias já renderizadas pelo hash do arquivo, para que o mesmo
+// documento enviado a vários contatos não seja renderizado a cada envio. As falhas também são
+// guardadas, por um tempo, para que um arquivo que o LibreOffice não converte não o execute a cada envio
+type documentPreviewCache struct {
+	mutex   sync.Mutex
+	entries map[string]documentPreviewEntry
+}
+
+func newDocumentPreviewCache() *documentPreviewCache {
+	return &documentPreviewCache{entries: make(map[string]documentPreviewEntry)}
+}
+
+func (c *documentPreviewCache) get(sum []byte) (*documentPreview, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := hex.EncodeToString(sum)
+	entry, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.preview, true
+}
+
+func (c *documentPreviewCache) set(sum []byte, preview *documentPreview) {
+	c.store(sum, documentPreviewEntry{preview: preview})
+}
+
+// setFailed registra que o documento não pôde ser renderizado
+func (c *documentPreviewCache) setFailed(sum []byte) {
+	c.store(sum, documentPreviewEntry{expiresAt: time.Now().Add(documentPreviewFailureTTL)})
+}
+
+func (c *documentPreviewCache) store(sum []byte, entry documentPreviewEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// O conteúdo não muda para o mesmo hash; com o cache cheio, qualquer entrada pode sair
+	if len(c.entries) >= documentPreviewCacheMaxEntries {
+		for key := range c.entries {
+			delete(c.entries, key)
+			break
+		}
+	}
+	c.entries[hex.EncodeToString(sum)] = entry
+}
+
+// documentPreviewFor retorna a prévia do documento, renderizando-a apenas na primeira vez que o conteúdo é enviado.
+// Retorna nil para formatos sem prévia ou quando a renderização falha (o documento é enviado sem miniatura);
+// a falha fica no cache por documentPreviewFailureTTL
+func (s *WhatsAppService) documentPreviewFor(fileBytes []byte, sum []byte, fileName string, mimeType string) *documentPreview {
+	if preview, ok := s.documentPreviews.get(sum); ok {
+		return preview
+	}
+
+	preview, err := renderDocumentPreview(fileBytes, fileName, mimeType)
+	if err != nil {
+		utils.LogWarning("Erro ao gerar miniatura do documento %s: %v", fileName, err)
+		s.documentPreviews.setFailed(sum)
+		return nil
+	}
+	if preview != nil {
+		s.documentPreviews.set(sum, preview)
+	}
+	return preview
+}
+
+// renderDocumentPreview renderiza a primeira página e conta as páginas de PDFs e documentos do Office, usando
+// o poppler (pdftoppm/pdfinfo) e o LibreOffice em subprocessos, como já é feito com o ffmpeg nos áudios
+func renderDocumentPreview(fileBytes []byte, fileName string, mimeType string) (*documentPreview, error) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	isPDF := mimeType == "application/pdf"
+	if !isPDF && !officeExtensions[ext] {
+		return nil, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "whatsapp_document_*")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório temporário: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pdfPath := filepath.Join(tempDir, "document.pdf")
+	if isPDF {
+		if err := os.WriteFile(pdfPath, fileBytes, 0600); err != nil {
+			return nil, fmt.Errorf("erro ao salvar documento temporário: %v", err)
+		}
+	} else {
+		inputPath := filepath.Join(tempDir, "document"+ext)
+		if err := os.WriteFile(inputPath, fileBytes, 0600); err != nil {
+			return nil, fmt.Errorf("erro ao salvar documento temporário: %v", err)
+		}
+		if err := convertToPDF(tempDir, inputPath); err != nil {
+			return nil, err
+		}
+	}
+
+	page, err := renderFirstPage(tempDir, pdfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnail, width, height, err := imageproc.Thumbnail(page, documentThumbnailDimension)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar miniatura: %v", err)
+	}
+
+	pageCount, err := pdfPageCount(pdfPath)
+	if err != nil {
+		utils.LogWarning("Erro ao contar páginas do documento %s: %v", fileName, err)
+	}
+
+	return &documentPreview{
+		thumbnail: thumbnail,
+		width:     width,
+		height:    height,
+		preview:   page,
+		pageCount: pageCount,
+	}, nil
+}
+
+// convertToPDF converte o documento com o LibreOffice. Cada conversão usa um perfil próprio no diretório
+// temporário, já que o LibreOffice não roda duas instâncias com o mesmo perfil
+func convertToPDF(tempDir string, inputPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), documentConvertTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "soffice",
+		"--headless",
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(tempDir, "profile")),
+		"--convert-to", "pdf",
+		"--outdir", tempDir,
+		inputPath,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("erro ao converter documento para PDF: %v\nDetalhes: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// renderFirstPage renderiza a primeira página em JPEG no tamanho da prévia do painel
+func renderFirstPage(tempDir string, pdfPath string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), documentRenderTimeout)
+	defer cancel()
+
+	outputPrefix := filepath.Join(tempDir, "page")
+	cmd := exec.CommandContext(ctx, "pdftoppm",
+		"-f", "1",
+		"-l", "1",
+		"-singlefile",
+		"-jpeg",
+		"-scale-to", strconv.Itoa(imageproc.PreviewDimension),
+		pdfPath,
+		outputPrefix,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("erro ao renderizar primeira página: %v\nDetalhes: %s", err, stderr.String())
+	}
+
+	page, err := os.ReadFile(outputPrefix + ".jpg")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler primeira página renderizada: %v", err)
+	}
+	return page, nil
+}
+
+// pdfPageCount lê o número de páginas informado pelo pdfinfo
+func pdfPageCount(pdfPath string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), documentRenderTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "pdfinfo", pdfPath).Output()
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "Pages:"); ok {
+			return strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	return 0, fmt.Errorf("número de páginas não encontrado na saída do pdfinfo")
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/libsignal/signalerror"
+	"go.mau.fi/whatsmeow"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Códigos estáveis dos erros de serviço, expostos no campo code das respostas da API
+const (
+	CodeNotConnected     = "not_connected"
+	CodeInvalidRequest   = "invalid_request"
+	CodeInvalidRecipient = "invalid_recipient"
+	CodeNotOnWhatsApp    = "not_on_whatsapp"
+	CodeRateLimited      = "rate_limited"
+	CodeMediaTooLarge    = "media_too_large"
+	CodeSectorNotFound   = "sector_not_found"
+	CodeOfficialSector   = "official_sector"
+	CodeUpstream         = "upstream_error"
+)
+
+// Error é um erro de serviço com um código estável e, opcionalmente, a causa original
+type Error struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is compara pelo código, permitindo errors.Is(err, ErrNotConnected) com a causa anexada
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Retryable indica se vale a pena tentar o envio novamente mais tarde
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case CodeInvalidRequest, CodeInvalidRecipient, CodeNotOnWhatsApp, CodeMediaTooLarge, CodeSectorNotFound, CodeOfficialSector:
+		return false
+	}
+	return true
+}
+
+var (
+	ErrNotConnected     = &Error{Code: CodeNotConnected, Message: "whatsapp não está conectado"}
+	ErrInvalidRequest   = &Error{Code: CodeInvalidRequest, Message: "requisição inválida"}
+	ErrInvalidRecipient = &Error{Code: CodeInvalidRecipient, Message: "número de telefone inválido"}
+	ErrNotOnWhatsApp    = &Error{Code: CodeNotOnWhatsApp, Message: "número não possui WhatsApp"}
+	ErrRateLimited      = &Error{Code: CodeRateLimited, Message: "limite de envios excedido"}
+	ErrMediaTooLarge    = &Error{Code: CodeMediaTooLarge, Message: "arquivo maior que o limite permitido pelo WhatsApp"}
+	ErrSectorNotFound   = &Error{Code: CodeSectorNotFound, Message: "setor não encontrado"}
+	ErrOfficialSector   = &Error{Code: CodeOfficialSector, Message: "este setor está configurado para usar WhatsApp oficial"}
+	ErrUpstream         = &Error{Code: CodeUpstream, Message: "erro de comunicação com o WhatsApp"}
+)
+
+// newError anexa a causa a um dos erros de serviço acima
+func newError(kind *Error, cause error) error {
+	return &Error{Code: kind.Code, Message: kind.Message, Err: cause}
+}
+
+// RecipientRateLimitedError indica que o destinatário atingiu o seu limite de envios; a mensagem
+// pode ser enviada novamente após RetryAfter
+type RecipientRateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RecipientRateLimitedError) Error() string {
+	return ErrRateLimited.Message + " para o destinatário"
+}
+
+func (e *RecipientRateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// IsRetryable indica se um erro de envio é transitório
+func IsRetryable(err error) bool {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Retryable()
+	}
+	return true
+}
+
+// classifySendError converte os erros do whatsmeow em erros de serviço, preservando os já classificados
+func classifySendError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, whatsmeow.ErrNotConnected), errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return newError(ErrNotConnected, err)
+	}
+	return newError(ErrUpstream, err)
+}
+
+// isDatabaseLocked detecta bloqueios do banco SQLite local da sessão do whatsmeow
+func isDatabaseLocked(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// isUntrustedIdentity detecta a troca de chaves do contato que exige reconectar a sessão
+func isUntrustedIdentity(err error) bool {
+	return errors.Is(err, signalerror.ErrUntrustedIdentity)
+}
+
+// serverErrorCode extrai o código numérico retornado pelo servidor do WhatsApp no envio.
+// O whatsmeow anexa o código logo após o erro sentinela: "server returned error 479"
+func serverErrorCode(err error) (int, bool) {
+	if !errors.Is(err, whatsmeow.ErrServerReturnedError) {
+		return 0, false
+	}
+
+	text := err.Error()
+	sentinel := whatsmeow.ErrServerReturnedError.Error()
+	index := strings.LastIndex(text, sentinel)
+	if index < 0 {
+		return 0, false
+	}
+
+	var code int
+	if _, err := fmt.Sscanf(text[index+len(sentinel):], " %d", &code); err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// isServerError detecta o código de erro retornado pelo servidor do WhatsApp no envio
+func isServerError(err error, code int) bool {
+	returned, ok := serverErrorCode(err)
+	return ok && returned == code
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mau.fi/libsignal/signalerror"
+	"go.mau.fi/whatsmeow"
+)
+
+func TestServerErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+		ok   bool
+	}{
+		{name: "erro do servidor", err: fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 479), code: 479, ok: true},
+		{name: "erro do servidor encapsulado", err: fmt.Errorf("erro ao enviar: %w", fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 463)), code: 463, ok: true},
+		{name: "sem código", err: whatsmeow.ErrServerReturnedError},
+		{name: "outro erro terminado em número", err: errors.New("timeout 479")},
+		{name: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := serverErrorCode(tt.err)
+			if code != tt.code || ok != tt.ok {
+				t.Fatalf("serverErrorCode(%v) = %d, %v, esperado %d, %v", tt.err, code, ok, tt.code, tt.ok)
+			}
+		})
+	}
+
+	if isServerError(fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 4790), 479) {
+		t.Fatal("isServerError não deve aceitar outro código com o mesmo prefixo")
+	}
+}
+
+func TestIsUntrustedIdentity(t *testing.T) {
+	if !isUntrustedIdentity(fmt.Errorf("falha ao criptografar: %w", signalerror.ErrUntrustedIdentity)) {
+		t.Fatal("isUntrustedIdentity deve reconhecer o erro encapsulado do libsignal")
+	}
+	if isUntrustedIdentity(errors.New("untrusted identity")) {
+		t.Fatal("isUntrustedIdentity não deve comparar o texto do erro")
+	}
+}
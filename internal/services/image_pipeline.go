@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"whatsapp-bot/internal/imageproc"
+	"whatsapp-bot/internal/utils"
+)
+
+// prepareImage remove os metadados, reduz a imagem e gera a miniatura. Se o processamento falhar, a imagem
+// é enviada sem miniatura, sem os metadados quando o formato é JPEG ou PNG
+func prepareImage(imageBytes []byte) *imageproc.Result {
+	result, err := imageproc.Process(imageBytes)
+	if errors.Is(err, imageproc.ErrUnsupported) {
+		utils.LogInfo("Formato %s não processado, enviando a imagem original", result.MimeType)
+	} else if err != nil {
+		utils.LogWarning("Erro ao processar imagem, enviando sem miniatura: %v", err)
+	}
+	return result
+}
+
+// storeMediaPreview grava a prévia ao lado do arquivo, sem regravar quando ela já existe
+func (s *WhatsAppService) storeMediaPreview(key string, preview []byte) {
+	if len(preview) == 0 {
+		return
+	}
+	if err := s.putMediaIfMissing(MediaPreviewKey(key), preview, "image/jpeg"); err != nil {
+		utils.LogError("Erro ao gravar prévia de %s: %v", key, err)
+	}
+}
+
+// storeReceivedImagePreview gera a prévia de uma imagem recebida, que é guardada sem alterações
+func (s *WhatsAppService) storeReceivedImagePreview(key string, data []byte) {
+	preview, err := imageproc.Preview(data)
+	if err != nil {
+		utils.LogDebug("Prévia não gerada para %s: %v", key, err)
+		return
+	}
+	s.storeMediaPreview(key, preview)
+}
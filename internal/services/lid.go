@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
+	"whatsapp-bot/internal/utils"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// resolveContactJIDs separa o JID do telefone e o LID de um usuário. O WhatsApp pode endereçar
+// o contato por qualquer um dos dois; o outro vem no endereço alternativo do evento ou do
+// mapeamento LID↔telefone guardado na sessão do whatsmeow. Qualquer um deles pode vir vazio.
+func (s *WhatsAppService) resolveContactJIDs(jid types.JID, alt types.JID) (pn types.JID, lid types.JID) {
+	for _, candidate := range []types.JID{jid.ToNonAD(), alt.ToNonAD()} {
+		switch candidate.Server {
+		case types.DefaultUserServer:
+			if pn.IsEmpty() {
+				pn = candidate
+			}
+		case types.HiddenUserServer:
+			if lid.IsEmpty() {
+				lid = candidate
+			}
+		}
+	}
+
+	if s.client == nil || s.client.Store == nil || s.client.Store.LIDs == nil {
+		return pn, lid
+	}
+
+	ctx := context.Background()
+	if pn.IsEmpty() && !lid.IsEmpty() {
+		found, err := s.client.Store.LIDs.GetPNForLID(ctx, lid)
+		if err != nil {
+			utils.LogError("Erro ao buscar o telefone do LID %s: %v", lid.String(), err)
+		} else {
+			pn = found
+		}
+	} else if lid.IsEmpty() && !pn.IsEmpty() {
+		found, err := s.client.Store.LIDs.GetLIDForPN(ctx, pn)
+		if err != nil {
+			utils.LogError("Erro ao buscar o LID de %s: %v", pn.String(), err)
+		} else {
+			lid = found
+		}
+	}
+
+	return pn, lid
+}
+
+// contactForJID encontra ou cria o contato do setor para um usuário endereçado por telefone ou LID,
+// gravando os dois identificadores. Sem o telefone conhecido, o contato é criado com o LID no lugar
+// do número; quando o telefone aparece depois, esse contato é atualizado ou incorporado ao contato
+// do telefone. Retorna também o JID de telefone, vazio quando ainda desconhecido.
+func (s *WhatsAppService) contactForJID(sectorID int, jid types.JID, alt types.JID) (*models.Contact, types.JID, error) {
+	pn, lid := s.resolveContactJIDs(jid, alt)
+	if pn.IsEmpty() && lid.IsEmpty() {
+		return nil, pn, fmt.Errorf("JID %s não é de um usuário", jid.String())
+	}
+
+	var lidContact *models.Contact
+	if !lid.IsEmpty() {
+		var err error
+		lidContact, err = s.contactRepository.GetByLID(sectorID, lid.String())
+		if err != nil {
+			return nil, pn, err
+		}
+	}
+
+	if pn.IsEmpty() {
+		if lidContact != nil {
+			return lidContact, pn, nil
+		}
+
+		utils.LogInfo("Criando contato identificado apenas pelo LID: %s", lid.String())
+		contact, err := s.contactRepository.CreateIfNotExists(sectorID, lid.String())
+		if err != nil {
+			return nil, pn, err
+		}
+		if err := s.contactRepository.SetLID(contact.ID, lid.String()); err != nil {
+			utils.LogError("Erro ao gravar o LID do contato %d: %v", contact.ID, err)
+		}
+		contact.LID = lid.String()
+		return contact, pn, nil
+	}
+
+	contact, err := s.contactRepository.GetByNumber(sectorID, pn.String())
+	if err != nil {
+		return nil, pn, err
+	}
+
+	// Contatos criados apenas com o LID passam a usar o telefone assim que ele é conhecido
+	lidOnly := lidContact != nil && phone.IsLID(lidContact.Number)
+	switch {
+	case contact == nil && lidOnly:
+		number := pn.User
+		if parsed, err := phone.ForSector(sectorID, pn.String()); err == nil {
+			number = parsed.Digits()
+		}
+		if err := s.contactRepository.SetNumber(lidContact.ID, number); err != nil {
+			return nil, pn, err
+		}
+		utils.LogInfo("Contato %d atualizado do LID %s para o número %s", lidContact.ID, lid.String(), number)
+		lidContact.Number = number
+		contact = lidContact
+	case contact == nil:
+		utils.LogInfo("Criando contato que não existe no banco: %s", pn.String())
+		contact, err = s.contactRepository.CreateIfNotExists(sectorID, pn.String())
+		if err != nil {
+			return nil, pn, err
+		}
+	case lidOnly && lidContact.ID != contact.ID:
+		utils.LogInfo("Incorporando contato %d (LID %s) ao contato %d", lidContact.ID, lid.String(), contact.ID)
+		// O LID é gravado antes da mesclagem, que só aceita duplicados com o mesmo número ou LID do sobrevivente
+		if contact.LID != lid.String() {
+			if err := s.contactRepository.SetLID(contact.ID, lid.String()); err != nil {
+				return nil, pn, err
+			}
+			contact.LID = lid.String()
+		}
+		merged, err := s.contactRepository.Merge(sectorID, contact.ID, []int{lidContact.ID})
+		if err != nil {
+			utils.LogError("Erro ao incorporar contato %d ao contato %d: %v", lidContact.ID, contact.ID, err)
+		} else if merged != nil {
+			contact = merged
+		}
+	}
+
+	if !lid.IsEmpty() && contact.LID != lid.String() {
+		if err := s.contactRepository.SetLID(contact.ID, lid.String()); err != nil {
+			utils.LogError("Erro ao gravar o LID do contato %d: %v", contact.ID, err)
+		} else {
+			contact.LID = lid.String()
+		}
+	}
+
+	return contact, pn, nil
+}
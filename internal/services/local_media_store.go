@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"whatsapp-bot/config"
+)
+
+// LocalMediaStore grava as mídias em disco. Os arquivos são servidos pela rota /media/,
+// que só aceita links assinados com a chave configurada
+type LocalMediaStore struct {
+	root       string
+	publicURL  string
+	signingKey []byte
+}
+
+func NewLocalMediaStore(config *config.StorageConfig) (*LocalMediaStore, error) {
+	root, err := filepath.Abs(config.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("caminho de armazenamento inválido: %v", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório de mídia: %v", err)
+	}
+
+	return &LocalMediaStore{
+		root:       root,
+		publicURL:  strings.TrimRight(config.PublicURL, "/"),
+		signingKey: []byte(config.SigningKey),
+	}, nil
+}
+
+func (s *LocalMediaStore) path(key string) (string, string, error) {
+	cleaned, err := cleanMediaKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return cleaned, filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalMediaStore) Put(key string, data []byte, contentType string) error {
+	return s.PutStream(key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// PutStream grava o arquivo em um temporário e o renomeia, para que leituras simultâneas nunca vejam um arquivo pela metade
+func (s *LocalMediaStore) PutStream(key string, body io.Reader, size int64, contentType string) error {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("erro ao criar diretório de mídia: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("erro ao criar arquivo de mídia: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao gravar arquivo de mídia: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("erro ao gravar arquivo de mídia: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("erro ao gravar arquivo de mídia: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalMediaStore) Get(key string) ([]byte, error) {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de mídia: %v", err)
+	}
+	return data, nil
+}
+
+// Open abre o arquivo para ser servido pela rota /media/ (com suporte a Range)
+func (s *LocalMediaStore) Open(key string) (*os.File, error) {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrMediaNotFound
+	}
+	return file, err
+}
+
+func (s *LocalMediaStore) Delete(key string) error {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("erro ao remover arquivo de mídia: %v", err)
+	}
+	return nil
+}
+
+// SignedURL monta o link da rota /media/ assinado com HMAC-SHA256, válido pelo tempo informado
+func (s *LocalMediaStore) SignedURL(key string, expires time.Duration) (string, error) {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return "", err
+	}
+	if expires <= 0 {
+		return "", fmt.Errorf("validade do link deve ser positiva")
+	}
+	expiresAt := time.Now().Add(expires).Unix()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("signature", s.sign(key, expiresAt))
+
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	return fmt.Sprintf("%s/media/%s?%s", s.publicURL, escaped, query.Encode()), nil
+}
+
+// VerifySignature confere a assinatura e a validade de um link gerado por SignedURL
+func (s *LocalMediaStore) VerifySignature(key string, expires string, signature string) bool {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return false
+	}
+	if time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expiresAt)))
+}
+
+func (s *LocalMediaStore) sign(key string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalMediaStore) Stat(key string) (*MediaObject, error) {
+	key, filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar arquivo de mídia: %v", err)
+	}
+
+	// O tipo é deduzido pela extensão e, na falta dela, pelo conteúdo
+	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if contentType == "" {
+		if file, err := os.Open(filePath); err == nil {
+			head := make([]byte, 512)
+			n, _ := file.Read(head)
+			file.Close()
+			contentType = http.DetectContentType(head[:n])
+		}
+	}
+
+	return &MediaObject{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: contentType,
+		ModTime:     info.ModTime(),
+	}, nil
+}
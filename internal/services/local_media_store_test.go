@@ -0,0 +1,88 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"whatsapp-bot/config"
+)
+
+func TestLocalMediaStoreSignature(t *testing.T) {
+	store, err := NewLocalMediaStore(&config.StorageConfig{
+		LocalPath:  t.TempDir(),
+		PublicURL:  "http://localhost:8081/api/v1/",
+		SigningKey: "chave-de-teste-com-16+",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalMediaStore erro inesperado: %v", err)
+	}
+
+	link, err := store.SignedURL("sector_1/images/a b.jpg", time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL erro inesperado: %v", err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("link inválido %q: %v", link, err)
+	}
+	if parsed.Path != "/api/v1/media/sector_1/images/a b.jpg" {
+		t.Fatalf("SignedURL = %s, caminho inesperado %s", link, parsed.Path)
+	}
+	expires := parsed.Query().Get("expires")
+	signature := parsed.Query().Get("signature")
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		key       string
+		expires   string
+		signature string
+		want      bool
+	}{
+		{name: "link válido", key: "sector_1/images/a b.jpg", expires: expires, signature: signature, want: true},
+		{name: "chave equivalente com barra inicial", key: "/sector_1/images/a b.jpg", expires: expires, signature: signature, want: true},
+		{name: "outra chave", key: "sector_2/images/a b.jpg", expires: expires, signature: signature, want: false},
+		{name: "validade alterada", key: "sector_1/images/a b.jpg", expires: expires + "0", signature: signature, want: false},
+		{name: "validade inválida", key: "sector_1/images/a b.jpg", expires: "amanhã", signature: signature, want: false},
+		{name: "assinatura alterada", key: "sector_1/images/a b.jpg", expires: expires, signature: strings.Repeat("0", len(signature)), want: false},
+		{name: "sem assinatura", key: "sector_1/images/a b.jpg", expires: expires, signature: "", want: false},
+		{name: "link vencido", key: "sector_1/images/a b.jpg", expires: expired, signature: store.sign("sector_1/images/a b.jpg", time.Now().Add(-time.Minute).Unix()), want: false},
+		{name: "subida de diretório é normalizada", key: "sector_1/../sector_1/images/a b.jpg", expires: expires, signature: signature, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.VerifySignature(tt.key, tt.expires, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature(%q, %q) = %v, esperado %v", tt.key, tt.expires, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalMediaStoreSignedURLRejectsInvalidInput(t *testing.T) {
+	store, err := NewLocalMediaStore(&config.StorageConfig{LocalPath: t.TempDir(), SigningKey: "chave-de-teste-com-16+"})
+	if err != nil {
+		t.Fatalf("NewLocalMediaStore erro inesperado: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		expires time.Duration
+	}{
+		{name: "chave só com barras", key: "//", expires: time.Minute},
+		{name: "chave vazia", key: "", expires: time.Minute},
+		{name: "validade zero", key: "sector_1/a.jpg", expires: 0},
+		{name: "validade negativa", key: "sector_1/a.jpg", expires: -time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if link, err := store.SignedURL(tt.key, tt.expires); err == nil {
+				t.Errorf("SignedURL(%q, %s) = %q, esperado erro", tt.key, tt.expires, link)
+			}
+		})
+	}
+}
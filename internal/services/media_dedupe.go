@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"whatsapp-bot/internal/utils"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// Validade dos uploads ao WhatsApp guardados em cache. A URL do upload traz a data em que o arquivo
+// expira no servidor (parâmetro oe); sem ela vale o TTL padrão
+const (
+	uploadCacheTTL        = 24 * time.Hour
+	uploadCacheMargin     = 1 * time.Hour
+	uploadCacheMaxEntries = 1000
+)
+
+type uploadCacheEntry struct {
+	uploaded  whatsmeow.UploadResponse
+	expiresAt time.Time
+}
+
+// uploadCache guarda, por setor, o upload criptografado de cada conteúdo já enviado ao WhatsApp, para que
+// o mesmo arquivo enviado a vários contatos seja transferido uma única vez
+type uploadCache struct {
+	mutex   sync.Mutex
+	entries map[string]uploadCacheEntry
+}
+
+func newUploadCache() *uploadCache {
+	return &uploadCache{entries: make(map[string]uploadCacheEntry)}
+}
+
+func uploadCacheKey(sum []byte, mediaType whatsmeow.MediaType) string {
+	return string(mediaType) + ":" + hex.EncodeToString(sum)
+}
+
+func (c *uploadCache) get(sum []byte, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := uploadCacheKey(sum, mediaType)
+	entry, exists := c.entries[key]
+	if !exists {
+		return whatsmeow.UploadResponse{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return whatsmeow.UploadResponse{}, false
+	}
+	return entry.uploaded, true
+}
+
+func (c *uploadCache) set(sum []byte, mediaType whatsmeow.MediaType, uploaded whatsmeow.UploadResponse) {
+	expiresAt := uploadExpiry(uploaded.URL)
+	if !time.Now().Before(expiresAt) {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= uploadCacheMaxEntries {
+		c.evict()
+	}
+	c.entries[uploadCacheKey(sum, mediaType)] = uploadCacheEntry{uploaded: uploaded, expiresAt: expiresAt}
+}
+
+// evict remove os uploads expirados e, se o cache continuar cheio, o que expira primeiro
+func (c *uploadCache) evict() {
+	now := time.Now()
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= uploadCacheMaxEntries && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// uploadExpiry calcula até quando o upload pode ser reaproveitado: o TTL padrão, limitado à expiração
+// informada pelo WhatsApp (oe, timestamp em hexadecimal) menos uma folga
+func uploadExpiry(uploadURL string) time.Time {
+	expiresAt := time.Now().Add(uploadCacheTTL)
+
+	parsed, err := url.Parse(uploadURL)
+	if err != nil {
+		return expiresAt
+	}
+	if oe := parsed.Query().Get("oe"); oe != "" {
+		if timestamp, err := strconv.ParseInt(oe, 16, 64); err == nil {
+			if limit := time.Unix(timestamp, 0).Add(-uploadCacheMargin); limit.Before(expiresAt) {
+				expiresAt = limit
+			}
+		}
+	}
+	return expiresAt
+}
+
+// uploadMedia envia o arquivo ao WhatsApp, reaproveitando o upload anterior do mesmo conteúdo enquanto ele é válido
+func (s *WhatsAppService) uploadMedia(data []byte, sum []byte, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	if uploaded, ok := s.uploadCache.get(sum, mediaType); ok {
+		utils.LogInfo("Reaproveitando upload ao WhatsApp da mídia %x", sum[:8])
+		return uploaded, nil
+	}
+
+	uploaded, err := s.client.Upload(context.Background(), data, mediaType)
+	if err != nil {
+		utils.LogError("Erro no upload da mídia: %v", err)
+		if !isDatabaseLocked(err) {
+			return whatsmeow.UploadResponse{}, classifySendError(err)
+		}
+		if fixErr := s.handleDatabaseLock(); fixErr != nil {
+			return whatsmeow.UploadResponse{}, fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
+		}
+		uploaded, err = s.client.Upload(context.Background(), data, mediaType)
+		if err != nil {
+			return whatsmeow.UploadResponse{}, classifySendError(fmt.Errorf("erro persistente ao fazer upload: %w", err))
+		}
+	}
+
+	s.uploadCache.set(sum, mediaType, uploaded)
+	return uploaded, nil
+}
+
+// ContentMediaKey gera a chave endereçada pelo SHA-256 do conteúdo, para que o mesmo arquivo fique
+// guardado uma única vez por setor
+func ContentMediaKey(sectorID int, sum []byte, ext string) string {
+	hash := hex.EncodeToString(sum)
+	if ext = mediaKeyExtension(ext); ext != "" {
+		ext = "." + ext
+	}
+	return fmt.Sprintf("sector_%d/media/%s/%s%s", sectorID, hash[:2], hash, ext)
+}
+
+// mediaKeyExtension aceita apenas extensões curtas e alfanuméricas, já que elas vêm do nome informado pelo cliente
+func mediaKeyExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if len(ext) == 0 || len(ext) > 10 {
+		return ""
+	}
+	for _, r := range ext {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// mediaHash é o SHA-256 usado para endereçar a mídia no armazenamento e no cache de uploads
+func mediaHash(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// storeMediaOnce grava o arquivo na chave endereçada pelo hash, sem novo upload quando ele já está guardado
+func (s *WhatsAppService) storeMediaOnce(sectorID int, data []byte, sum []byte, ext string, mimeType string) (string, error) {
+	key := ContentMediaKey(sectorID, sum, ext)
+	if err := s.putMediaIfMissing(key, data, mimeType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// putMediaIfMissing grava o arquivo apenas quando a chave ainda não existe no armazenamento
+func (s *WhatsAppService) putMediaIfMissing(key string, data []byte, mimeType string) error {
+	_, err := s.mediaStore.Stat(key)
+	if err == nil {
+		utils.LogInfo("Mídia já guardada no armazenamento: %s", key)
+		return nil
+	}
+	if !errors.Is(err, ErrMediaNotFound) {
+		utils.LogWarning("Erro ao consultar mídia %s, gravando novamente: %v", key, err)
+	}
+
+	if err := s.mediaStore.Put(key, data, mimeType); err != nil {
+		return fmt.Errorf("erro ao gravar mídia: %v", err)
+	}
+	utils.LogInfo("Upload concluído: %s", key)
+	return nil
+}
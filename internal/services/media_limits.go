@@ -0,0 +1,34 @@
+package services
+
+import (
+	"fmt"
+	"whatsapp-bot/internal/models"
+)
+
+// MediaSizeLimit retorna o tamanho máximo, em bytes, configurado para o tipo de mensagem (0 quando não há limite)
+func (cm *ConnectionManager) MediaSizeLimit(kind string) int64 {
+	switch kind {
+	case models.OutboundKindImage:
+		return cm.config.Media.MaxImageSize
+	case models.OutboundKindAudio:
+		return cm.config.Media.MaxAudioSize
+	case models.OutboundKindDocument:
+		return cm.config.Media.MaxDocumentSize
+	}
+	return 0
+}
+
+// CheckMediaSize retorna ErrMediaTooLarge quando o arquivo excede o limite configurado para o tipo de mensagem
+func (cm *ConnectionManager) CheckMediaSize(kind string, size int) error {
+	limit := cm.MediaSizeLimit(kind)
+	if limit > 0 && int64(size) > limit {
+		return newError(ErrMediaTooLarge, fmt.Errorf("%d bytes, limite de %d MB", size, limit>>20))
+	}
+	return nil
+}
+
+// MediaTooLargeError é o ErrMediaTooLarge de um arquivo recebido em stream, interrompido ao passar do limite
+// (o tamanho total não é conhecido)
+func MediaTooLargeError(limit int64) error {
+	return newError(ErrMediaTooLarge, fmt.Errorf("limite de %d MB", limit>>20))
+}
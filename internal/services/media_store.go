@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"whatsapp-bot/config"
+)
+
+// ErrMediaNotFound é retornado quando a chave não existe no armazenamento
+var ErrMediaNotFound = errors.New("arquivo de mídia não encontrado")
+
+// MediaObject descreve um arquivo guardado no armazenamento de mídia
+type MediaObject struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// MediaStore guarda as mídias das conversas (S3/MinIO ou disco local), sempre endereçadas por chave
+type MediaStore interface {
+	// Put grava o arquivo. Os arquivos são privados: o acesso é sempre por SignedURL
+	Put(key string, data []byte, contentType string) error
+	// PutStream grava o arquivo lendo do body, sem carregá-lo inteiro na memória. size pode ser -1 quando desconhecido
+	PutStream(key string, body io.Reader, size int64, contentType string) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	// SignedURL gera um link de acesso temporário ao arquivo
+	SignedURL(key string, expires time.Duration) (string, error)
+	Stat(key string) (*MediaObject, error)
+}
+
+// NewMediaStore cria o armazenamento de mídia escolhido na configuração
+func NewMediaStore(cfg *config.Config) (MediaStore, error) {
+	switch cfg.Storage.Backend {
+	case config.StorageBackendLocal:
+		store, err := NewLocalMediaStore(cfg.Storage)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case config.StorageBackendS3, "":
+		store, err := NewS3Service(cfg.S3Config)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("backend de armazenamento desconhecido: %s", cfg.Storage.Backend)
+}
+
+// reusableMediaFolders são as pastas do setor cujos arquivos podem ser reaproveitados em um novo envio. A fila de
+// envio fica de fora, já que os arquivos são apagados depois de enviados
+var reusableMediaFolders = []string{"uploads", "media", "templates", "campaigns", "images", "audios", "documents", "videos", "stickers"}
+
+// ReusableMediaKey indica se o setor pode reaproveitar o arquivo em um novo envio. Apenas as pastas conhecidas
+// do próprio setor são aceitas; qualquer outra chave é recusada
+func ReusableMediaKey(sectorID int, key string) bool {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return false
+	}
+	for _, folder := range reusableMediaFolders {
+		if strings.HasPrefix(key, fmt.Sprintf("sector_%d/%s/", sectorID, folder)) {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadMediaKey gera a chave dos arquivos enviados pelo /upload para um setor
+func UploadMediaKey(sectorID int, fileName string) string {
+	return fmt.Sprintf("sector_%d/uploads/%s", sectorID, fileName)
+}
+
+// MediaPreviewKey é a chave da prévia (JPEG) de uma imagem ou documento guardado, exibida no painel dos atendentes
+func MediaPreviewKey(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_preview.jpg"
+}
+
+// cleanMediaKey normaliza a chave e impede que ela saia do diretório/bucket (ex: ../../etc)
+func cleanMediaKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(key, "\\", "/")), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("chave de mídia inválida: %q", key)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	outboundPollInterval = 5 * time.Second
+	outboundBatchSize    = 20
+	outboundBaseBackoff  = 5 * time.Second
+	outboundMaxBackoff   = 10 * time.Minute
+)
+
+// outboundDispatcher consome a fila de envio de um único setor, uma mensagem por vez
+type outboundDispatcher struct {
+	sectorID int
+	manager  *ConnectionManager
+	wake     chan struct{}
+	stop     chan struct{}
+}
+
+func newOutboundDispatcher(sectorID int, manager *ConnectionManager) *outboundDispatcher {
+	return &outboundDispatcher{
+		sectorID: sectorID,
+		manager:  manager,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (d *outboundDispatcher) run() {
+	utils.LogInfo("Iniciando despachante da fila de envio para setor %d", d.sectorID)
+
+	for {
+		d.processDue()
+
+		select {
+		case <-d.stop:
+			utils.LogInfo("Despachante da fila de envio encerrado para setor %d", d.sectorID)
+			return
+		case <-d.wake:
+		case <-time.After(outboundPollInterval):
+		}
+	}
+}
+
+// notify acorda o despachante sem bloquear caso ele já tenha um aviso pendente
+func (d *outboundDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *outboundDispatcher) processDue() {
+	// Enquanto o setor estiver desconectado as mensagens permanecem na fila sem consumir tentativas
+	service := d.manager.activeConnection(d.sectorID)
+	if service == nil {
+		return
+	}
+
+	messages, err := d.manager.outboundRepository.ClaimDue(d.sectorID, outboundBatchSize)
+	if err != nil {
+		utils.LogError("Erro ao buscar mensagens da fila do setor %d: %v", d.sectorID, err)
+		return
+	}
+
+	for i, message := range messages {
+		select {
+		case <-d.stop:
+			d.releaseClaimed(messages[i:])
+			return
+		default:
+		}
+
+		// A conexão pode cair no meio do lote; o restante volta para a fila sem consumir tentativas
+		if service = d.manager.activeConnection(d.sectorID); service == nil {
+			d.releaseClaimed(messages[i:])
+			return
+		}
+
+		d.deliver(service, message)
+	}
+}
+
+// releaseClaimed devolve para a fila as mensagens reservadas que não foram entregues ao WhatsApp
+func (d *outboundDispatcher) releaseClaimed(messages []*models.OutboundMessage) {
+	ids := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	if err := d.manager.outboundRepository.ReleaseClaimed(ids); err != nil {
+		utils.LogError("Erro ao devolver mensagens para a fila do setor %d: %v", d.sectorID, err)
+		return
+	}
+	utils.LogInfo("%d mensagens devolvidas para a fila do setor %d", len(ids), d.sectorID)
+}
+
+func (d *outboundDispatcher) deliver(service *WhatsAppService, message *models.OutboundMessage) {
+	repository := d.manager.outboundRepository
+
+	// O ID da mensagem é reservado na primeira tentativa e reaproveitado nas seguintes: se o WhatsApp aceitou um envio
+	// cuja resposta se perdeu, a nova tentativa usa o mesmo ID e o destinatário não recebe a mensagem duplicada
+	if message.PendingMessageID == "" {
+		pendingMessageID := string(service.client.GenerateMessageID())
+		if err := repository.SetPendingMessageID(message.ID, pendingMessageID); err != nil {
+			utils.LogError("Erro ao reservar ID da mensagem %d: %v", message.ID, err)
+			d.releaseClaimed([]*models.OutboundMessage{message})
+			return
+		}
+		message.PendingMessageID = pendingMessageID
+	}
+	messageID := types.MessageID(message.PendingMessageID)
+	if message.Source == models.OutboundSourceCampaign {
+		d.manager.recordCampaignMessageID(message.SourceID, message.PendingMessageID)
+	}
+
+	whatsappMessageID, err := d.manager.sendOutbound(service, message, messageID)
+
+	// O destinatário atingiu o seu limite: a mensagem aguarda na fila sem consumir tentativa e o despachante segue com as demais
+	var recipientLimited *RecipientRateLimitedError
+	if errors.As(err, &recipientLimited) {
+		message.Status = models.OutboundStatusQueued
+		message.NextAttemptAt = time.Now().UTC().Add(recipientLimited.RetryAfter)
+		utils.LogInfo("Mensagem %d do setor %d adiada em %v pelo limite de envios do destinatário", message.ID, d.sectorID, recipientLimited.RetryAfter)
+		if markErr := repository.Postpone(message.ID, message.NextAttemptAt); markErr != nil {
+			utils.LogError("Erro ao adiar mensagem %d: %v", message.ID, markErr)
+		}
+		d.manager.publishOutboundStatus(message)
+		return
+	}
+
+	message.Attempts++
+
+	if err == nil {
+		message.Status = models.OutboundStatusSent
+		message.WhatsAppMessageID = whatsappMessageID
+		message.LastError = ""
+		if markErr := repository.MarkSent(message.ID, whatsappMessageID); markErr != nil {
+			utils.LogError("Erro ao marcar mensagem %d como enviada: %v", message.ID, markErr)
+		}
+		d.manager.removeOutboundMedia(service, message)
+		d.manager.publishOutboundStatus(message)
+		d.manager.notifyOutboundSource(message)
+		return
+	}
+
+	message.LastError = err.Error()
+	if message.Attempts >= message.MaxAttempts || !IsRetryable(err) {
+		utils.LogError("Mensagem %d do setor %d movida para dead após %d tentativas: %v", message.ID, d.sectorID, message.Attempts, err)
+		message.Status = models.OutboundStatusDead
+		if markErr := repository.MarkDead(message.ID, message.LastError); markErr != nil {
+			utils.LogError("Erro ao marcar mensagem %d como dead: %v", message.ID, markErr)
+		}
+	} else {
+		message.Status = models.OutboundStatusQueued
+		message.NextAttemptAt = time.Now().UTC().Add(outboundBackoff(message.Attempts))
+		utils.LogWarning("Falha ao enviar mensagem %d do setor %d (tentativa %d/%d): %v", message.ID, d.sectorID, message.Attempts, message.MaxAttempts, err)
+		if markErr := repository.MarkRetry(message.ID, message.LastError, message.NextAttemptAt); markErr != nil {
+			utils.LogError("Erro ao reagendar mensagem %d: %v", message.ID, markErr)
+		}
+	}
+
+	d.manager.publishOutboundStatus(message)
+	if message.Status == models.OutboundStatusDead {
+		d.manager.notifyOutboundSource(message)
+	}
+}
+
+// outboundBackoff calcula o intervalo exponencial até a próxima tentativa
+func outboundBackoff(attempts int) time.Duration {
+	delay := outboundBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= outboundMaxBackoff {
+			return outboundMaxBackoff
+		}
+	}
+	return delay
+}
+
+// OutboundMediaKey gera a chave no armazenamento onde a mídia fica guardada até o envio
+func OutboundMediaKey(sectorID int, fileName string) string {
+	return fmt.Sprintf("%s%d_%s", outboundMediaPrefix(sectorID), time.Now().UnixNano(), fileName)
+}
+
+func outboundMediaPrefix(sectorID int) string {
+	return fmt.Sprintf("sector_%d/outbound/", sectorID)
+}
+
+// IsOutboundMediaKey indica se o arquivo foi guardado apenas para o envio e pode ser apagado depois dele. Arquivos
+// reaproveitados pelo objectKey ficam em outras pastas e são mantidos
+func IsOutboundMediaKey(sectorID int, key string) bool {
+	return strings.HasPrefix(key, outboundMediaPrefix(sectorID))
+}
+
+// sendOutbound envia a mensagem enfileirada pelo caminho normal do WhatsAppService
+func (cm *ConnectionManager) sendOutbound(service *WhatsAppService, message *models.OutboundMessage, messageID types.MessageID) (string, error) {
+	if message.Kind == models.OutboundKindText {
+		return service.SendMessage(message.SectorID, message.Recipient, message.Content, message.UserID, message.IsAnonymous, message.SentAt, messageID)
+	}
+
+	if service.mediaStore == nil {
+		return "", fmt.Errorf("armazenamento de mídia não está disponível")
+	}
+
+	data, err := service.mediaStore.Get(message.MediaKey)
+	if err != nil {
+		return "", err
+	}
+
+	switch message.Kind {
+	case models.OutboundKindImage:
+		return service.SendImage(message.SectorID, message.Recipient, data, message.Content, message.UserID, message.IsAnonymous, message.SentAt, messageID)
+	case models.OutboundKindAudio:
+		return service.SendAudio(message.SectorID, message.Recipient, data, message.UserID, message.IsAnonymous, message.SentAt, messageID)
+	case models.OutboundKindDocument:
+		return service.SendDocument(message.SectorID, message.Recipient, data, message.FileName, message.Content, message.UserID, message.IsAnonymous, message.SentAt, messageID)
+	}
+
+	return "", fmt.Errorf("tipo de mensagem não suportado: %s", message.Kind)
+}
+
+// removeOutboundMedia apaga o arquivo temporário da fila depois que a mensagem foi enviada
+func (cm *ConnectionManager) removeOutboundMedia(service *WhatsAppService, message *models.OutboundMessage) {
+	if message.MediaKey == "" || service.mediaStore == nil {
+		return
+	}
+	if !IsOutboundMediaKey(message.SectorID, message.MediaKey) {
+		return
+	}
+	if err := service.mediaStore.Delete(message.MediaKey); err != nil {
+		utils.LogWarning("Não foi possível remover mídia da fila %s: %v", message.MediaKey, err)
+	}
+}
+
+func (cm *ConnectionManager) publishOutboundStatus(message *models.OutboundMessage) {
+	wsnotify.SendOutboundStatusEvent(
+		message.ID,
+		message.SectorID,
+		message.Recipient,
+		message.Kind,
+		message.Status,
+		message.Attempts,
+		message.WhatsAppMessageID,
+		message.LastError,
+	)
+}
+
+// notifyOutboundSource repassa o resultado final (sent ou dead) ao recurso que originou a mensagem
+func (cm *ConnectionManager) notifyOutboundSource(message *models.OutboundMessage) {
+	switch message.Source {
+	case models.OutboundSourceScheduled:
+		cm.handleScheduledResult(message)
+	case models.OutboundSourceCampaign:
+		cm.handleCampaignResult(message)
+	}
+}
+
+// EnqueueOutbound grava a mensagem na fila do setor e acorda o despachante correspondente
+func (cm *ConnectionManager) EnqueueOutbound(message *models.OutboundMessage) error {
+	if err := cm.CheckSector(message.SectorID); err != nil {
+		return err
+	}
+
+	if err := cm.outboundRepository.Enqueue(message); err != nil {
+		return err
+	}
+
+	utils.LogInfo("Mensagem %d enfileirada para %s no setor %d", message.ID, message.Recipient, message.SectorID)
+	cm.publishOutboundStatus(message)
+	cm.ensureOutboundDispatcher(message.SectorID).notify()
+	return nil
+}
+
+// RetryOutbound devolve uma mensagem dead para a fila do setor
+func (cm *ConnectionManager) RetryOutbound(id int64) (*models.OutboundMessage, error) {
+	if err := cm.outboundRepository.Requeue(id); err != nil {
+		return nil, err
+	}
+
+	message, err := cm.outboundRepository.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, fmt.Errorf("mensagem não encontrada")
+	}
+	if message.Source == models.OutboundSourceCampaign {
+		cm.requeueCampaignRecipient(message)
+	}
+
+	cm.publishOutboundStatus(message)
+	cm.ensureOutboundDispatcher(message.SectorID).notify()
+	return message, nil
+}
+
+// StartOutboundDispatchers retoma as filas pendentes após uma reinicialização
+func (cm *ConnectionManager) StartOutboundDispatchers() error {
+	if err := cm.outboundRepository.ResetSending(); err != nil {
+		return err
+	}
+
+	sectors, err := cm.outboundRepository.PendingSectors()
+	if err != nil {
+		return err
+	}
+
+	for _, sectorID := range sectors {
+		cm.ensureOutboundDispatcher(sectorID)
+	}
+	return nil
+}
+
+func (cm *ConnectionManager) ensureOutboundDispatcher(sectorID int) *outboundDispatcher {
+	cm.dispatcherMutex.Lock()
+	defer cm.dispatcherMutex.Unlock()
+
+	if dispatcher, exists := cm.dispatchers[sectorID]; exists {
+		return dispatcher
+	}
+
+	dispatcher := newOutboundDispatcher(sectorID, cm)
+	cm.dispatchers[sectorID] = dispatcher
+	go dispatcher.run()
+	return dispatcher
+}
+
+// notifyOutboundDispatcher acorda o despachante do setor, se existir (ex.: após reconectar)
+func (cm *ConnectionManager) notifyOutboundDispatcher(sectorID int) {
+	cm.dispatcherMutex.Lock()
+	dispatcher, exists := cm.dispatchers[sectorID]
+	cm.dispatcherMutex.Unlock()
+
+	if exists {
+		dispatcher.notify()
+	}
+}
+
+func (cm *ConnectionManager) stopOutboundDispatchers() {
+	cm.dispatcherMutex.Lock()
+	defer cm.dispatcherMutex.Unlock()
+
+	for sectorID, dispatcher := range cm.dispatchers {
+		close(dispatcher.stop)
+		delete(cm.dispatchers, sectorID)
+	}
+}
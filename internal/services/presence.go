@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// SetChatPresence envia o estado de presença do setor na conversa sem bloquear a chamada.
+// Os estados composing e recording voltam a paused automaticamente após duration segundos,
+// a menos que sejam renovados ou encerrados antes disso.
+func (s *WhatsAppService) SetChatPresence(recipient string, state string, duration int) error {
+	if !s.connected || s.client == nil {
+		return ErrNotConnected
+	}
+
+	if !models.IsValidPresenceState(state) {
+		return newError(ErrInvalidRequest, fmt.Errorf("estado de presença inválido: %s", state))
+	}
+
+	key, err := presenceKey(s.sectorID, recipient)
+	if err != nil {
+		return err
+	}
+	// O JID canônico preserva contas antigas registradas sem o nono dígito
+	jid, err := s.ResolveRecipient(recipient)
+	if err != nil {
+		return err
+	}
+
+	if state == models.PresenceStatePaused {
+		s.stopPresenceTimer(key)
+		defer s.releaseAvailability()
+		return s.sendChatPresence(jid, key, state)
+	}
+
+	if duration <= 0 {
+		duration = models.DefaultPresenceDuration
+	}
+	if duration > models.MaxPresenceDuration {
+		duration = models.MaxPresenceDuration
+	}
+
+	if err := s.markAvailable(); err != nil {
+		if errors.Is(err, whatsmeow.ErrNoPushName) {
+			return fmt.Errorf("não foi possível enviar status de digitação: dispositivo ainda não está totalmente configurado")
+		}
+		return fmt.Errorf("erro ao definir presença: %v", err)
+	}
+
+	if err := s.sendChatPresence(jid, key, state); err != nil {
+		return err
+	}
+
+	s.presenceMutex.Lock()
+	if timer, exists := s.presenceTimers[key]; exists {
+		timer.Stop()
+	}
+	s.presenceTimers[key] = time.AfterFunc(time.Duration(duration)*time.Second, func() {
+		s.presenceMutex.Lock()
+		delete(s.presenceTimers, key)
+		s.presenceMutex.Unlock()
+
+		if err := s.sendChatPresence(jid, key, models.PresenceStatePaused); err != nil {
+			utils.LogDebug("Não foi possível encerrar a presença em %s: %v", jid.User, err)
+		}
+		s.releaseAvailability()
+	})
+	s.presenceMutex.Unlock()
+
+	return nil
+}
+
+// presenceKey identifica a conversa nos timers e nos eventos do frontend: o número normalizado
+// do contato ou, para contatos conhecidos apenas pelo LID, o usuário do LID
+func presenceKey(sectorID int, recipient string) (string, error) {
+	if phone.IsLID(recipient) {
+		lid, err := types.ParseJID(strings.TrimSpace(recipient))
+		if err != nil {
+			return "", newError(ErrInvalidRecipient, err)
+		}
+		return lid.User, nil
+	}
+
+	number, err := phone.ForSector(sectorID, recipient)
+	if err != nil {
+		return "", newError(ErrInvalidRecipient, err)
+	}
+	return number.Digits(), nil
+}
+
+// stopPresenceTimer cancela o encerramento automático pendente da presença na conversa
+func (s *WhatsAppService) stopPresenceTimer(user string) {
+	s.presenceMutex.Lock()
+	defer s.presenceMutex.Unlock()
+
+	if timer, exists := s.presenceTimers[user]; exists {
+		timer.Stop()
+		delete(s.presenceTimers, user)
+	}
+}
+
+// sendChatPresence envia o estado ao WhatsApp e replica a mudança para o frontend do setor
+func (s *WhatsAppService) sendChatPresence(jid types.JID, key string, state string) error {
+	presence := types.ChatPresenceComposing
+	media := types.ChatPresenceMediaText
+	switch state {
+	case models.PresenceStateRecording:
+		media = types.ChatPresenceMediaAudio
+	case models.PresenceStatePaused:
+		presence = types.ChatPresencePaused
+	}
+
+	if err := s.client.SendChatPresence(jid, presence, media); err != nil {
+		return fmt.Errorf("erro ao enviar status de presença: %v", err)
+	}
+
+	wsnotify.SendAgentPresenceEvent(s.sectorID, key, state)
+	return nil
+}
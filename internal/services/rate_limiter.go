@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"whatsapp-bot/internal/models"
+)
+
+// rateLimiterMaxIdleBuckets limita quantos baldes de destinatários ficam em memória antes da limpeza
+const rateLimiterMaxIdleBuckets = 10000
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// RateLimiter implementa token buckets por setor e por destinatário usando os limites do setor
+type RateLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow consome um envio dos baldes do setor e do destinatário. Quando não há saldo,
+// nada é consumido e é retornado o tempo até o próximo envio permitido
+func (l *RateLimiter) Allow(settings *models.SectorSettings, recipient string) (bool, time.Duration) {
+	allowed, retryAfter, _ := l.allow(settings, recipient)
+	return allowed, retryAfter
+}
+
+// allow também informa se o envio foi barrado pelo limite do destinatário
+func (l *RateLimiter) allow(settings *models.SectorSettings, recipient string) (bool, time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	sectorKey, recipientKey := rateLimiterKeys(settings.SectorID, recipient)
+
+	sectorWait := l.wait(sectorKey, settings.SectorRatePerMinute, settings.SectorBurst, now)
+	recipientWait := l.wait(recipientKey, settings.RecipientRatePerMinute, settings.RecipientBurst, now)
+	if recipientWait > 0 {
+		return false, max(recipientWait, sectorWait), true
+	}
+	if sectorWait > 0 {
+		return false, sectorWait, false
+	}
+
+	l.consume(sectorKey, settings.SectorRatePerMinute)
+	l.consume(recipientKey, settings.RecipientRatePerMinute)
+	l.prune(now)
+	return true, 0, false
+}
+
+// AllowKey consome um token de um balde avulso, usado para ritmos que não dependem do setor (ex.: campanhas)
+func (l *RateLimiter) AllowKey(key string, perMinute int, burst int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if l.wait(key, perMinute, burst, now) > 0 {
+		return false
+	}
+
+	l.consume(key, perMinute)
+	l.prune(now)
+	return true
+}
+
+// Wait bloqueia enquanto o limite do setor estiver esgotado. Quando o limite esgotado é o do destinatário,
+// não espera: retorna o tempo até o próximo envio permitido para ele, para que os demais não fiquem parados
+func (l *RateLimiter) Wait(settings *models.SectorSettings, recipient string) time.Duration {
+	for {
+		allowed, retryAfter, recipientLimited := l.allow(settings, recipient)
+		if allowed {
+			return 0
+		}
+		if recipientLimited {
+			return retryAfter
+		}
+		time.Sleep(retryAfter)
+	}
+}
+
+// wait atualiza o saldo do balde e retorna quanto falta para existir um token disponível
+func (l *RateLimiter) wait(key string, perMinute int, burst int, now time.Time) time.Duration {
+	if perMinute <= 0 {
+		return 0
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	ratePerSecond := float64(perMinute) / 60
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(burst), updatedAt: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+elapsed*ratePerSecond)
+	bucket.updatedAt = now
+
+	if bucket.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - bucket.tokens) / ratePerSecond * float64(time.Second))
+}
+
+func (l *RateLimiter) consume(key string, perMinute int) {
+	if perMinute <= 0 {
+		return
+	}
+	if bucket, exists := l.buckets[key]; exists {
+		bucket.tokens--
+	}
+}
+
+// prune remove baldes parados há mais de uma hora quando o mapa cresce demais
+func (l *RateLimiter) prune(now time.Time) {
+	if len(l.buckets) < rateLimiterMaxIdleBuckets {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updatedAt) > time.Hour {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func rateLimiterKeys(sectorID int, recipient string) (string, string) {
+	sectorKey := fmt.Sprintf("sector:%d", sectorID)
+	return sectorKey, sectorKey + ":recipient:" + recipient
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Quantidade máxima de mensagens por recibo de leitura enviado ao WhatsApp
+const readReceiptBatchSize = 50
+
+// MarkConversationRead marca como lidas todas as mensagens recebidas do contato.
+// Envia os recibos de leitura ao WhatsApp em lotes (quando o setor está conectado),
+// atualiza o banco em um único comando e notifica o frontend via WebSocket.
+// Retorna a quantidade de mensagens marcadas e se os recibos foram enviados ao WhatsApp.
+func (cm *ConnectionManager) MarkConversationRead(sectorID int, contactID int) (int, bool, error) {
+	contact, err := cm.contactRepository.GetByID(sectorID, contactID)
+	if err != nil {
+		return 0, false, err
+	}
+	if contact == nil {
+		return 0, false, fmt.Errorf("contato %d não encontrado no setor %d", contactID, sectorID)
+	}
+
+	messages, err := cm.messageRepository.GetUnreadIncoming(sectorID, contactID)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(messages) == 0 {
+		return 0, false, nil
+	}
+
+	receiptSent := false
+	if service := cm.activeConnection(sectorID); service != nil {
+		chatJID, err := service.contactChatJID(contact)
+		if err != nil {
+			utils.LogError("Não foi possível resolver o contato %d para o recibo de leitura: %v", contactID, err)
+		} else {
+			receiptSent = service.sendReadReceipts(chatJID, messages)
+		}
+	}
+
+	marked, err := cm.messageRepository.MarkContactMessagesAsRead(sectorID, contactID)
+	if err != nil {
+		return 0, receiptSent, err
+	}
+
+	for _, message := range messages {
+		publishMessageRead(message)
+	}
+
+	utils.LogInfo("Marcadas %d mensagens como lidas para o contato %d do setor %d", marked, contactID, sectorID)
+	return int(marked), receiptSent, nil
+}
+
+// contactChatJID retorna o JID da conversa do contato: o LID, quando conhecido, ou o JID canônico
+// do número resolvido pelo cache de destinatários, que preserva contas antigas registradas sem o nono dígito
+func (s *WhatsAppService) contactChatJID(contact *models.Contact) (types.JID, error) {
+	if contact.LID != "" {
+		return types.ParseJID(contact.LID)
+	}
+	return s.ResolveRecipient(contact.Number)
+}
+
+// sendReadReceipts envia os recibos de leitura ao WhatsApp em lotes
+func (s *WhatsAppService) sendReadReceipts(chatJID types.JID, messages []*models.Message) bool {
+	if s.client == nil {
+		return false
+	}
+
+	ids := make([]types.MessageID, 0, len(messages))
+	for _, message := range messages {
+		if message.WhatsAppMessageID != "" {
+			ids = append(ids, types.MessageID(message.WhatsAppMessageID))
+		}
+	}
+	if len(ids) == 0 {
+		return false
+	}
+
+	for start := 0; start < len(ids); start += readReceiptBatchSize {
+		end := start + readReceiptBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := s.client.MarkRead(ids[start:end], time.Now(), chatJID, chatJID); err != nil {
+			utils.LogError("Erro ao enviar recibo de leitura para %s: %v", chatJID.String(), err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// publishMessageRead envia ao frontend a mensagem com status de lida (duas barras azuis)
+func publishMessageRead(message *models.Message) {
+	var urlPtr, fileNamePtr, mimeTypePtr *string
+	if message.URL != "" {
+		urlPtr = &message.URL
+	}
+	if message.NomeArquivo != "" {
+		fileNamePtr = &message.NomeArquivo
+	}
+	if message.MimeType != "" {
+		mimeTypePtr = &message.MimeType
+	}
+
+	// data_envio vem no formato brasileiro DD/MM/AAAA HH:MM:SS
+	messageTime, err := time.Parse("02/01/2006 15:04:05", message.DataEnvio)
+	if err != nil {
+		messageTime = time.Now()
+	}
+
+	wsnotify.SendMessageEvent(
+		message.ID,
+		int(message.ContatoID),
+		message.IDSetor,
+		message.Conteudo,
+		message.Tipo,
+		urlPtr,
+		fileNamePtr,
+		mimeTypePtr,
+		messageTime,
+		message.Enviado,
+		true,
+		models.StatusRead,
+	)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"whatsapp-bot/internal/models"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestContactChatJID(t *testing.T) {
+	service := &WhatsAppService{sectorID: 1, recipientCache: newRecipientCache()}
+	// Conta antiga registrada sem o nono dígito: o cache guarda o JID devolvido pelo IsOnWhatsApp
+	service.recipientCache.set("5511987654321", types.NewJID("551187654321", types.DefaultUserServer), true)
+	service.recipientCache.set("5511912345678", types.NewJID("5511912345678", types.DefaultUserServer), true)
+
+	tests := []struct {
+		name    string
+		contact models.Contact
+		want    string
+	}{
+		{name: "conta antiga com JID de 12 dígitos", contact: models.Contact{Number: "5511987654321"}, want: "551187654321@s.whatsapp.net"},
+		{name: "número gravado sem o nono dígito", contact: models.Contact{Number: "551187654321"}, want: "551187654321@s.whatsapp.net"},
+		{name: "conta com o nono dígito", contact: models.Contact{Number: "5511912345678"}, want: "5511912345678@s.whatsapp.net"},
+		{name: "LID conhecido tem prioridade", contact: models.Contact{Number: "5511987654321", LID: "123456789012345@lid"}, want: "123456789012345@lid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jid, err := service.contactChatJID(&tt.contact)
+			if err != nil {
+				t.Fatalf("contactChatJID(%+v) erro inesperado: %v", tt.contact, err)
+			}
+			if jid.String() != tt.want {
+				t.Fatalf("contactChatJID(%+v) = %s, esperado %s", tt.contact, jid.String(), tt.want)
+			}
+		})
+	}
+}
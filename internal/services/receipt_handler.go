@@ -0,0 +1,33 @@
+package services
+
+import (
+	"whatsapp-bot/internal/models"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleReceipt trata recibos de entrega e leitura das mensagens enviadas pelo setor
+func (s *WhatsAppService) handleReceipt(evt interface{}) {
+	receipt, ok := evt.(*events.Receipt)
+	if !ok || s.manager == nil {
+		return
+	}
+
+	var status string
+	switch receipt.Type {
+	case types.ReceiptTypeDelivered:
+		status = models.CampaignRecipientDelivered
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		status = models.CampaignRecipientRead
+	default:
+		return
+	}
+
+	ids := make([]string, len(receipt.MessageIDs))
+	for i, id := range receipt.MessageIDs {
+		ids[i] = string(id)
+	}
+
+	s.manager.handleCampaignReceipts(s.sectorID, ids, status)
+}
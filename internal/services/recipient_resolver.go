@@ -0,0 +1,196 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
+	"whatsapp-bot/internal/utils"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Validade das respostas do IsOnWhatsApp guardadas em cache
+const (
+	recipientCacheTTL         = 24 * time.Hour
+	recipientNegativeCacheTTL = 1 * time.Hour
+	isOnWhatsAppBatchSize     = 50
+)
+
+type recipientCacheEntry struct {
+	jid       types.JID
+	exists    bool
+	expiresAt time.Time
+}
+
+// recipientCache guarda, por setor, o resultado da verificação de cada número
+type recipientCache struct {
+	mutex   sync.RWMutex
+	entries map[string]recipientCacheEntry
+}
+
+func newRecipientCache() *recipientCache {
+	return &recipientCache{entries: make(map[string]recipientCacheEntry)}
+}
+
+func (c *recipientCache) get(number string) (recipientCacheEntry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, exists := c.entries[number]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return recipientCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *recipientCache) set(number string, jid types.JID, exists bool) {
+	ttl := recipientCacheTTL
+	if !exists {
+		ttl = recipientNegativeCacheTTL
+	}
+
+	c.mutex.Lock()
+	c.entries[number] = recipientCacheEntry{jid: jid, exists: exists, expiresAt: time.Now().Add(ttl)}
+	c.mutex.Unlock()
+}
+
+// ResolveRecipient verifica se o número possui WhatsApp e retorna o seu JID canônico
+func (s *WhatsAppService) ResolveRecipient(recipient string) (types.JID, error) {
+	// Contatos conhecidos apenas pelo LID: usa o telefone se a sessão já conhece o mapeamento,
+	// senão envia endereçando pelo próprio LID
+	if phone.IsLID(recipient) {
+		lid, err := types.ParseJID(strings.TrimSpace(recipient))
+		if err != nil {
+			return types.JID{}, newError(ErrInvalidRecipient, err)
+		}
+		pn, _ := s.resolveContactJIDs(lid, types.EmptyJID)
+		if pn.IsEmpty() {
+			return lid.ToNonAD(), nil
+		}
+		recipient = pn.String()
+	}
+
+	results, err := s.CheckNumbers([]string{recipient})
+	if err != nil {
+		return types.JID{}, err
+	}
+
+	result := results[0]
+	if result.Error != "" {
+		return types.JID{}, newError(ErrInvalidRecipient, fmt.Errorf("%s", result.Error))
+	}
+	if !result.Exists {
+		return types.JID{}, newError(ErrNotOnWhatsApp, fmt.Errorf("%s", result.Number))
+	}
+
+	return types.ParseJID(result.JID)
+}
+
+// CheckNumbers verifica em lote quais números possuem WhatsApp, consultando o cache do setor antes do servidor
+func (s *WhatsAppService) CheckNumbers(numbers []string) ([]models.NumberCheckResult, error) {
+	results := make([]models.NumberCheckResult, len(numbers))
+	pending := make(map[string][]int)
+	var queries []string
+
+	for i, input := range numbers {
+		results[i].Input = input
+
+		parsed, err := phone.ForSector(s.sectorID, input)
+		if err != nil {
+			results[i].Error = "número de telefone inválido"
+			continue
+		}
+		number := parsed.Digits()
+		results[i].Number = number
+
+		if entry, cached := s.recipientCache.get(number); cached {
+			applyRecipientEntry(&results[i], entry)
+			continue
+		}
+
+		if _, queued := pending[number]; !queued {
+			queries = append(queries, number)
+		}
+		pending[number] = append(pending[number], i)
+	}
+
+	if len(queries) == 0 {
+		return results, nil
+	}
+
+	if !s.connected || s.client == nil {
+		return nil, ErrNotConnected
+	}
+
+	for start := 0; start < len(queries); start += isOnWhatsAppBatchSize {
+		end := start + isOnWhatsAppBatchSize
+		if end > len(queries) {
+			end = len(queries)
+		}
+		if err := s.queryNumbers(queries[start:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	for number, indexes := range pending {
+		entry, _ := s.recipientCache.get(number)
+		for _, i := range indexes {
+			applyRecipientEntry(&results[i], entry)
+		}
+	}
+
+	return results, nil
+}
+
+// queryNumbers consulta o servidor do WhatsApp e grava no cache o resultado de cada número.
+// Celulares brasileiros são consultados com e sem o nono dígito, pois contas antigas podem estar registradas sem ele
+func (s *WhatsAppService) queryNumbers(numbers []string) error {
+	var phones []string
+	variantOf := make(map[string]string)
+	for _, number := range numbers {
+		parsed, err := phone.Parse("+"+number, phone.DefaultRegion)
+		if err != nil {
+			continue
+		}
+		for _, variant := range parsed.Variants() {
+			phones = append(phones, "+"+variant)
+			variantOf[variant] = number
+		}
+	}
+
+	responses, err := s.client.IsOnWhatsApp(phones)
+	if err != nil {
+		return classifySendError(fmt.Errorf("erro ao verificar números no WhatsApp: %w", err))
+	}
+
+	found := make(map[string]types.JID)
+	for _, response := range responses {
+		if !response.IsIn {
+			continue
+		}
+		number := variantOf[strings.TrimPrefix(response.Query, "+")]
+		if _, exists := found[number]; number != "" && !exists {
+			found[number] = response.JID.ToNonAD()
+		}
+	}
+
+	for _, number := range numbers {
+		jid, exists := found[number]
+		s.recipientCache.set(number, jid, exists)
+		if !exists {
+			utils.LogDebug("Número %s não possui WhatsApp no setor %d", number, s.sectorID)
+		}
+	}
+	return nil
+}
+
+func applyRecipientEntry(result *models.NumberCheckResult, entry recipientCacheEntry) {
+	result.Exists = entry.exists
+	if entry.exists {
+		result.Number = entry.jid.User
+		result.JID = entry.jid.String()
+	}
+}
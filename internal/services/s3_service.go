@@ -2,98 +2,170 @@ package services
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"path/filepath"
+	"net/http"
 	"time"
 	"whatsapp-bot/config"
-	"whatsapp-bot/internal/utils"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
+// S3Service implementa o MediaStore sobre o S3 (ou MinIO, apontando ServiceUrl para o servidor)
 type S3Service struct {
 	s3Client *s3.S3
+	uploader *s3manager.Uploader
 	config   *config.S3Config
 }
 
 func NewS3Service(config *config.S3Config) (*S3Service, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String("us-east-1"),
-		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
-		Endpoint:    aws.String(config.ServiceUrl),
-	})
+	awsConfig := &aws.Config{
+		Region:   aws.String(config.Region),
+		Endpoint: aws.String(config.ServiceUrl),
+		// MinIO e outros compatíveis com S3 não resolvem o bucket como subdomínio
+		S3ForcePathStyle: aws.Bool(config.ForcePathStyle),
+	}
+	// Sem chaves configuradas a sessão usa as credenciais do ambiente (IAM role, perfil da instância)
+	if config.AccessKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, "")
+	}
+
+	sess, err := session.NewSession(awsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao criar sessão do S3: %v", err)
 	}
 
+	client := s3.New(sess)
 	return &S3Service{
-		s3Client: s3.New(sess),
-		config:   config,
+		s3Client: client,
+		// Corpos maiores que PartSize são enviados em partes paralelas; os menores em um único PutObject
+		uploader: s3manager.NewUploaderWithClient(client, func(u *s3manager.Uploader) {
+			if config.PartSize >= s3manager.MinUploadPartSize {
+				u.PartSize = config.PartSize
+			}
+		}),
+		config: config,
 	}, nil
 }
 
-func (s *S3Service) UploadFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
-	buffer := make([]byte, fileHeader.Size)
-	if _, err := file.Read(buffer); err != nil {
-		return "", fmt.Errorf("erro ao ler arquivo: %v", err)
+func (s *S3Service) Put(key string, data []byte, contentType string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
 	}
 
-	// Voltar ao início do arquivo para upload
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
-		return "", fmt.Errorf("erro ao resetar arquivo: %v", err)
+	params := &s3.PutObjectInput{
+		Bucket:      aws.String(s.config.BucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
 	}
 
-	// Gerar nome único para o arquivo
-	ext := filepath.Ext(fileHeader.Filename)
-	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-
-	// Detectar o tipo MIME do arquivo
-	contentType := fileHeader.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "application/octet-stream"
+	_, err = s.s3Client.PutObject(params)
+	if err != nil {
+		return fmt.Errorf("erro ao fazer upload para S3: %v", err)
 	}
+	return nil
+}
 
-	utils.LogInfo("Iniciando upload para S3: %s", filename)
+// PutStream envia o arquivo pelo s3manager, que usa multipart upload quando ele excede o tamanho de uma parte.
+// Se o envio em partes falhar, o upload incompleto é abortado pelo próprio s3manager
+func (s *S3Service) PutStream(key string, body io.Reader, size int64, contentType string) error {
+	key, err := cleanMediaKey(key)
+	if err != nil {
+		return err
+	}
 
-	// Parâmetros para upload no S3
-	params := &s3.PutObjectInput{
+	_, err = s.uploader.Upload(&s3manager.UploadInput{
 		Bucket:      aws.String(s.config.BucketName),
-		Key:         aws.String(filename),
-		Body:        bytes.NewReader(buffer),
+		Key:         aws.String(key),
+		Body:        body,
 		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao fazer upload para S3: %v", err)
+	}
+	return nil
+}
+
+// Get lê o conteúdo de um objeto do bucket a partir da sua chave
+func (s *S3Service) Get(key string) ([]byte, error) {
+	output, err := s.s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.config.BucketName),
+		Key:    aws.String(key),
+	})
+	if isS3NotFound(err) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao baixar arquivo do S3: %v", err)
 	}
+	defer output.Body.Close()
 
-	// Realizar upload
-	_, err := s.s3Client.PutObject(params)
+	data, err := io.ReadAll(output.Body)
 	if err != nil {
-		return "", fmt.Errorf("erro ao fazer upload para S3: %v", err)
+		return nil, fmt.Errorf("erro ao ler arquivo do S3: %v", err)
 	}
 
-	// Retornar URL do arquivo
-	fileUrl := fmt.Sprintf("%s/%s", s.config.BucketUrl, filename)
-	utils.LogInfo("Upload concluído: %s", fileUrl)
+	return data, nil
+}
 
-	return fileUrl, nil
+func (s *S3Service) Delete(key string) error {
+	_, err := s.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.config.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao remover arquivo do S3: %v", err)
+	}
+	return nil
 }
 
-func (s *S3Service) UploadBytes(data []byte, fileName string, contentType string) (string, error) {
-	params := &s3.PutObjectInput{
-		Bucket:      aws.String(s.config.BucketName),
-		Key:         aws.String(fileName),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String(contentType),
+// SignedURL gera uma URL pré-assinada de leitura do objeto
+func (s *S3Service) SignedURL(key string, expires time.Duration) (string, error) {
+	request, _ := s.s3Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.config.BucketName),
+		Key:    aws.String(key),
+	})
+	signedURL, err := request.Presign(expires)
+	if err != nil {
+		return "", fmt.Errorf("erro ao assinar URL do S3: %v", err)
 	}
+	return signedURL, nil
+}
 
-	_, err := s.s3Client.PutObject(params)
+func (s *S3Service) Stat(key string) (*MediaObject, error) {
+	output, err := s.s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.config.BucketName),
+		Key:    aws.String(key),
+	})
+	if isS3NotFound(err) {
+		return nil, ErrMediaNotFound
+	}
 	if err != nil {
-		return "", fmt.Errorf("erro ao fazer upload para S3: %v", err)
+		return nil, fmt.Errorf("erro ao consultar arquivo no S3: %v", err)
 	}
 
-	fileUrl := fmt.Sprintf("%s/%s", s.config.BucketUrl, fileName)
-	return fileUrl, nil
+	return &MediaObject{
+		Key:         key,
+		Size:        aws.Int64Value(output.ContentLength),
+		ContentType: aws.StringValue(output.ContentType),
+		ModTime:     aws.TimeValue(output.LastModified),
+	}, nil
+}
+
+// isS3NotFound detecta objeto inexistente (HeadObject não traz corpo, só o status 404)
+func isS3NotFound(err error) bool {
+	var requestErr awserr.RequestFailure
+	if errors.As(err, &requestErr) && requestErr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey
 }
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+	"whatsapp-bot/internal/wsnotify"
+)
+
+const (
+	schedulerPollInterval = 10 * time.Second
+	schedulerBatchSize    = 100
+)
+
+// StartScheduler inicia o agendador que entrega as mensagens agendadas à fila de envio do setor
+func (cm *ConnectionManager) StartScheduler() {
+	cm.schedulerOnce.Do(func() {
+		go cm.runScheduler()
+	})
+}
+
+func (cm *ConnectionManager) runScheduler() {
+	utils.LogInfo("Iniciando agendador de mensagens")
+
+	// Uma reinicialização entre a reserva e a entrada na fila deixaria a mensagem presa em "queued" sem nunca ser enviada
+	if err := cm.scheduledRepository.ResetQueued(); err != nil {
+		utils.LogError("Erro ao recuperar mensagens agendadas reservadas: %v", err)
+	}
+
+	for {
+		cm.fireDueScheduled()
+
+		select {
+		case <-cm.schedulerStop:
+			utils.LogInfo("Agendador de mensagens encerrado")
+			return
+		case <-time.After(schedulerPollInterval):
+		}
+	}
+}
+
+func (cm *ConnectionManager) stopScheduler() {
+	select {
+	case <-cm.schedulerStop:
+	default:
+		close(cm.schedulerStop)
+	}
+}
+
+func (cm *ConnectionManager) fireDueScheduled() {
+	messages, err := cm.scheduledRepository.ListDue(schedulerBatchSize)
+	if err != nil {
+		utils.LogError("Erro ao buscar mensagens agendadas: %v", err)
+		return
+	}
+
+	for _, scheduled := range messages {
+		claimed, err := cm.scheduledRepository.Claim(scheduled.ID)
+		if err != nil {
+			utils.LogError("Erro ao reservar mensagem agendada %d: %v", scheduled.ID, err)
+			continue
+		}
+		if !claimed {
+			// Cancelada ou reagendada entre a consulta e o disparo
+			continue
+		}
+
+		cm.fireScheduled(scheduled)
+	}
+}
+
+// fireScheduled entrega a mensagem agendada ao caminho normal de envio
+func (cm *ConnectionManager) fireScheduled(scheduled *models.ScheduledMessage) {
+	message := &models.OutboundMessage{
+		SectorID:    scheduled.SectorID,
+		Kind:        scheduled.Kind,
+		Recipient:   scheduled.Recipient,
+		Content:     scheduled.Content,
+		MediaKey:    scheduled.MediaKey,
+		FileName:    scheduled.FileName,
+		UserID:      scheduled.UserID,
+		IsAnonymous: scheduled.IsAnonymous,
+		SentAt:      scheduled.SendAt,
+		Source:      models.OutboundSourceScheduled,
+		SourceID:    scheduled.ID,
+	}
+
+	if err := cm.EnqueueOutbound(message); err != nil {
+		utils.LogError("Erro ao disparar mensagem agendada %d: %v", scheduled.ID, err)
+		scheduled.Status = models.ScheduledStatusFailed
+		scheduled.LastError = err.Error()
+		if markErr := cm.scheduledRepository.MarkResult(scheduled.ID, scheduled.Status, "", scheduled.LastError); markErr != nil {
+			utils.LogError("Erro ao marcar mensagem agendada %d como falha: %v", scheduled.ID, markErr)
+		}
+		cm.publishScheduledStatus(scheduled)
+		return
+	}
+
+	scheduled.Status = models.ScheduledStatusQueued
+	scheduled.OutboundMessageID = message.ID
+	if err := cm.scheduledRepository.SetOutbound(scheduled.ID, message.ID); err != nil {
+		utils.LogError("Erro ao vincular mensagem agendada %d à fila: %v", scheduled.ID, err)
+	}
+
+	utils.LogInfo("Mensagem agendada %d disparada para %s no setor %d", scheduled.ID, scheduled.Recipient, scheduled.SectorID)
+	cm.publishScheduledStatus(scheduled)
+}
+
+// handleScheduledResult atualiza a mensagem agendada com o resultado final da fila de envio
+func (cm *ConnectionManager) handleScheduledResult(message *models.OutboundMessage) {
+	scheduled, err := cm.scheduledRepository.GetByID(message.SourceID)
+	if err != nil {
+		utils.LogError("Erro ao buscar mensagem agendada %d: %v", message.SourceID, err)
+		return
+	}
+	if scheduled == nil {
+		return
+	}
+
+	scheduled.Status = models.ScheduledStatusSent
+	scheduled.WhatsAppMessageID = message.WhatsAppMessageID
+	scheduled.LastError = ""
+	if message.Status == models.OutboundStatusDead {
+		scheduled.Status = models.ScheduledStatusFailed
+		scheduled.LastError = message.LastError
+	}
+
+	if err := cm.scheduledRepository.MarkResult(scheduled.ID, scheduled.Status, scheduled.WhatsAppMessageID, scheduled.LastError); err != nil {
+		utils.LogError("Erro ao atualizar mensagem agendada %d: %v", scheduled.ID, err)
+	}
+	cm.publishScheduledStatus(scheduled)
+}
+
+func (cm *ConnectionManager) publishScheduledStatus(scheduled *models.ScheduledMessage) {
+	wsnotify.SendScheduledMessageEvent(
+		scheduled.ID,
+		scheduled.SectorID,
+		scheduled.Recipient,
+		scheduled.Kind,
+		scheduled.Status,
+		scheduled.SendAt,
+		scheduled.OutboundMessageID,
+		scheduled.WhatsAppMessageID,
+		scheduled.LastError,
+	)
+}
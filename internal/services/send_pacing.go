@@ -0,0 +1,74 @@
+package services
+
+import (
+	"math/rand"
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	minTypingDuration = 1 * time.Second
+	maxTypingDuration = 6 * time.Second
+	typingPerChar     = 60 * time.Millisecond
+)
+
+// paceSend aplica o limite de envios do setor e, se configurado, um atraso aleatório
+// e a indicação de digitação/gravação antes da mensagem, imitando o ritmo de uma pessoa.
+// Quando o limite do destinatário está esgotado, retorna RecipientRateLimitedError sem esperar
+func (s *WhatsAppService) paceSend(sectorID int, jid types.JID, media types.ChatPresenceMedia, textLength int) error {
+	settings, err := s.sectorSettingsRepository.GetBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar configurações do setor %d: %v", sectorID, err)
+		settings = models.DefaultSectorSettings(sectorID)
+	}
+
+	if retryAfter := s.connectionManager.sendLimiter.Wait(settings, jid.User); retryAfter > 0 {
+		return &RecipientRateLimitedError{RetryAfter: retryAfter}
+	}
+
+	// Responder ao contato torna a conversa ativa, então passamos a acompanhar sua presença
+	go s.subscribeContactPresence(jid, 0)
+
+	if settings.SendJitterMaxMs > 0 {
+		time.Sleep(time.Duration(rand.Intn(settings.SendJitterMaxMs)) * time.Millisecond)
+	}
+
+	if !settings.TypingBeforeSend || s.client == nil {
+		return nil
+	}
+
+	if err := s.markAvailable(); err != nil {
+		utils.LogDebug("Não foi possível definir presença antes do envio: %v", err)
+		return nil
+	}
+	// Sem atendente com o painel aberto, o dispositivo volta a indisponível após a digitação
+	defer s.releaseAvailability()
+
+	if err := s.client.SendChatPresence(jid, types.ChatPresenceComposing, media); err != nil {
+		utils.LogDebug("Não foi possível enviar status de digitação antes do envio: %v", err)
+		return nil
+	}
+
+	time.Sleep(typingDuration(textLength))
+
+	if err := s.client.SendChatPresence(jid, types.ChatPresencePaused, media); err != nil {
+		utils.LogDebug("Não foi possível limpar status de digitação: %v", err)
+	}
+	return nil
+}
+
+// typingDuration estima o tempo de digitação proporcional ao tamanho do texto
+func typingDuration(textLength int) time.Duration {
+	duration := time.Duration(textLength) * typingPerChar
+	if duration < minTypingDuration {
+		return minTypingDuration
+	}
+	if duration > maxTypingDuration {
+		return maxTypingDuration
+	}
+	return duration
+}
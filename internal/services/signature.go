@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/utils"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// agentSignature resolve a assinatura do atendente e sua posição. A configuração do usuário,
+// quando existir, tem prioridade sobre a do setor; mensagens anônimas nunca são assinadas
+func (s *WhatsAppService) agentSignature(sectorID int, userID *int, isAnonymous bool) (string, string) {
+	if userID == nil || isAnonymous {
+		return "", models.SignaturePlacementNone
+	}
+
+	settings, err := s.sectorSettingsRepository.GetBySector(sectorID)
+	if err != nil {
+		utils.LogError("Erro ao buscar configurações do setor %d: %v", sectorID, err)
+		settings = models.DefaultSectorSettings(sectorID)
+	}
+	signatureTemplate, placement := settings.SignatureTemplate, settings.SignaturePlacement
+
+	override, err := s.userSignatureRepository.GetByUser(*userID)
+	if err != nil {
+		utils.LogError("Erro ao buscar assinatura do usuário %d: %v", *userID, err)
+	} else if override != nil {
+		if override.SignatureTemplate != "" {
+			signatureTemplate = override.SignatureTemplate
+		}
+		if override.SignaturePlacement != "" {
+			placement = override.SignaturePlacement
+		}
+	}
+
+	if placement == models.SignaturePlacementNone || strings.TrimSpace(signatureTemplate) == "" {
+		return "", models.SignaturePlacementNone
+	}
+
+	user, err := s.userRepository.GetByID(*userID)
+	if err != nil || user == nil {
+		return "", models.SignaturePlacementNone
+	}
+
+	signature := RenderTemplate(signatureTemplate, &TemplateData{
+		Agent: user,
+		Now:   time.Now().In(defaultTemplateLocation()),
+	})
+	return signature, placement
+}
+
+// signContent aplica a assinatura ao texto ou legenda na posição configurada
+func signContent(content string, signature string, placement string) string {
+	if signature == "" {
+		return content
+	}
+	if content == "" {
+		return signature
+	}
+
+	switch placement {
+	case models.SignaturePlacementPrefix:
+		return signature + "\n\n" + content
+	case models.SignaturePlacementSuffix:
+		return content + "\n\n" + signature
+	}
+	return content
+}
+
+// sendSignatureText envia a assinatura como mensagem de texto separada (usado em áudios, que não têm legenda).
+// É chamado uma única vez, após o envio do áudio; uma falha aqui não faz o áudio ser reenviado
+func (s *WhatsAppService) sendSignatureText(jid types.JID, signature string) {
+	if signature == "" {
+		return
+	}
+	if _, err := s.client.SendMessage(context.Background(), jid, &waProto.Message{
+		Conversation: proto.String(signature),
+	}); err != nil {
+		utils.LogWarning("Não foi possível enviar a assinatura para %s: %v", jid.String(), err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
+	"whatsapp-bot/internal/utils"
+)
+
+// DefaultTemplateTimezone é usado em {{date}} e {{time}} quando o chamador não informa um fuso
+const DefaultTemplateTimezone = "America/Sao_Paulo"
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// TemplateData reúne os valores disponíveis para os placeholders de um template
+type TemplateData struct {
+	Contact   *models.Contact
+	Agent     *models.User
+	Variables map[string]string
+	Now       time.Time
+}
+
+// RenderTemplate substitui os placeholders do texto. Variáveis informadas pelo chamador têm prioridade
+// sobre os valores do contato e do atendente; placeholders desconhecidos permanecem no texto
+func RenderTemplate(content string, data *TemplateData) string {
+	return templatePlaceholder.ReplaceAllStringFunc(content, func(placeholder string) string {
+		key := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+
+		if value, ok := data.Variables[key]; ok {
+			return value
+		}
+		if value, ok := data.builtin(key); ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+func (d *TemplateData) builtin(key string) (string, bool) {
+	switch key {
+	case "contact.name":
+		if d.Contact != nil {
+			return d.Contact.Name, true
+		}
+		return "", true
+	case "contact.number":
+		if d.Contact != nil {
+			if number, err := phone.ForSector(d.Contact.SectorID, d.Contact.Number); err == nil {
+				return number.Digits(), true
+			}
+			return strings.TrimSuffix(d.Contact.Number, "@s.whatsapp.net"), true
+		}
+		return "", true
+	case "contact.email":
+		if d.Contact != nil {
+			return d.Contact.Email, true
+		}
+		return "", true
+	case "agent.name":
+		if d.Agent != nil {
+			return d.Agent.Name, true
+		}
+		return "", true
+	case "date":
+		return d.Now.Format("02/01/2006"), true
+	case "time":
+		return d.Now.Format("15:04"), true
+	case "datetime":
+		return d.Now.Format("02/01/2006 15:04"), true
+	}
+	return "", false
+}
+
+// TemplateData carrega o contato e o atendente usados para resolver os placeholders de uma mensagem
+func (cm *ConnectionManager) TemplateData(sectorID int, recipient string, userID *int, variables map[string]string, loc *time.Location) *TemplateData {
+	if loc == nil {
+		loc = defaultTemplateLocation()
+	}
+
+	data := &TemplateData{
+		Variables: variables,
+		Now:       time.Now().In(loc),
+	}
+
+	contact, err := cm.contactRepository.GetByNumber(sectorID, recipient)
+	if err != nil {
+		utils.LogError("Erro ao buscar contato %s para template: %v", recipient, err)
+	}
+	data.Contact = contact
+
+	if userID != nil {
+		agent, err := cm.userRepository.GetByID(*userID)
+		if err != nil {
+			utils.LogError("Erro ao buscar usuário %d para template: %v", *userID, err)
+		}
+		data.Agent = agent
+	}
+
+	return data
+}
+
+func defaultTemplateLocation() *time.Location {
+	loc, err := time.LoadLocation(DefaultTemplateTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// TemplateMediaKey gera a chave no armazenamento do anexo de um template, mantido enquanto houver mensagens que o usem
+func TemplateMediaKey(sectorID int, fileName string) string {
+	return fmt.Sprintf("sector_%d/templates/%d_%s", sectorID, time.Now().UnixNano(), fileName)
+}
@@ -1,22 +1,19 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"whatsapp-bot/config"
 	"whatsapp-bot/internal/models"
+	"whatsapp-bot/internal/phone"
 	"whatsapp-bot/internal/repositories"
 	"whatsapp-bot/internal/utils"
 	"whatsapp-bot/internal/wsnotify"
@@ -44,17 +41,28 @@ type WhatsAppService struct {
 	connectionManager *ConnectionManager
 	messageRepository models.MessageRepository
 	contactRepository models.ContactRepository
-	s3Service         *S3Service
+	mediaStore        MediaStore
 	userRepository    models.UserRepository
+
+	sectorSettingsRepository models.SectorSettingsRepository
+	userSignatureRepository  models.UserSignatureRepository
+
+	presenceTimers        map[string]*time.Timer
+	presenceSubscriptions map[string]*presenceSubscription // JID do contato -> conversa ativa com presença assinada
+	presenceLookups       map[string]presenceLookup
+	presenceMutex         sync.Mutex
+	contactPresence       *presenceThrottle
+	available             bool       // Dispositivo marcado como disponível nesta conexão
+	availabilityMutex     sync.Mutex // Serializa as mudanças de disponibilidade do dispositivo
+
+	recipientCache   *recipientCache
+	uploadCache      *uploadCache
+	documentPreviews *documentPreviewCache
 }
 
 func NewWhatsAppService(config *config.Config, connectionManager *ConnectionManager, messageRepository models.MessageRepository, contactRepository models.ContactRepository) *WhatsAppService {
-	s3Service, err := NewS3Service(config.S3Config)
-	if err != nil {
-		utils.LogError("Error creating S3 service: %v", err)
-	}
-
 	userRepository := repositories.NewMySQLUserRepository(connectionManager.db)
+	sectorSettingsRepository := repositories.NewMySQLSectorSettingsRepository(connectionManager.db)
 
 	service := &WhatsAppService{
 		config:            config,
@@ -63,8 +71,20 @@ func NewWhatsAppService(config *config.Config, connectionManager *ConnectionMana
 		connectionManager: connectionManager,
 		messageRepository: messageRepository,
 		contactRepository: contactRepository,
-		s3Service:         s3Service,
+		mediaStore:        connectionManager.mediaStore,
 		userRepository:    userRepository,
+
+		sectorSettingsRepository: sectorSettingsRepository,
+		userSignatureRepository:  repositories.NewMySQLUserSignatureRepository(connectionManager.db),
+
+		presenceTimers:        make(map[string]*time.Timer),
+		presenceSubscriptions: make(map[string]*presenceSubscription),
+		presenceLookups:       make(map[string]presenceLookup),
+		contactPresence:       newPresenceThrottle(0),
+
+		recipientCache:   newRecipientCache(),
+		uploadCache:      newUploadCache(),
+		documentPreviews: newDocumentPreviewCache(),
 	}
 	return service
 }
@@ -72,6 +92,7 @@ func NewWhatsAppService(config *config.Config, connectionManager *ConnectionMana
 func (s *WhatsAppService) SetSectorAndManager(sectorID int, manager *ConnectionManager) {
 	s.sectorID = sectorID
 	s.manager = manager
+	s.contactPresence = newPresenceThrottle(sectorID)
 }
 
 func (s *WhatsAppService) getDBPath() string {
@@ -80,7 +101,7 @@ func (s *WhatsAppService) getDBPath() string {
 
 func (s *WhatsAppService) Connect() error {
 	// Definir nome e tipo de plataforma para aparecer como navegador
-	store.DeviceProps.Os = proto.String("LigChat")
+	store.DeviceProps.Os = proto.String(s.config.DeviceName)
 	store.DeviceProps.PlatformType = waProto.DeviceProps_DESKTOP.Enum()
 
 	utils.LogInfo("Conectando ao WhatsApp para setor %d", s.sectorID)
@@ -182,10 +203,20 @@ func (s *WhatsAppService) eventHandler(evt interface{}) {
 	switch evt.(type) {
 	case *events.Message:
 		s.handleMessage(evt)
+	case *events.CallOffer:
+		s.handleCallOffer(evt)
+	case *events.Receipt:
+		s.handleReceipt(evt)
+	case *events.Presence:
+		s.handlePresence(evt)
+	case *events.ChatPresence:
+		s.handleChatPresence(evt)
 	case *events.Connected:
 		utils.LogInfo("WhatsApp conectado para setor %d", s.sectorID)
 		s.SetConnected(true)
+		s.resetPresenceSubscriptions()
 		s.manager.SetConnected(s.sectorID)
+		s.manager.notifyOutboundDispatcher(s.sectorID)
 	case *events.Disconnected:
 		utils.LogWarning("WhatsApp desconectado para setor %d", s.sectorID)
 		s.SetConnected(false)
@@ -269,77 +300,75 @@ func (s *WhatsAppService) handleDatabaseLock() error {
 	return nil
 }
 
-func (s *WhatsAppService) SendMessage(sectorID int, recipient string, message string, userID *int, isAnonymous bool, sentAt time.Time) error {
-	prefix := ""
-	if userID != nil && !isAnonymous {
-		user, err := s.userRepository.GetByID(*userID)
-		if err == nil && user != nil {
-			prefix = "*" + user.Name + "*:\n\n"
-		}
-	}
-	msgToSend := prefix + message
+// SendMessage envia um texto ao destinatário. messageID define o ID da mensagem no WhatsApp; vazio gera um novo
+func (s *WhatsAppService) SendMessage(sectorID int, recipient string, message string, userID *int, isAnonymous bool, sentAt time.Time, messageID types.MessageID) (string, error) {
+	signature, placement := s.agentSignature(sectorID, userID, isAnonymous)
+	msgToSend := signContent(message, signature, placement)
 
 	conn, err := s.connectionManager.GetConnection(sectorID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	jid, err := utils.ParseJID(recipient)
+	// Confirmar que o número possui WhatsApp e usar o JID canônico (com ou sem o nono dígito)
+	jid, err := conn.ResolveRecipient(recipient)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Buscar ou criar o contato para atualizar sua ordem
-	contact, err := s.contactRepository.GetByNumber(sectorID, recipient)
+	// Buscar ou criar o contato, pelo telefone ou pelo LID, para atualizar sua ordem
+	contact, _, err := conn.contactForJID(sectorID, jid, types.EmptyJID)
 	if err != nil {
 		utils.LogError("Erro ao buscar contato: %v", err)
-	} else if contact == nil {
-		contact, err = s.contactRepository.CreateIfNotExists(sectorID, recipient)
-		if err != nil {
-			utils.LogError("Erro ao criar contato: %v", err)
-		}
+	} else {
+		// Gravar a conversa no contato resolvido, mesmo que o destinatário tenha vindo como LID
+		recipient = contact.Number
+	}
+
+	// Respeitar o limite de envios e o ritmo configurado para o setor
+	if err := conn.paceSend(sectorID, jid, types.ChatPresenceMediaText, len(msgToSend)); err != nil {
+		return "", err
 	}
 
 	utils.LogInfo("Tentando enviar mensagem para %s", recipient)
 
 	msg, err := conn.client.SendMessage(context.Background(), jid, &waProto.Message{
 		Conversation: proto.String(msgToSend),
-	})
+	}, whatsmeow.SendRequestExtra{ID: messageID})
 
 	if err != nil {
 		utils.LogWarning("Erro na primeira tentativa de envio: %v", err)
 
-		if strings.Contains(err.Error(), "database is locked") ||
-			strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isDatabaseLocked(err) {
 			utils.LogInfo("Detectado banco de dados bloqueado, tentando resolver...")
 			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco de dados: %v (original: %v)", fixErr, err)
+				return "", fmt.Errorf("erro ao consertar banco de dados: %v (original: %v)", fixErr, err)
 			}
 		}
 
-		if strings.Contains(err.Error(), "untrusted identity") {
+		if isUntrustedIdentity(err) {
 			utils.LogInfo("Detectada identidade não confiável, tentando resolver...")
 			if err := conn.Reconnect(); err != nil {
-				return fmt.Errorf("erro ao reconectar após identidade não confiável: %v", err)
+				return "", fmt.Errorf("erro ao reconectar após identidade não confiável: %v", err)
 			}
 		}
 
 		utils.LogInfo("Tentando enviar mensagem novamente...")
 		msg, err = conn.client.SendMessage(context.Background(), jid, &waProto.Message{
 			Conversation: proto.String(msgToSend),
-		})
+		}, whatsmeow.SendRequestExtra{ID: messageID})
 
 		if err != nil {
-			if strings.Contains(err.Error(), "server returned error 479") {
-				return fmt.Errorf("erro de conexão com WhatsApp (479), por favor tente novamente em alguns instantes")
+			if isServerError(err, 479) {
+				return "", newError(ErrUpstream, fmt.Errorf("erro de conexão com WhatsApp (479), por favor tente novamente em alguns instantes"))
 			}
-			return fmt.Errorf("erro persistente ao enviar mensagem: %v", err)
+			return "", classifySendError(fmt.Errorf("erro persistente ao enviar mensagem: %w", err))
 		}
 	}
 
 	utils.LogInfo("Mensagem enviada com sucesso para %s", recipient)
 
-	err = s.SaveMessage(sectorID, recipient, message, "text", "", "", "", msg.ID, true, userID, isAnonymous, sentAt)
+	err = s.SaveMessage(sectorID, recipient, message, msgToSend, "text", "", "", "", msg.ID, true, userID, isAnonymous, sentAt)
 	if err != nil {
 		utils.LogError("Error saving message: %v", err)
 	}
@@ -353,183 +382,111 @@ func (s *WhatsAppService) SendMessage(sectorID int, recipient string, message st
 		go s.contactRepository.UpdateContactOrder(sectorID, contact.ID)
 	}
 
-	return nil
+	return msg.ID, nil
 }
 
-// Nova função para marcar mensagens anteriores como lidas
-func (s *WhatsAppService) markPreviousMessagesAsRead(sectorID int, contactJID string) {
-	// Buscar o contato
-	contact, err := s.contactRepository.GetByNumber(sectorID, contactJID)
-	if err != nil || contact == nil {
-		utils.LogError("Erro ao buscar contato para marcar mensagens como lidas: %v", err)
-		return
-	}
-
-	// Buscar mensagens recebidas deste contato (não enviadas pelo sistema)
-	// Aqui precisamos fazer uma consulta personalizada
-	query := `
-		SELECT id FROM messages 
-		WHERE id_setor = ? AND contato_id = ? AND enviado = 0
-		ORDER BY data_envio DESC 
-		LIMIT 20` // Limitar a 20 mensagens mais recentes
-
-	rows, err := s.connectionManager.db.Query(query, sectorID, contact.ID)
+// markPreviousMessagesAsRead marca a conversa como lida após uma resposta, quando o setor tiver essa opção ativa
+func (s *WhatsAppService) markPreviousMessagesAsRead(sectorID int, recipient string) {
+	settings, err := s.sectorSettingsRepository.GetBySector(sectorID)
 	if err != nil {
-		utils.LogError("Erro ao buscar mensagens anteriores: %v", err)
+		utils.LogError("Erro ao buscar configurações do setor %d: %v", sectorID, err)
+		settings = models.DefaultSectorSettings(sectorID)
+	}
+	if !settings.AutoMarkReadOnReply {
 		return
 	}
-	defer rows.Close()
-
-	// Para cada mensagem, enviar uma atualização WebSocket
-	var messageIDs []int
-	for rows.Next() {
-		var messageID int
-		if err := rows.Scan(&messageID); err == nil {
-			messageIDs = append(messageIDs, messageID)
-
-			// Buscar detalhes da mensagem para enviar atualização
-			message, err := s.messageRepository.GetByID(messageID)
-			if err == nil && message != nil {
-				// Enviar recibo de leitura para o WhatsApp oficial
-				if s.client != nil && message.WhatsAppMessageID != "" {
-					jid := utils.JIDFromContatoID(message.ContatoID)
-					s.client.MarkRead(
-						[]types.MessageID{types.MessageID(message.WhatsAppMessageID)},
-						time.Now(),
-						jid,
-						jid,
-					)
-				}
-				// Enviar evento de atualização por WebSocket
-				var urlPtr, fileNamePtr, mimeTypePtr *string
-				if message.URL != "" {
-					urlPtr = &message.URL
-				}
-				if message.NomeArquivo != "" {
-					fileNamePtr = &message.NomeArquivo
-				}
-				if message.MimeType != "" {
-					mimeTypePtr = &message.MimeType
-				}
 
-				// Criar um timestamp usando a data da mensagem
-				var messageTime time.Time
-				var err error
-				// Se a mensagem.DataEnvio está no formato brasileiro DD/MM/AAAA HH:MM:SS, precisamos converter
-				messageTime, err = time.Parse("02/01/2006 15:04:05", message.DataEnvio)
-				if err != nil {
-					// Se falhar, usar a hora atual
-					messageTime = time.Now()
-				}
-
-				// Enviar WebSocket com status atualizado para "lido" (duas barras azuis)
-				wsnotify.SendMessageEvent(
-					message.ID,
-					int(message.ContatoID),
-					message.IDSetor,
-					message.Conteudo,
-					message.Tipo,
-					urlPtr,
-					fileNamePtr,
-					mimeTypePtr,
-					messageTime,
-					message.Enviado,
-					true,              // Marcar como lida
-					models.StatusRead, // Status com duas barras azuis
-				)
-			}
-		}
+	contact, err := s.contactRepository.GetByNumber(sectorID, recipient)
+	if err != nil || contact == nil {
+		utils.LogError("Erro ao buscar contato para marcar mensagens como lidas: %v", err)
+		return
 	}
 
-	// Marcar no banco também como lidas
-	if len(messageIDs) > 0 {
-		utils.LogInfo("Marcando %d mensagens como lidas para o contato %s", len(messageIDs), contactJID)
-		if err := s.messageRepository.MarkMessagesAsRead(messageIDs); err != nil {
-			utils.LogError("Erro ao marcar mensagens como lidas no banco: %v", err)
-		}
+	if _, _, err := s.connectionManager.MarkConversationRead(sectorID, contact.ID); err != nil {
+		utils.LogError("Erro ao marcar conversa do contato %d como lida: %v", contact.ID, err)
 	}
 }
 
-func (s *WhatsAppService) SendImage(sectorID int, recipient string, imageBytes []byte, caption string, userID *int, isAnonymous bool, sentAt time.Time) error {
+func (s *WhatsAppService) SendImage(sectorID int, recipient string, imageBytes []byte, caption string, userID *int, isAnonymous bool, sentAt time.Time, messageID types.MessageID) (string, error) {
 	conn, err := s.connectionManager.GetConnection(sectorID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	jid, err := utils.ParseJID(recipient)
+	// Confirmar que o número possui WhatsApp e usar o JID canônico (com ou sem o nono dígito)
+	jid, err := conn.ResolveRecipient(recipient)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Buscar ou criar o contato para atualizar sua ordem
-	contact, err := s.contactRepository.GetByNumber(sectorID, recipient)
+	// Buscar ou criar o contato, pelo telefone ou pelo LID, para atualizar sua ordem
+	contact, _, err := conn.contactForJID(sectorID, jid, types.EmptyJID)
 	if err != nil {
 		utils.LogError("Erro ao buscar contato: %v", err)
-	} else if contact == nil {
-		contact, err = s.contactRepository.CreateIfNotExists(sectorID, recipient)
-		if err != nil {
-			utils.LogError("Erro ao criar contato: %v", err)
-		}
+	} else {
+		// Gravar a conversa no contato resolvido, mesmo que o destinatário tenha vindo como LID
+		recipient = contact.Number
 	}
 
-	mimeType := http.DetectContentType(imageBytes)
-	fileName := fmt.Sprintf("sector_%d/images/%d.%s", sectorID, time.Now().UnixNano(), utils.GetExtensionFromMime(mimeType))
+	signature, placement := s.agentSignature(sectorID, userID, isAnonymous)
+	signedCaption := signContent(caption, signature, placement)
 
-	utils.LogInfo("Fazendo upload da imagem para S3...")
-	s3URL, err := s.s3Service.UploadBytes(imageBytes, fileName, mimeType)
-	if err != nil {
-		return fmt.Errorf("erro ao fazer upload para S3: %v", err)
+	// Respeitar o limite de envios e o ritmo configurado para o setor
+	if err := conn.paceSend(sectorID, jid, types.ChatPresenceMediaText, len(signedCaption)); err != nil {
+		return "", err
 	}
 
-	utils.LogInfo("Upload para S3 concluído, URL: %s", s3URL)
+	// Sem metadados (ex: localização do EXIF), no tamanho recomendado e com miniatura para a notificação
+	image := prepareImage(imageBytes)
+	imageBytes, mimeType := image.Data, image.MimeType
 
-	uploaded, err := conn.client.Upload(context.Background(), imageBytes, whatsmeow.MediaImage)
+	// O mesmo arquivo enviado a vários contatos é guardado e enviado ao WhatsApp uma única vez
+	sum := mediaHash(imageBytes)
+	fileName, err := s.storeMediaOnce(sectorID, imageBytes, sum, utils.GetExtensionFromMime(mimeType), mimeType)
 	if err != nil {
-		utils.LogError("Erro no upload da imagem: %v", err)
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
-			}
-			uploaded, err = conn.client.Upload(context.Background(), imageBytes, whatsmeow.MediaImage)
-			if err != nil {
-				return fmt.Errorf("erro persistente ao fazer upload: %v", err)
-			}
-		} else {
-			return err
-		}
+		return "", err
+	}
+	s.storeMediaPreview(fileName, image.Preview)
+
+	uploaded, err := conn.uploadMedia(imageBytes, sum, whatsmeow.MediaImage)
+	if err != nil {
+		return "", err
 	}
 
 	imgMsg := &waProto.Message{
 		ImageMessage: &waProto.ImageMessage{
-			Caption:       proto.String(caption),
+			Caption:       proto.String(signedCaption),
 			URL:           proto.String(uploaded.URL),
 			DirectPath:    proto.String(uploaded.DirectPath),
 			MediaKey:      uploaded.MediaKey,
 			Mimetype:      proto.String(mimeType),
-			FileLength:    proto.Uint64(uint64(len(imageBytes))),
+			FileLength:    proto.Uint64(uploaded.FileLength),
 			FileSHA256:    uploaded.FileSHA256,
 			FileEncSHA256: uploaded.FileEncSHA256,
+			JPEGThumbnail: image.Thumbnail,
 		},
 	}
+	if image.Width > 0 && image.Height > 0 {
+		imgMsg.ImageMessage.Width = proto.Uint32(uint32(image.Width))
+		imgMsg.ImageMessage.Height = proto.Uint32(uint32(image.Height))
+	}
 
-	msg, err := conn.client.SendMessage(context.Background(), jid, imgMsg)
+	msg, err := conn.client.SendMessage(context.Background(), jid, imgMsg, whatsmeow.SendRequestExtra{ID: messageID})
 	if err != nil {
-		if strings.Contains(err.Error(), "untrusted identity") ||
-			strings.Contains(err.Error(), "database is locked") ||
-			strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isUntrustedIdentity(err) || isDatabaseLocked(err) {
 			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
+				return "", fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
 			}
-			msg, err = conn.client.SendMessage(context.Background(), jid, imgMsg)
+			msg, err = conn.client.SendMessage(context.Background(), jid, imgMsg, whatsmeow.SendRequestExtra{ID: messageID})
 			if err != nil {
-				return fmt.Errorf("erro persistente ao enviar imagem: %v", err)
+				return "", classifySendError(fmt.Errorf("erro persistente ao enviar imagem: %w", err))
 			}
 		} else {
-			return err
+			return "", classifySendError(err)
 		}
 	}
 
-	err = s.SaveMessage(sectorID, recipient, caption, "image", s3URL, fileName, mimeType, msg.ID, true, userID, isAnonymous, sentAt)
+	err = s.SaveMessage(sectorID, recipient, caption, signedCaption, "image", fileName, fileName, mimeType, msg.ID, true, userID, isAnonymous, sentAt)
 	if err != nil {
 		utils.LogError("Error saving message: %v", err)
 	}
@@ -542,274 +499,101 @@ func (s *WhatsAppService) SendImage(sectorID int, recipient string, imageBytes [
 		go s.contactRepository.UpdateContactOrder(sectorID, contact.ID)
 	}
 
-	return nil
-}
-
-// Função para converter qualquer formato de áudio para OGG usando ffmpeg
-func (s *WhatsAppService) convertToOgg(audioBytes []byte) ([]byte, float32, []byte, error) {
-	// Criar diretório temporário com caminho absoluto
-	tempDir, err := os.MkdirTemp("", "whatsapp_audio_*")
-	if err != nil {
-		return nil, 0, nil, fmt.Errorf("erro ao criar diretório temporário: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Detectar extensão baseado no MIME type
-	mimeType := http.DetectContentType(audioBytes)
-	ext := ".bin"
-	switch {
-	case strings.Contains(mimeType, "wav"):
-		ext = ".wav"
-	case strings.Contains(mimeType, "mp3"):
-		ext = ".mp3"
-	case strings.Contains(mimeType, "ogg"):
-		ext = ".ogg"
-	case strings.Contains(mimeType, "webm"):
-		ext = ".webm"
-	case strings.Contains(mimeType, "mpeg"):
-		ext = ".mp3"
-	case strings.Contains(mimeType, "x-m4a"):
-		ext = ".m4a"
-	}
-
-	// Usar caminhos absolutos para os arquivos temporários
-	tempInputPath := filepath.Join(tempDir, fmt.Sprintf("input_%d%s", time.Now().UnixNano(), ext))
-	if err := os.WriteFile(tempInputPath, audioBytes, 0644); err != nil {
-		return nil, 0, nil, fmt.Errorf("erro ao salvar arquivo de áudio temporário: %v", err)
-	}
-
-	tempOggPath := filepath.Join(tempDir, fmt.Sprintf("output_%d.ogg", time.Now().UnixNano()))
-	tempWavPath := filepath.Join(tempDir, fmt.Sprintf("temp_%d.wav", time.Now().UnixNano()))
-
-	utils.LogInfo("Convertendo áudio para OGG (Opus) usando FFmpeg...")
-	utils.LogDebug("Arquivo de entrada: %s", tempInputPath)
-	utils.LogDebug("Arquivo de saída: %s", tempOggPath)
-
-	// Primeiro, converter para WAV para análise
-	cmdWav := exec.Command("ffmpeg",
-		"-i", tempInputPath,
-		"-acodec", "pcm_s16le",
-		"-ar", "16000",
-		"-ac", "1",
-		"-y",
-		tempWavPath,
-	)
-
-	if err := cmdWav.Run(); err != nil {
-		return nil, 0, nil, fmt.Errorf("erro ao converter para WAV: %v", err)
-	}
-
-	// Obter duração do áudio
-	duration, err := s.getAudioDuration(tempWavPath)
-	if err != nil {
-		utils.LogError("Erro ao obter duração do áudio: %v", err)
-		duration = 0
-	}
-
-	// Gerar waveform
-	waveform, err := s.generateWaveform(tempWavPath)
-	if err != nil {
-		utils.LogError("Erro ao gerar waveform: %v", err)
-		waveform = []byte{0x5, 0x8, 0x10, 0x12, 0x15, 0x12, 0x10, 0x8, 0x5}
-	}
-
-	// Converter para OGG final
-	cmd := exec.Command("ffmpeg",
-		"-i", tempInputPath,
-		"-c:a", "libopus",
-		"-ar", "48000",
-		"-ac", "1",
-		"-b:a", "128k",
-		"-application", "voip",
-		"-y",
-		tempOggPath,
-	)
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		utils.LogError("Erro ao executar FFmpeg: %v\nSaída de erro: %s", err, stderr.String())
-		return nil, 0, nil, fmt.Errorf("erro ao converter áudio: %v\nDetalhes: %s", err, stderr.String())
-	}
-
-	// Verificar se o arquivo de saída foi criado
-	if _, err := os.Stat(tempOggPath); os.IsNotExist(err) {
-		return nil, 0, nil, fmt.Errorf("arquivo de saída não foi criado pelo FFmpeg")
-	}
-
-	// Ler o arquivo OGG convertido
-	oggBytes, err := os.ReadFile(tempOggPath)
-	if err != nil {
-		return nil, 0, nil, fmt.Errorf("erro ao ler arquivo OGG convertido: %v", err)
-	}
-
-	utils.LogInfo("Conversão concluída com sucesso. Tamanho do arquivo: %d bytes, Duração: %.2f segundos", len(oggBytes), duration)
-	return oggBytes, duration, waveform, nil
-}
-
-func (s *WhatsAppService) getAudioDuration(filePath string) (float32, error) {
-	cmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-show_entries", "format=duration",
-		"-of", "default=noprint_wrappers=1:nokey=1",
-		filePath,
-	)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
-	}
-
-	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 32)
-	if err != nil {
-		return 0, err
-	}
-
-	return float32(duration), nil
-}
-
-func (s *WhatsAppService) generateWaveform(wavPath string) ([]byte, error) {
-	cmd := exec.Command("ffmpeg",
-		"-i", wavPath,
-		"-filter:a", "aformat=channel_layouts=mono,highpass=f=200,lowpass=f=3000,compand=gain=20:attack=0.01:release=0.05:points=-90/-90 -70/-90 -15/-15 0/-10,showwavespic=s=32x32:colors=black:filter=peak:scale=lin",
-		"-frames:v", "1",
-		"-f", "image2pipe",
-		"-vcodec", "rawvideo",
-		"-pix_fmt", "gray",
-		"pipe:1",
-	)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	// Converter a imagem em escala de cinza para valores de 0-31
-	waveform := make([]byte, 32)
-	for i := 0; i < 32; i++ {
-		var sum int
-		for j := 0; j < 32; j++ {
-			sum += int(output[i*32+j])
-		}
-		avg := sum / 32
-
-		// Aplicar curva de resposta não linear mais agressiva
-		normalized := float64(avg) / 255.0
-
-		// Usar uma função de potência com expoente menor para aumentar valores baixos
-		// e uma escala maior para aumentar a amplitude geral
-		enhanced := math.Pow(normalized, 0.4) // Expoente menor = mais sensível a sons baixos
-
-		// Aumentar a amplitude geral e garantir que valores baixos sejam mais visíveis
-		amplified := math.Min(enhanced*1.8+0.2, 1.0) // Adicionar offset e amplificar
-
-		// Converter para byte, garantindo valor mínimo de 3 para ter sempre alguma visualização
-		value := int(amplified * 31)
-		if value < 3 {
-			value = 3
-		}
-		waveform[i] = byte(value)
-	}
-
-	return waveform, nil
+	return msg.ID, nil
 }
 
-func (s *WhatsAppService) SendAudio(sectorID int, recipient string, audioBytes []byte, userID *int, isAnonymous bool, sentAt time.Time) error {
+func (s *WhatsAppService) SendAudio(sectorID int, recipient string, audioBytes []byte, userID *int, isAnonymous bool, sentAt time.Time, messageID types.MessageID) (string, error) {
 	conn, err := s.connectionManager.GetConnection(sectorID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	jid, err := utils.ParseJID(recipient)
+	// Confirmar que o número possui WhatsApp e usar o JID canônico (com ou sem o nono dígito)
+	jid, err := conn.ResolveRecipient(recipient)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Buscar ou criar o contato para atualizar sua ordem
-	contact, err := s.contactRepository.GetByNumber(sectorID, recipient)
+	// Buscar ou criar o contato, pelo telefone ou pelo LID, para atualizar sua ordem
+	contact, _, err := conn.contactForJID(sectorID, jid, types.EmptyJID)
 	if err != nil {
 		utils.LogError("Erro ao buscar contato: %v", err)
-	} else if contact == nil {
-		contact, err = s.contactRepository.CreateIfNotExists(sectorID, recipient)
-		if err != nil {
-			utils.LogError("Erro ao criar contato: %v", err)
-		}
+	} else {
+		// Gravar a conversa no contato resolvido, mesmo que o destinatário tenha vindo como LID
+		recipient = contact.Number
+	}
+
+	// Respeitar o limite de envios e o ritmo configurado para o setor
+	if err := conn.paceSend(sectorID, jid, types.ChatPresenceMediaAudio, 0); err != nil {
+		return "", err
 	}
 
 	mimeType := http.DetectContentType(audioBytes)
 	utils.LogInfo("Tipo MIME original detectado: %s", mimeType)
 
-	// Sempre converter para OGG com codec Opus
-	utils.LogInfo("Convertendo áudio para OGG (Opus)...")
-	oggBytes, duration, waveform, err := s.convertToOgg(audioBytes)
+	// Mensagens de voz precisam estar em OGG com codec Opus
+	audio, err := prepareAudio(audioBytes)
 	if err != nil {
 		utils.LogError("Erro ao converter áudio para OGG (Opus): %v", err)
-		// Se falhar a conversão, tentar enviar como documento
-		return s.SendDocument(sectorID, recipient, audioBytes, "audio"+filepath.Ext(mimeType), userID, isAnonymous, sentAt)
+		// Se falhar a conversão, enviar como documento ao mesmo destinatário, sem aplicar o limite e o ritmo de novo
+		signature, placement := s.agentSignature(sectorID, userID, isAnonymous)
+		return s.sendDocument(conn, jid, contact, sectorID, recipient, audioBytes, audioFallbackFileName(mimeType), "", signContent("", signature, placement), userID, isAnonymous, sentAt, messageID)
 	}
 
-	fileName := fmt.Sprintf("sector_%d/audios/%d.ogg", sectorID, time.Now().UnixNano())
-	utils.LogInfo("Fazendo upload do áudio para S3...")
-	s3URL, err := s.s3Service.UploadBytes(oggBytes, fileName, "audio/ogg; codecs=opus")
+	// A conversão não gera sempre os mesmos bytes, então o áudio é endereçado pelo hash do arquivo original
+	sum := mediaHash(audioBytes)
+	fileName, err := s.storeMediaOnce(sectorID, audio.data, sum, "ogg", voiceMimeType)
 	if err != nil {
-		return fmt.Errorf("erro ao fazer upload para S3: %v", err)
+		return "", err
 	}
 
-	utils.LogInfo("Upload para S3 concluído, URL: %s", s3URL)
-
-	uploaded, err := conn.client.Upload(context.Background(), oggBytes, whatsmeow.MediaAudio)
+	uploaded, err := conn.uploadMedia(audio.data, sum, whatsmeow.MediaAudio)
 	if err != nil {
-		utils.LogError("Erro no upload do áudio: %v", err)
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
-			}
-			uploaded, err = conn.client.Upload(context.Background(), oggBytes, whatsmeow.MediaAudio)
-			if err != nil {
-				return fmt.Errorf("erro persistente ao fazer upload: %v", err)
-			}
-		} else {
-			return err
-		}
+		return "", err
 	}
 
 	audioMsg := &waProto.AudioMessage{
 		URL:           proto.String(uploaded.URL),
 		DirectPath:    proto.String(uploaded.DirectPath),
 		MediaKey:      uploaded.MediaKey,
-		Mimetype:      proto.String("audio/ogg; codecs=opus"),
-		FileLength:    proto.Uint64(uint64(len(oggBytes))),
+		Mimetype:      proto.String(voiceMimeType),
+		FileLength:    proto.Uint64(uploaded.FileLength),
 		FileSHA256:    uploaded.FileSHA256,
 		FileEncSHA256: uploaded.FileEncSHA256,
-		Seconds:       proto.Uint32(uint32(duration)),
+		Seconds:       proto.Uint32(uint32(audio.duration)),
 		PTT:           proto.Bool(true),
-		Waveform:      waveform,
+		Waveform:      audio.waveform,
 	}
 
+	// Áudios não têm legenda no WhatsApp: a assinatura vai como uma mensagem de texto separada
+	signature, _ := s.agentSignature(sectorID, userID, isAnonymous)
+
 	msg, err := conn.client.SendMessage(context.Background(), jid, &waProto.Message{
 		AudioMessage: audioMsg,
-	})
+	}, whatsmeow.SendRequestExtra{ID: messageID})
 
 	if err != nil {
-		if strings.Contains(err.Error(), "untrusted identity") ||
-			strings.Contains(err.Error(), "database is locked") ||
-			strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isUntrustedIdentity(err) || isDatabaseLocked(err) {
 			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
+				return "", fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
 			}
 			msg, err = conn.client.SendMessage(context.Background(), jid, &waProto.Message{
 				AudioMessage: audioMsg,
-			})
+			}, whatsmeow.SendRequestExtra{ID: messageID})
 			if err != nil {
-				return fmt.Errorf("erro persistente ao enviar áudio: %v", err)
+				return "", classifySendError(fmt.Errorf("erro persistente ao enviar áudio: %w", err))
 			}
 		} else {
-			return err
+			return "", classifySendError(err)
 		}
 	}
 
-	err = s.SaveMessage(sectorID, recipient, "", "audio", s3URL, fileName, mimeType, msg.ID, true, userID, isAnonymous, sentAt)
+	// A assinatura só é enviada depois que o áudio foi aceito, em qualquer posição configurada: enviada antes,
+	// ela se repetiria a cada nova tentativa da fila para um áudio que falhou
+	conn.sendSignatureText(jid, signature)
+
+	// O arquivo guardado é o OGG (Opus) enviado, não o áudio original
+	err = s.SaveMessage(sectorID, recipient, "", signature, "audio", fileName, fileName, voiceMimeType, msg.ID, true, userID, isAnonymous, sentAt)
 	if err != nil {
 		utils.LogError("Error saving message: %v", err)
 	}
@@ -822,55 +606,55 @@ func (s *WhatsAppService) SendAudio(sectorID int, recipient string, audioBytes [
 		go s.contactRepository.UpdateContactOrder(sectorID, contact.ID)
 	}
 
-	return nil
+	return msg.ID, nil
 }
 
-func (s *WhatsAppService) SendDocument(sectorID int, recipient string, fileBytes []byte, filename string, userID *int, isAnonymous bool, sentAt time.Time) error {
+func (s *WhatsAppService) SendDocument(sectorID int, recipient string, fileBytes []byte, filename string, caption string, userID *int, isAnonymous bool, sentAt time.Time, messageID types.MessageID) (string, error) {
 	conn, err := s.connectionManager.GetConnection(sectorID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	jid, err := utils.ParseJID(recipient)
+	// Confirmar que o número possui WhatsApp e usar o JID canônico (com ou sem o nono dígito)
+	jid, err := conn.ResolveRecipient(recipient)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Buscar ou criar o contato para atualizar sua ordem
-	contact, err := s.contactRepository.GetByNumber(sectorID, recipient)
+	// Buscar ou criar o contato, pelo telefone ou pelo LID, para atualizar sua ordem
+	contact, _, err := conn.contactForJID(sectorID, jid, types.EmptyJID)
 	if err != nil {
 		utils.LogError("Erro ao buscar contato: %v", err)
-	} else if contact == nil {
-		contact, err = s.contactRepository.CreateIfNotExists(sectorID, recipient)
-		if err != nil {
-			utils.LogError("Erro ao criar contato: %v", err)
-		}
+	} else {
+		// Gravar a conversa no contato resolvido, mesmo que o destinatário tenha vindo como LID
+		recipient = contact.Number
 	}
 
+	signature, placement := s.agentSignature(sectorID, userID, isAnonymous)
+	signedCaption := signContent(caption, signature, placement)
+
+	// Respeitar o limite de envios e o ritmo configurado para o setor
+	if err := conn.paceSend(sectorID, jid, types.ChatPresenceMediaText, len(signedCaption)); err != nil {
+		return "", err
+	}
+
+	return s.sendDocument(conn, jid, contact, sectorID, recipient, fileBytes, filename, caption, signedCaption, userID, isAnonymous, sentAt, messageID)
+}
+
+// sendDocument envia o documento ao destinatário já resolvido, depois do limite e do ritmo de envio aplicados
+func (s *WhatsAppService) sendDocument(conn *WhatsAppService, jid types.JID, contact *models.Contact, sectorID int, recipient string, fileBytes []byte, filename string, caption string, signedCaption string, userID *int, isAnonymous bool, sentAt time.Time, messageID types.MessageID) (string, error) {
 	mimeType := http.DetectContentType(fileBytes)
-	s3FileName := fmt.Sprintf("sector_%d/documents/%d_%s", sectorID, time.Now().UnixNano(), filename)
 
-	utils.LogInfo("Fazendo upload do documento para S3...")
-	s3URL, err := s.s3Service.UploadBytes(fileBytes, s3FileName, mimeType)
+	// O mesmo arquivo enviado a vários contatos é guardado e enviado ao WhatsApp uma única vez
+	sum := mediaHash(fileBytes)
+	s3FileName, err := s.storeMediaOnce(sectorID, fileBytes, sum, filepath.Ext(filename), mimeType)
 	if err != nil {
-		return fmt.Errorf("erro ao fazer upload para S3: %v", err)
+		return "", err
 	}
 
-	utils.LogInfo("Upload para S3 concluído, URL: %s", s3URL)
-
-	uploaded, err := conn.client.Upload(context.Background(), fileBytes, whatsmeow.MediaDocument)
+	uploaded, err := conn.uploadMedia(fileBytes, sum, whatsmeow.MediaDocument)
 	if err != nil {
-		if strings.Contains(err.Error(), "database is locked") || strings.Contains(err.Error(), "SQLITE_BUSY") {
-			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
-			}
-			uploaded, err = conn.client.Upload(context.Background(), fileBytes, whatsmeow.MediaDocument)
-			if err != nil {
-				return fmt.Errorf("erro persistente ao fazer upload: %v", err)
-			}
-		} else {
-			return err
-		}
+		return "", err
 	}
 
 	docMsg := &waProto.Message{
@@ -881,30 +665,44 @@ func (s *WhatsAppService) SendDocument(sectorID int, recipient string, fileBytes
 			Mimetype:      proto.String(mimeType),
 			Title:         proto.String(filename),
 			FileName:      proto.String(filename),
-			FileLength:    proto.Uint64(uint64(len(fileBytes))),
+			Caption:       proto.String(signedCaption),
+			FileLength:    proto.Uint64(uploaded.FileLength),
 			FileSHA256:    uploaded.FileSHA256,
 			FileEncSHA256: uploaded.FileEncSHA256,
 		},
 	}
 
-	msg, err := conn.client.SendMessage(context.Background(), jid, docMsg)
+	// PDFs e documentos do Office vão com a miniatura da primeira página e o número de páginas
+	if preview := s.documentPreviewFor(fileBytes, sum, filename, mimeType); preview != nil {
+		docMsg.DocumentMessage.JPEGThumbnail = preview.thumbnail
+		docMsg.DocumentMessage.ThumbnailWidth = proto.Uint32(uint32(preview.width))
+		docMsg.DocumentMessage.ThumbnailHeight = proto.Uint32(uint32(preview.height))
+		if preview.pageCount > 0 {
+			docMsg.DocumentMessage.PageCount = proto.Uint32(uint32(preview.pageCount))
+		}
+		s.storeMediaPreview(s3FileName, preview.preview)
+	}
+
+	msg, err := conn.client.SendMessage(context.Background(), jid, docMsg, whatsmeow.SendRequestExtra{ID: messageID})
 	if err != nil {
-		if strings.Contains(err.Error(), "untrusted identity") ||
-			strings.Contains(err.Error(), "database is locked") ||
-			strings.Contains(err.Error(), "SQLITE_BUSY") {
+		if isUntrustedIdentity(err) || isDatabaseLocked(err) {
 			if fixErr := conn.handleDatabaseLock(); fixErr != nil {
-				return fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
+				return "", fmt.Errorf("erro ao consertar banco: %v (original: %v)", fixErr, err)
 			}
-			msg, err = conn.client.SendMessage(context.Background(), jid, docMsg)
+			msg, err = conn.client.SendMessage(context.Background(), jid, docMsg, whatsmeow.SendRequestExtra{ID: messageID})
 			if err != nil {
-				return fmt.Errorf("erro persistente ao enviar documento: %v", err)
+				return "", classifySendError(fmt.Errorf("erro persistente ao enviar documento: %w", err))
 			}
 		} else {
-			return err
+			return "", classifySendError(err)
 		}
 	}
 
-	err = s.SaveMessage(sectorID, recipient, filename, "document", s3URL, filename, mimeType, msg.ID, true, userID, isAnonymous, sentAt)
+	content := filename
+	if caption != "" {
+		content = caption
+	}
+	err = s.SaveMessage(sectorID, recipient, content, signedCaption, "document", s3FileName, filename, mimeType, msg.ID, true, userID, isAnonymous, sentAt)
 	if err != nil {
 		utils.LogError("Error saving message: %v", err)
 	}
@@ -917,57 +715,12 @@ func (s *WhatsAppService) SendDocument(sectorID int, recipient string, fileBytes
 		go s.contactRepository.UpdateContactOrder(sectorID, contact.ID)
 	}
 
-	return nil
+	return msg.ID, nil
 }
 
+// SendTyping inicia a indicação de digitação, que volta a paused automaticamente após duration segundos
 func (s *WhatsAppService) SendTyping(recipient string, duration int) error {
-	if !s.connected {
-		return fmt.Errorf("whatsapp não está conectado")
-	}
-
-	if s.client == nil {
-		return fmt.Errorf("cliente do whatsapp não inicializado")
-	}
-
-	// Adiciona código do país (Brasil - 55) se não estiver presente
-	if len(recipient) == 11 || len(recipient) == 10 {
-		recipient = "55" + recipient
-	}
-
-	jid, err := types.ParseJID(recipient + "@s.whatsapp.net")
-	if err != nil {
-		return fmt.Errorf("número de telefone inválido: %v", err)
-	}
-
-	// Se a duração não for especificada, usa 5 segundos como padrão
-	if duration <= 0 {
-		duration = 5
-	}
-
-	// Inicia o estado de digitando
-	err = s.client.SendPresence(types.PresenceAvailable)
-	if err != nil {
-		if err.Error() == "can't send presence without PushName set" {
-			return fmt.Errorf("não foi possível enviar status de digitação: dispositivo ainda não está totalmente configurado")
-		}
-		return fmt.Errorf("erro ao definir presença: %v", err)
-	}
-
-	err = s.client.SendChatPresence(jid, types.ChatPresenceComposing, types.ChatPresenceMediaText)
-	if err != nil {
-		return fmt.Errorf("erro ao enviar status de digitação: %v", err)
-	}
-
-	// Aguarda a duração especificada
-	time.Sleep(time.Duration(duration) * time.Second)
-
-	// Para o estado de digitando
-	err = s.client.SendChatPresence(jid, types.ChatPresencePaused, types.ChatPresenceMediaText)
-	if err != nil {
-		return fmt.Errorf("erro ao limpar status de digitação: %v", err)
-	}
-
-	return nil
+	return s.SetChatPresence(recipient, models.PresenceStateComposing, duration)
 }
 
 func (s *WhatsAppService) Logout() error {
@@ -1118,7 +871,9 @@ func (s *WhatsAppService) RestoreSession() error {
 	return nil
 }
 
-func (s *WhatsAppService) SaveMessage(sectorID int, contactJID string, content string, messageType string, url string, fileName string, mimeType string, whatsappMessageID string, isFromSystem bool, userID *int, isAnonymous bool, sentAt time.Time) error {
+// SaveMessage grava a mensagem na conversa. content é o texto original (sem assinatura) e
+// sentContent o texto efetivamente enviado ao WhatsApp
+func (s *WhatsAppService) SaveMessage(sectorID int, contactJID string, content string, sentContent string, messageType string, url string, fileName string, mimeType string, whatsappMessageID string, isFromSystem bool, userID *int, isAnonymous bool, sentAt time.Time) error {
 	// Obter ou criar o contato
 	contact, err := s.contactRepository.CreateIfNotExists(sectorID, contactJID)
 	if err != nil {
@@ -1139,6 +894,7 @@ func (s *WhatsAppService) SaveMessage(sectorID int, contactJID string, content s
 
 	message := &models.Message{
 		Conteudo:          content,
+		ConteudoEnviado:   sentContent,
 		Tipo:              messageType,
 		URL:               url,
 		NomeArquivo:       fileName,
@@ -1219,11 +975,8 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 			return
 		}
 
-		senderJID := msg.Info.Sender.String()
-		normalizedJID := senderJID
-		if parts := strings.Split(senderJID, ":"); len(parts) > 1 {
-			normalizedJID = parts[0] + "@" + strings.Split(parts[1], "@")[1]
-		}
+		// Remover agente e dispositivo do remetente; o repositório normaliza o número
+		normalizedJID := msg.Info.Sender.ToNonAD().String()
 
 		// Verificar se é um status pelo endereço do remetente
 		if normalizedJID == "status@broadcast" ||
@@ -1257,33 +1010,27 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 			return
 		}
 
+		// Buscar ou criar o contato; o remetente pode vir endereçado pelo LID em vez do telefone
 		sectorID := s.sectorID
-		contact, err := s.contactRepository.GetByNumber(sectorID, normalizedJID)
+		contact, phoneJID, err := s.contactForJID(sectorID, msg.Info.Sender, msg.Info.SenderAlt)
 		if err != nil {
 			utils.LogError("Error checking contact: %v", err)
 			return
 		}
 
-		// Criar contato se não existir
-		if contact == nil {
-			utils.LogInfo("Criando contato que não existe no banco: %s", normalizedJID)
-			contact, err = s.contactRepository.CreateIfNotExists(sectorID, normalizedJID)
-			if err != nil {
-				utils.LogError("Error creating contact: %v", err)
-				return
-			}
-		}
-
-		// Atualizar foto do contato do WhatsApp se não houver avatar
-		if contact.AvatarURL == "" || contact.AvatarURL == "null" {
-			s.fetchContactInfo(sectorID, normalizedJID)
+		// Atualizar foto do contato do WhatsApp se não houver avatar (só é possível com o telefone)
+		if !phoneJID.IsEmpty() && (contact.AvatarURL == "" || contact.AvatarURL == "null") {
+			s.fetchContactInfo(sectorID, phoneJID.String())
 		}
 
 		// Mover o contato para o topo da lista
 		go s.contactRepository.UpdateContactOrder(sectorID, contact.ID)
 
+		// Acompanhar se o contato está online ou digitando enquanto a conversa está ativa
+		go s.subscribeContactPresence(msg.Info.Sender.ToNonAD(), contact.ID)
+
 		// Marcar como não visualizado ao receber mensagem
-		err = s.contactRepository.SetUnviewed(sectorID, normalizedJID)
+		err = s.contactRepository.SetUnviewed(sectorID, contact.Number)
 		if err != nil {
 			utils.LogError("Error marking contact as unviewed: %v", err)
 		}
@@ -1317,10 +1064,11 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 
 			if data, err := s.client.Download(imgMsg); err == nil {
 				fileName = fmt.Sprintf("sector_%d/images/%s.%s", sectorID, msg.Info.ID, utils.GetExtensionFromMime(mimeType))
-				if s3URL, err := s.s3Service.UploadBytes(data, fileName, mimeType); err == nil {
-					url = s3URL
+				if err := s.mediaStore.Put(fileName, data, mimeType); err == nil {
+					url = fileName
+					go s.storeReceivedImagePreview(fileName, data)
 				} else {
-					utils.LogError("Error uploading image to S3: %v", err)
+					utils.LogError("Error uploading image to media store: %v", err)
 				}
 			}
 
@@ -1331,10 +1079,10 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 
 			if data, err := s.client.Download(audioMsg); err == nil {
 				fileName = fmt.Sprintf("sector_%d/audios/%s.%s", sectorID, msg.Info.ID, utils.GetExtensionFromMime(mimeType))
-				if s3URL, err := s.s3Service.UploadBytes(data, fileName, mimeType); err == nil {
-					url = s3URL
+				if err := s.mediaStore.Put(fileName, data, mimeType); err == nil {
+					url = fileName
 				} else {
-					utils.LogError("Error uploading audio to S3: %v", err)
+					utils.LogError("Error uploading audio to media store: %v", err)
 				}
 			}
 
@@ -1346,10 +1094,10 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 
 			if data, err := s.client.Download(docMsg); err == nil {
 				s3FileName := fmt.Sprintf("sector_%d/documents/%s_%s", sectorID, msg.Info.ID, fileName)
-				if s3URL, err := s.s3Service.UploadBytes(data, s3FileName, mimeType); err == nil {
-					url = s3URL
+				if err := s.mediaStore.Put(s3FileName, data, mimeType); err == nil {
+					url = s3FileName
 				} else {
-					utils.LogError("Error uploading document to S3: %v", err)
+					utils.LogError("Error uploading document to media store: %v", err)
 				}
 			}
 
@@ -1361,10 +1109,10 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 
 			if data, err := s.client.Download(vidMsg); err == nil {
 				fileName = fmt.Sprintf("sector_%d/videos/%s.%s", sectorID, msg.Info.ID, utils.GetExtensionFromMime(mimeType))
-				if s3URL, err := s.s3Service.UploadBytes(data, fileName, mimeType); err == nil {
-					url = s3URL
+				if err := s.mediaStore.Put(fileName, data, mimeType); err == nil {
+					url = fileName
 				} else {
-					utils.LogError("Error uploading video to S3: %v", err)
+					utils.LogError("Error uploading video to media store: %v", err)
 				}
 			}
 
@@ -1388,8 +1136,8 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 
 					if data, err := s.client.Download(imageMsg); err == nil {
 						fileName = fmt.Sprintf("sector_%d/images/%s.%s", sectorID, msg.Info.ID, utils.GetExtensionFromMime(mimeType))
-						if s3URL, err := s.s3Service.UploadBytes(data, fileName, mimeType); err == nil {
-							url = s3URL
+						if err := s.mediaStore.Put(fileName, data, mimeType); err == nil {
+							url = fileName
 						}
 					}
 				}
@@ -1402,8 +1150,8 @@ func (s *WhatsAppService) handleMessage(evt interface{}) {
 
 			if data, err := s.client.Download(stickerMsg); err == nil {
 				fileName = fmt.Sprintf("sector_%d/stickers/%s.webp", sectorID, msg.Info.ID)
-				if s3URL, err := s.s3Service.UploadBytes(data, fileName, mimeType); err == nil {
-					url = s3URL
+				if err := s.mediaStore.Put(fileName, data, mimeType); err == nil {
+					url = fileName
 				}
 			}
 
@@ -1504,15 +1252,18 @@ func (s *WhatsAppService) fetchContactInfo(sectorID int, jid string) {
 		return
 	}
 
-	normalizedJID := jid
-	if parts := strings.Split(jid, ":"); len(parts) > 1 {
-		normalizedJID = parts[0] + "@" + strings.Split(parts[1], "@")[1]
-	}
-
-	normalizedJID = strings.TrimSuffix(normalizedJID, "@s.whatsapp.net")
-	if !strings.Contains(normalizedJID, "@") {
-		normalizedJID = normalizedJID + "@s.whatsapp.net"
+	// Consultar o WhatsApp pelo JID recebido, que pode diferir do número canônico (ex: sem o nono dígito)
+	parsedJID, err := types.ParseJID(jid)
+	if err != nil || parsedJID.Server != types.DefaultUserServer {
+		number, parseErr := phone.ForSector(sectorID, jid)
+		if parseErr != nil {
+			utils.LogError("Número inválido ao buscar informações de contato: %s", jid)
+			return
+		}
+		parsedJID = number.JID()
 	}
+	parsedJID = parsedJID.ToNonAD()
+	normalizedJID := parsedJID.String()
 
 	contact, err := s.contactRepository.GetByNumber(sectorID, normalizedJID)
 	if err != nil {
@@ -1529,12 +1280,6 @@ func (s *WhatsAppService) fetchContactInfo(sectorID int, jid string) {
 		}
 	}
 
-	phoneNumber := strings.TrimSuffix(normalizedJID, "@s.whatsapp.net")
-	parsedJID := types.JID{
-		User:   phoneNumber,
-		Server: "s.whatsapp.net",
-	}
-
 	updated := false
 
 	contactInfo, err := s.client.Store.Contacts.GetContact(parsedJID)
@@ -1554,13 +1299,13 @@ func (s *WhatsAppService) fetchContactInfo(sectorID int, jid string) {
 			if err == nil {
 				defer resp.Body.Close()
 				if picData, err := io.ReadAll(resp.Body); err == nil {
-					s3FileName := fmt.Sprintf("sector_%d/avatars/%s.jpg", sectorID, phoneNumber)
-					if s3URL, err := s.s3Service.UploadBytes(picData, s3FileName, "image/jpeg"); err == nil {
-						contact.AvatarURL = s3URL
+					s3FileName := fmt.Sprintf("sector_%d/avatars/%s.jpg", sectorID, parsedJID.User)
+					if err := s.mediaStore.Put(s3FileName, picData, "image/jpeg"); err == nil {
+						contact.AvatarURL = s3FileName
 						updated = true
-						utils.LogInfo("URL do avatar atualizada: %s", s3URL)
+						utils.LogInfo("Avatar do contato atualizado: %s", s3FileName)
 					} else {
-						utils.LogError("Erro ao fazer upload do avatar para S3: %v", err)
+						utils.LogError("Erro ao fazer upload do avatar: %v", err)
 					}
 				}
 			}
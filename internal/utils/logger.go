@@ -1,10 +1,10 @@
 package utils
 
 import (
-	"fmt"
 	"io"
 	"log"
 	"time"
+	"whatsapp-bot/internal/phone"
 
 	"go.mau.fi/whatsmeow/types"
 )
@@ -48,16 +48,11 @@ func GetCurrentTimestamp() int64 {
 	return time.Now().Unix()
 }
 
+// ParseJID normaliza o número na região padrão e retorna o JID correspondente
 func ParseJID(recipient string) (types.JID, error) {
-	if len(recipient) == 11 || len(recipient) == 10 {
-		recipient = "55" + recipient
-	}
-	return types.ParseJID(recipient + "@s.whatsapp.net")
-}
-
-func JIDFromContatoID(contatoID int64) types.JID {
-	return types.JID{
-		User:   fmt.Sprintf("%d", contatoID),
-		Server: "s.whatsapp.net",
+	number, err := phone.Parse(recipient, phone.DefaultRegion)
+	if err != nil {
+		return types.JID{}, err
 	}
+	return number.JID(), nil
 }
@@ -14,7 +14,7 @@ func GetExtensionFromMime(mimeType string) string {
 		return "ogg"
 	case "audio/mpeg", "audio/mp3":
 		return "mp3"
-	case "audio/wav":
+	case "audio/wav", "audio/wave":
 		return "wav"
 	case "video/mp4":
 		return "mp4"
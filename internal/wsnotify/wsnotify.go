@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"whatsapp-bot/internal/mediaurl"
 	"whatsapp-bot/internal/models"
 
 	"github.com/gorilla/websocket"
@@ -52,6 +53,18 @@ func (m *WebSocketManager) RemoveClient(conn *websocket.Conn) {
 	delete(m.clients, conn)
 }
 
+// HasSectorClients informa se há algum painel do setor conectado
+func (m *WebSocketManager) HasSectorClients(sectorID int) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, client := range m.clients {
+		if client.SectorID == sectorID {
+			return true
+		}
+	}
+	return false
+}
+
 // Broadcast envia mensagem para todos os clientes conectados
 func (m *WebSocketManager) Broadcast(event interface{}) {
 	m.lock.RLock()
@@ -91,6 +104,7 @@ type MessagePayload struct {
 	Content       string  `json:"content"`
 	MediaType     string  `json:"mediaType"`
 	MediaUrl      *string `json:"mediaUrl"`
+	MediaPath     string  `json:"mediaPath,omitempty"` // Endereço estável que redireciona para um link novo
 	FileName      *string `json:"fileName"`
 	MimeType      *string `json:"mimeType"`
 	SentAt        string  `json:"sentAt"`
@@ -124,7 +138,7 @@ func SendMessageEvent(
 		SectorID:      sectorID,
 		Content:       content,
 		MediaType:     mediaType,
-		MediaUrl:      mediaUrl,
+		MediaUrl:      mediaurl.SignPtr(mediaUrl),
 		FileName:      fileName,
 		MimeType:      mimeType,
 		SentAt:        sentAt.Format(time.RFC3339Nano),
@@ -132,6 +146,9 @@ func SendMessageEvent(
 		IsRead:        isRead,
 		MessageStatus: messageStatus,
 	}
+	if mediaUrl != nil && *mediaUrl != "" {
+		payload.MediaPath = mediaurl.MessageMediaPath(sectorID, id)
+	}
 	event := MessageEvent{
 		Type:    "message",
 		Payload: payload,
@@ -146,6 +163,7 @@ type ContactPayload struct {
 	Name          string    `json:"name"`
 	Number        string    `json:"number"`
 	AvatarUrl     string    `json:"avatarUrl"`
+	AvatarPath    string    `json:"avatarPath,omitempty"` // Endereço estável que redireciona para um link novo
 	IsViewed      bool      `json:"isViewed"`
 	ContactStatus string    `json:"contactStatus"`
 	CreatedAt     time.Time `json:"createdAt"`
@@ -153,6 +171,14 @@ type ContactPayload struct {
 	Order         int       `json:"order"`
 }
 
+// avatarPath retorna o endereço estável do avatar, vazio quando o contato não tem foto
+func avatarPath(sectorID int, contactID int, avatarURL string) string {
+	if mediaurl.Key(avatarURL) == "" {
+		return ""
+	}
+	return mediaurl.ContactAvatarPath(sectorID, contactID)
+}
+
 type ContactEvent struct {
 	Type    string         `json:"type"`
 	Payload ContactPayload `json:"payload"`
@@ -176,7 +202,8 @@ func SendContactEvent(
 		SectorID:      sectorID,
 		Name:          name,
 		Number:        number,
-		AvatarUrl:     avatarUrl,
+		AvatarUrl:     mediaurl.Sign(avatarUrl),
+		AvatarPath:    avatarPath(sectorID, id, avatarUrl),
 		IsViewed:      isViewed,
 		ContactStatus: contactStatus,
 		CreatedAt:     createdAt,
@@ -241,7 +268,8 @@ func SendContactsList(sectorID int, contacts []*models.Contact) {
 			SectorID:      contact.SectorID,
 			Name:          contact.Name,
 			Number:        contact.Number,
-			AvatarUrl:     contact.AvatarURL,
+			AvatarUrl:     mediaurl.Sign(contact.AvatarURL),
+			AvatarPath:    avatarPath(contact.SectorID, contact.ID, contact.AvatarURL),
 			IsViewed:      contact.IsViewed,
 			ContactStatus: contact.ContactStatus,
 			CreatedAt:     contact.CreatedAt,
@@ -262,3 +290,269 @@ func SendContactsList(sectorID int, contacts []*models.Contact) {
 
 	Manager.BroadcastToSector(event, sectorID)
 }
+
+// CallPayload define os dados de uma chamada recebida para eventos WebSocket
+type CallPayload struct {
+	MessageID int    `json:"messageId"`
+	ContactID int    `json:"contactId"`
+	SectorID  int    `json:"sectorId"`
+	CallID    string `json:"callId"`
+	Caller    string `json:"caller"`
+	IsVideo   bool   `json:"isVideo"`
+	Action    string `json:"action"`
+	CalledAt  string `json:"calledAt"`
+}
+
+type CallEvent struct {
+	Type    string      `json:"type"`
+	Payload CallPayload `json:"payload"`
+}
+
+// SendCallEvent envia um evento de chamada recebida via WebSocket
+func SendCallEvent(
+	messageID int,
+	contactID int,
+	sectorID int,
+	callID string,
+	caller string,
+	isVideo bool,
+	action string,
+	calledAt time.Time,
+) {
+	payload := CallPayload{
+		MessageID: messageID,
+		ContactID: contactID,
+		SectorID:  sectorID,
+		CallID:    callID,
+		Caller:    caller,
+		IsVideo:   isVideo,
+		Action:    action,
+		CalledAt:  calledAt.UTC().Format(time.RFC3339Nano),
+	}
+	event := CallEvent{
+		Type:    "call",
+		Payload: payload,
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}
+
+// OutboundStatusPayload define os dados de mudança de estado de uma mensagem da fila de envio
+type OutboundStatusPayload struct {
+	ID                int64  `json:"id"`
+	SectorID          int    `json:"sectorId"`
+	Recipient         string `json:"recipient"`
+	Kind              string `json:"kind"`
+	Status            string `json:"status"`
+	Attempts          int    `json:"attempts"`
+	WhatsAppMessageID string `json:"whatsappMessageId,omitempty"`
+	LastError         string `json:"lastError,omitempty"`
+}
+
+type OutboundStatusEvent struct {
+	Type    string                `json:"type"`
+	Payload OutboundStatusPayload `json:"payload"`
+}
+
+// SendOutboundStatusEvent envia a mudança de estado de uma mensagem enfileirada via WebSocket
+func SendOutboundStatusEvent(
+	id int64,
+	sectorID int,
+	recipient string,
+	kind string,
+	status string,
+	attempts int,
+	whatsappMessageID string,
+	lastError string,
+) {
+	payload := OutboundStatusPayload{
+		ID:                id,
+		SectorID:          sectorID,
+		Recipient:         recipient,
+		Kind:              kind,
+		Status:            status,
+		Attempts:          attempts,
+		WhatsAppMessageID: whatsappMessageID,
+		LastError:         lastError,
+	}
+	event := OutboundStatusEvent{
+		Type:    "outbound_status",
+		Payload: payload,
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}
+
+// ScheduledMessagePayload define os dados de disparo ou falha de uma mensagem agendada
+type ScheduledMessagePayload struct {
+	ID                int64  `json:"id"`
+	SectorID          int    `json:"sectorId"`
+	Recipient         string `json:"recipient"`
+	Kind              string `json:"kind"`
+	Status            string `json:"status"`
+	SendAt            string `json:"sendAt"`
+	OutboundMessageID int64  `json:"outboundMessageId,omitempty"`
+	WhatsAppMessageID string `json:"whatsappMessageId,omitempty"`
+	LastError         string `json:"lastError,omitempty"`
+}
+
+type ScheduledMessageEvent struct {
+	Type    string                  `json:"type"`
+	Payload ScheduledMessagePayload `json:"payload"`
+}
+
+// SendScheduledMessageEvent envia a mudança de estado de uma mensagem agendada via WebSocket
+func SendScheduledMessageEvent(
+	id int64,
+	sectorID int,
+	recipient string,
+	kind string,
+	status string,
+	sendAt time.Time,
+	outboundMessageID int64,
+	whatsappMessageID string,
+	lastError string,
+) {
+	payload := ScheduledMessagePayload{
+		ID:                id,
+		SectorID:          sectorID,
+		Recipient:         recipient,
+		Kind:              kind,
+		Status:            status,
+		SendAt:            sendAt.UTC().Format(time.RFC3339Nano),
+		OutboundMessageID: outboundMessageID,
+		WhatsAppMessageID: whatsappMessageID,
+		LastError:         lastError,
+	}
+	event := ScheduledMessageEvent{
+		Type:    "scheduled_message",
+		Payload: payload,
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}
+
+// CampaignStatusPayload define os dados de mudança de estado de uma campanha
+type CampaignStatusPayload struct {
+	CampaignID int64          `json:"campaignId"`
+	SectorID   int            `json:"sectorId"`
+	Status     string         `json:"status"`
+	Counts     map[string]int `json:"counts,omitempty"`
+}
+
+type CampaignStatusEvent struct {
+	Type    string                `json:"type"`
+	Payload CampaignStatusPayload `json:"payload"`
+}
+
+// SendCampaignStatusEvent envia a mudança de estado de uma campanha via WebSocket
+func SendCampaignStatusEvent(campaignID int64, sectorID int, status string, counts map[string]int) {
+	event := CampaignStatusEvent{
+		Type: "campaign_status",
+		Payload: CampaignStatusPayload{
+			CampaignID: campaignID,
+			SectorID:   sectorID,
+			Status:     status,
+			Counts:     counts,
+		},
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}
+
+// CampaignRecipientPayload define os dados de mudança de estado de um destinatário da campanha
+type CampaignRecipientPayload struct {
+	CampaignID        int64  `json:"campaignId"`
+	RecipientID       int64  `json:"recipientId"`
+	SectorID          int    `json:"sectorId"`
+	Recipient         string `json:"recipient"`
+	Status            string `json:"status"`
+	WhatsAppMessageID string `json:"whatsappMessageId,omitempty"`
+	LastError         string `json:"lastError,omitempty"`
+}
+
+type CampaignRecipientEvent struct {
+	Type    string                   `json:"type"`
+	Payload CampaignRecipientPayload `json:"payload"`
+}
+
+// SendCampaignRecipientEvent envia a mudança de estado de um destinatário da campanha via WebSocket
+func SendCampaignRecipientEvent(
+	campaignID int64,
+	recipientID int64,
+	sectorID int,
+	recipient string,
+	status string,
+	whatsappMessageID string,
+	lastError string,
+) {
+	payload := CampaignRecipientPayload{
+		CampaignID:        campaignID,
+		RecipientID:       recipientID,
+		SectorID:          sectorID,
+		Recipient:         recipient,
+		Status:            status,
+		WhatsAppMessageID: whatsappMessageID,
+		LastError:         lastError,
+	}
+	event := CampaignRecipientEvent{
+		Type:    "campaign_recipient",
+		Payload: payload,
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}
+
+// AgentPresencePayload define o estado de presença exibido pelo setor em uma conversa
+type AgentPresencePayload struct {
+	SectorID  int    `json:"sectorId"`
+	Recipient string `json:"recipient"`
+	State     string `json:"state"`
+}
+
+type AgentPresenceEvent struct {
+	Type    string               `json:"type"`
+	Payload AgentPresencePayload `json:"payload"`
+}
+
+// SendAgentPresenceEvent envia a mudança de presença do setor (digitando, gravando, pausado) via WebSocket
+func SendAgentPresenceEvent(sectorID int, recipient string, state string) {
+	payload := AgentPresencePayload{
+		SectorID:  sectorID,
+		Recipient: recipient,
+		State:     state,
+	}
+	event := AgentPresenceEvent{
+		Type:    "agent_presence",
+		Payload: payload,
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}
+
+// ContactPresencePayload define o estado de presença de um contato (online, digitando, gravando)
+type ContactPresencePayload struct {
+	SectorID  int     `json:"sectorId"`
+	ContactID int     `json:"contactId"`
+	Number    string  `json:"number"`
+	State     string  `json:"state"`
+	LastSeen  *string `json:"lastSeen,omitempty"`
+}
+
+type ContactPresenceEvent struct {
+	Type    string                 `json:"type"`
+	Payload ContactPresencePayload `json:"payload"`
+}
+
+// SendContactPresenceEvent envia a mudança de presença de um contato via WebSocket
+func SendContactPresenceEvent(sectorID int, contactID int, number string, state string, lastSeen *time.Time) {
+	payload := ContactPresencePayload{
+		SectorID:  sectorID,
+		ContactID: contactID,
+		Number:    number,
+		State:     state,
+	}
+	if lastSeen != nil {
+		formatted := lastSeen.Format(time.RFC3339)
+		payload.LastSeen = &formatted
+	}
+	event := ContactPresenceEvent{
+		Type:    "contact_presence",
+		Payload: payload,
+	}
+	Manager.BroadcastToSector(event, sectorID)
+}